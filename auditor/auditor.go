@@ -0,0 +1,274 @@
+// Package auditor 定期检查log server的一致性。
+// 每次轮询获取新的digest并从上一次验证过的digest验证consistency proof,
+// 可选地下载新的epoch重新计算verkle tree的commitment和叶子的content hash。
+// 验证失败时写入一条带有证据的告警记录。
+package auditor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"MerkleVerkle/client"
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+)
+
+// 告警的类型
+const (
+	KindInconsistent = "inconsistent" // consistency proof验证失败
+	KindRollback     = "rollback"     // digest变小
+	KindInvalidProof = "invalid_proof"
+	KindInvalidEpoch = "invalid_epoch" // 重新计算的epoch与log中的叶子不一致
+)
+
+// Config 是auditor的参数
+type Config struct {
+	ServerURL string
+	StatePath string // 最后验证过的digest
+	AlertPath string // 告警记录, 每行一个JSON
+	Interval  time.Duration
+	Hasher    crypto.Hasher
+	Tiles     int               // 大于0时ServerURL是导出的tile目录, proof从这个高度的tile计算
+	PublicKey ed25519.PublicKey // 不为空时告警附带log签名的证据, 第三方可以用core.VerifyMisbehaviour复核
+
+	// Recompute为true时下载新的epoch, 用K和VerkleDepth重新计算
+	Recompute   bool
+	K           uint32
+	VerkleDepth uint32
+}
+
+// Alert 是一条告警记录, 包含复核所需的全部证据
+type Alert struct {
+	Time        time.Time                    `json:"time"`
+	Kind        string                       `json:"kind"`
+	Message     string                       `json:"message"`
+	Trusted     *core.Digest                 `json:"trusted,omitempty"`
+	Digest      *core.Digest                 `json:"digest,omitempty"`
+	Consistency *core.MerkleConsistencyProof `json:"consistency,omitempty"`
+	Inclusion   *core.MerkleInclusionProof   `json:"inclusion,omitempty"`
+	Epoch       *uint32                      `json:"epoch,omitempty"`
+	Values      [][]byte                     `json:"values,omitempty"`
+	Evidence    *core.MisbehaviourEvidence   `json:"evidence,omitempty"`
+}
+
+// Auditor 轮询一个log server
+type Auditor struct {
+	cfg    Config
+	client *client.Client
+
+	mu sync.Mutex // 保护告警文件
+}
+
+// New 创建Auditor, 状态文件中的digest作为起点
+func New(cfg Config) (*Auditor, error) {
+	c, err := client.New(cfg.ServerURL, cfg.StatePath, cfg.Hasher)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Tiles > 0 {
+		c.UseTiles(cfg.Tiles)
+	}
+	return &Auditor{cfg: cfg, client: c}, nil
+}
+
+// Trusted 返回最后验证过的digest
+func (a *Auditor) Trusted() *core.Digest {
+	return a.client.Trusted()
+}
+
+// Run 每隔Interval轮询一次, 直到ctx结束。网络错误只记录日志, 不产生告警
+func (a *Auditor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		alert, err := a.Poll()
+		if err != nil {
+			log.Printf("auditor: poll failed: %v", err)
+		} else if alert != nil {
+			log.Printf("auditor: ALERT %s: %s", alert.Kind, alert.Message)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll 执行一次检查。验证失败时返回写入的告警, 其他错误(例如网络错误)返回error。
+// 新的digest在重新计算的epoch都通过之后才保存为信任的digest
+func (a *Auditor) Poll() (*Alert, error) {
+	old := a.client.Trusted()
+	digest, err := a.client.Fetch()
+
+	var inconsistency *client.InconsistencyError
+	var rollback *client.RollbackError
+	switch {
+	case errors.As(err, &inconsistency):
+		return a.alert(&Alert{
+			Kind:        KindInconsistent,
+			Message:     err.Error(),
+			Trusted:     inconsistency.Trusted,
+			Digest:      inconsistency.Digest,
+			Consistency: inconsistency.Proof,
+			Evidence:    a.evidence(inconsistency),
+		})
+	case errors.As(err, &rollback):
+		return a.alert(&Alert{Kind: KindRollback, Message: err.Error(), Trusted: rollback.Trusted, Digest: rollback.Digest})
+	case errors.Is(err, client.ErrInvalidProof):
+		return a.alert(&Alert{Kind: KindInvalidProof, Message: err.Error(), Trusted: old})
+	case err != nil:
+		return nil, err
+	}
+
+	if a.cfg.Recompute {
+		var from uint32
+		if old != nil {
+			from = old.Size
+		}
+		for epoch := from; epoch < digest.Size; epoch++ {
+			alert, err := a.checkEpoch(epoch, digest)
+			if alert != nil || err != nil {
+				return alert, err
+			}
+		}
+	}
+	if err := a.client.Trust(digest); err != nil {
+		return nil, err
+	}
+	a.keepSignature(digest)
+	return nil, nil
+}
+
+// 接受新的digest时把log对它的签名和状态一起保存, 之后log换成另一段历史时, 证据中是log当时的签名
+func (a *Auditor) keepSignature(digest *core.Digest) {
+	if a.cfg.PublicKey == nil {
+		return
+	}
+	if signed := a.client.TrustedSignature(); signed != nil && signed.Digest.Size == digest.Size {
+		return
+	}
+	signed, err := a.signedDigest(digest)
+	if err == nil {
+		err = a.client.KeepSignature(signed)
+	}
+	if err != nil {
+		log.Printf("auditor: no signature for size %d: %v", digest.Size, err)
+	}
+}
+
+// log签名的两个digest作为证据, 没有公钥或者无法获取签名时返回nil。
+// 没有保存信任的digest的签名时使用现在获取的签名, 两个签名的digest都必须与告警中的digest相同
+func (a *Auditor) evidence(e *client.InconsistencyError) *core.MisbehaviourEvidence {
+	if a.cfg.PublicKey == nil {
+		return nil
+	}
+	first := a.client.TrustedSignature()
+	if first == nil || !sameDigest(a.cfg.Hasher, first.Digest, e.Trusted) {
+		var err error
+		if first, err = a.signedDigest(e.Trusted); err != nil {
+			log.Printf("auditor: no signature for the trusted digest: %v", err)
+			return nil
+		}
+	}
+	second, err := a.signedDigest(e.Digest)
+	if err != nil {
+		log.Printf("auditor: no signature for the new digest: %v", err)
+		return nil
+	}
+	return &core.MisbehaviourEvidence{First: first, Second: second, Consistency: e.Proof}
+}
+
+// 获取log对大小为digest.Size的digest的签名, 签名的digest必须是digest
+func (a *Auditor) signedDigest(digest *core.Digest) (*core.SignedDigest, error) {
+	signed, err := a.client.SignedDigestAt(digest.Size, a.cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !sameDigest(a.cfg.Hasher, signed.Digest, digest) {
+		return nil, fmt.Errorf("signed digest of size %d differs from the verified digest", digest.Size)
+	}
+	return signed, nil
+}
+
+func sameDigest(h crypto.Hasher, a *core.Digest, b *core.Digest) bool {
+	return a.Size == b.Size && bytes.Equal(a.BaggedRoot(h), b.BaggedRoot(h))
+}
+
+// 下载epoch的值, 重新计算verkle tree的commitment和content hash, 并与log中的叶子比较
+func (a *Auditor) checkEpoch(epoch uint32, digest *core.Digest) (*Alert, error) {
+	values, err := a.client.Values(epoch)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := a.client.VerifyInclusionAt(epoch, digest)
+	if errors.Is(err, client.ErrInvalidProof) {
+		return a.alert(&Alert{
+			Kind:    KindInvalidProof,
+			Message: fmt.Sprintf("inclusion proof for epoch %d failed", epoch),
+			Digest:  digest,
+			Epoch:   &epoch,
+		})
+	} else if err != nil {
+		return nil, err
+	}
+
+	message := ""
+	tree, err := core.NewKaryTree(a.cfg.K, a.cfg.VerkleDepth, a.cfg.Hasher)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if !tree.AddValue(value) {
+			message = fmt.Sprintf("epoch %d has too many values for the verkle tree", epoch)
+			break
+		}
+	}
+	if message == "" {
+		acc := tree.ComputeAcc()
+		contentHash := core.ComputeLeafHash(a.cfg.Hasher, acc, epoch, proof.Leaf.Header)
+		if !bytes.Equal(acc, proof.Leaf.Acc) || !bytes.Equal(contentHash, proof.Leaf.NodeContentHash) {
+			message = fmt.Sprintf("recomputed commitment of epoch %d does not match the log", epoch)
+		}
+	}
+	if message == "" {
+		return nil, nil
+	}
+	return a.alert(&Alert{
+		Kind:      KindInvalidEpoch,
+		Message:   message,
+		Digest:    digest,
+		Inclusion: proof,
+		Epoch:     &epoch,
+		Values:    values,
+	})
+}
+
+// 把告警追加到告警文件
+func (a *Auditor) alert(alert *Alert) (*Alert, error) {
+	alert.Time = time.Now().UTC()
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return alert, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.cfg.AlertPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return alert, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return alert, err
+	}
+	return alert, f.Sync()
+}
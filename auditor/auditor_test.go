@@ -0,0 +1,263 @@
+package auditor
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+	"MerkleVerkle/server"
+)
+
+func newTestLog(t *testing.T) *ledger.Log {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{
+		Depth:       8,
+		K:           2,
+		VerkleDepth: 2,
+		Hash:        "shake128",
+		HashSize:    32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// 可以在测试中替换后端的server
+type switchHandler struct {
+	mu sync.Mutex
+	h  http.Handler
+}
+
+func (s *switchHandler) set(h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.h = h
+}
+
+func (s *switchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	h := s.h
+	s.mu.Unlock()
+	h.ServeHTTP(w, r)
+}
+
+func newAuditor(t *testing.T, l *ledger.Log) (*Auditor, *switchHandler, string) {
+	sw := &switchHandler{}
+	sw.set(server.New(l))
+	ts := httptest.NewServer(sw)
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	alerts := filepath.Join(dir, "alerts.jsonl")
+	a, err := New(Config{
+		ServerURL:   ts.URL,
+		StatePath:   filepath.Join(dir, "state.json"),
+		AlertPath:   alerts,
+		Hasher:      l.Tree().Hasher(),
+		Recompute:   true,
+		K:           2,
+		VerkleDepth: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, sw, alerts
+}
+
+func readAlerts(t *testing.T, path string) []Alert {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var alerts []Alert
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var alert Alert
+		if err := json.Unmarshal(scanner.Bytes(), &alert); err != nil {
+			t.Fatal(err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func TestAuditorHonestLog(t *testing.T) {
+	l := newTestLog(t)
+	a, _, alerts := newAuditor(t, l)
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 3; i++ {
+			if _, err := l.Append([][]byte{[]byte("a"), []byte("b"), []byte("c")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		alert, err := a.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alert != nil {
+			t.Fatalf("unexpected alert %s: %s", alert.Kind, alert.Message)
+		}
+	}
+	if a.Trusted().Size != 9 {
+		t.Errorf("trusted size %d", a.Trusted().Size)
+	}
+	if got := readAlerts(t, alerts); len(got) != 0 {
+		t.Errorf("%d alerts", len(got))
+	}
+}
+
+func TestAuditorDetectsFork(t *testing.T) {
+	l := newTestLog(t)
+	l.Append([][]byte{[]byte("a")})
+	l.Append([][]byte{[]byte("b")})
+	a, sw, alerts := newAuditor(t, l)
+	if alert, err := a.Poll(); alert != nil || err != nil {
+		t.Fatal(alert, err)
+	}
+
+	// operator换成另一段历史
+	other := newTestLog(t)
+	for i := 0; i < 4; i++ {
+		other.Append([][]byte{[]byte("x")})
+	}
+	sw.set(server.New(other))
+	alert, err := a.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert == nil || alert.Kind != KindInconsistent {
+		t.Fatalf("got %+v", alert)
+	}
+	if a.Trusted().Size != 2 {
+		t.Error("trusted digest should not change")
+	}
+
+	// 回滚
+	sw.set(server.New(newTestLog(t)))
+	if alert, err = a.Poll(); err != nil || alert == nil || alert.Kind != KindRollback {
+		t.Fatalf("got %+v %v", alert, err)
+	}
+
+	got := readAlerts(t, alerts)
+	if len(got) != 2 {
+		t.Fatalf("%d alerts", len(got))
+	}
+	if got[0].Trusted.Size != 2 || got[0].Digest.Size != 4 || got[0].Consistency == nil {
+		t.Error("inconsistency alert is missing evidence")
+	}
+	if got[1].Trusted.Size != 2 || got[1].Digest == nil || got[1].Digest.Size != 0 {
+		t.Error("rollback alert is missing the smaller digest")
+	}
+}
+
+// operator用同一个签名key换成另一段历史, 告警中的证据可以由第三方复核
+func TestAuditorEvidence(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	l, err := ledger.Create(store, newTestLog(t).Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append([][]byte{[]byte("a")})
+	l.Append([][]byte{[]byte("b")})
+	a, sw, _ := newAuditor(t, l)
+	a.cfg.PublicKey = l.PublicKey()
+	if alert, err := a.Poll(); alert != nil || err != nil {
+		t.Fatal(alert, err)
+	}
+	// 没有保存签名的auditor
+	unsigned, unsignedSw, _ := newAuditor(t, l)
+	if alert, err := unsigned.Poll(); alert != nil || err != nil {
+		t.Fatal(alert, err)
+	}
+	unsigned.cfg.PublicKey = l.PublicKey()
+
+	seed, err := store.Get([]byte("signing_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forkStore := storage.NewMemoryStorage()
+	forkStore.Put([]byte("signing_key"), seed)
+	fork, err := ledger.Create(forkStore, l.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		fork.Append([][]byte{[]byte("x")})
+	}
+	sw.set(server.New(fork))
+	unsignedSw.set(server.New(fork))
+
+	// 重启之后仍然使用保存的签名
+	a, err = New(a.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alert, err := a.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert == nil || alert.Evidence == nil {
+		t.Fatalf("got %+v", alert)
+	}
+	// 大小2的root也是大小3的第一个root
+	got, err := core.VerifyMisbehaviour(l.PublicKey(), alert.Evidence)
+	if err != nil || got.Kind != core.ForkedRoot || !got.Conclusive {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	// fork对大小2的签名不是信任的digest, 不能作为证据
+	alert, err = unsigned.Poll()
+	if err != nil || alert == nil || alert.Kind != KindInconsistent {
+		t.Fatalf("got %+v, %v", alert, err)
+	}
+	if alert.Evidence != nil {
+		t.Error("evidence should not use a signature of another digest")
+	}
+}
+
+func TestAuditorDetectsTamperedEpoch(t *testing.T) {
+	l := newTestLog(t)
+	l.Append([][]byte{[]byte("a"), []byte("b")})
+	a, sw, alerts := newAuditor(t, l)
+
+	// epoch的值被篡改, 但digest和proof是真实的
+	honest := server.New(l)
+	sw.set(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, server.PathEpochs+"/") {
+			json.NewEncoder(w).Encode(server.EpochResponse{Epoch: 0, Values: [][]byte{[]byte("a"), []byte("z")}})
+			return
+		}
+		honest.ServeHTTP(w, r)
+	}))
+	alert, err := a.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert == nil || alert.Kind != KindInvalidEpoch || *alert.Epoch != 0 {
+		t.Fatalf("got %+v", alert)
+	}
+	if got := readAlerts(t, alerts); len(got) != 1 || len(got[0].Values) != 2 || got[0].Inclusion == nil {
+		t.Error("alert is missing evidence")
+	}
+	// 重新计算失败的digest不被信任, 下一次轮询仍然检查这个epoch
+	if a.Trusted() != nil {
+		t.Errorf("digest of size %d was trusted", a.Trusted().Size)
+	}
+	if alert, err := a.Poll(); err != nil || alert == nil || alert.Kind != KindInvalidEpoch {
+		t.Errorf("got %+v, %v", alert, err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package bench 测量MerklePT的性能, 用于论文中的图和回归检查。
+// 每组参数建一个新的log, 记录append, 生成和验证proof的平均时间, proof的二进制大小和内存占用。
+package bench
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"time"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+)
+
+// Params 是一组测试参数
+type Params struct {
+	Depth          uint32 // Merkle prefix tree的深度
+	K              uint32 // verkle tree的分叉数
+	VerkleDepth    uint32 // verkle tree的深度
+	LeavesPerEpoch uint32 // 每个epoch的叶子数
+	Epochs         uint32 // append的epoch数, 0表示填满2^Depth个epoch
+}
+
+// Result 是一组参数的测量结果, 时间是每次操作的平均值
+type Result struct {
+	Params
+
+	Append            time.Duration
+	ConsistencyProve  time.Duration
+	ConsistencyVerify time.Duration
+	InclusionProve    time.Duration
+	InclusionVerify   time.Duration
+	LookupProve       time.Duration
+	LookupVerify      time.Duration
+
+	// proof的二进制编码大小(字节)
+	DigestBytes      int
+	ConsistencyBytes int
+	InclusionBytes   int
+	LookupBytes      int
+
+	TreeBytes int    // MerklePT.MemorySize估计的大小
+	HeapBytes uint64 // append前后堆内存的增长
+}
+
+// Sweep 返回所有参数组合
+func Sweep(depths, ks, verkleDepths, leaves []uint32, epochs uint32) []Params {
+	var res []Params
+	for _, d := range depths {
+		for _, k := range ks {
+			for _, vd := range verkleDepths {
+				for _, n := range leaves {
+					res = append(res, Params{Depth: d, K: k, VerkleDepth: vd, LeavesPerEpoch: n, Epochs: epochs})
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (p Params) validate() error {
+	if p.Depth == 0 || p.Depth > 31 {
+		return errors.New("bench: depth must be between 1 and 31")
+	}
+	if p.Epochs > 1<<p.Depth {
+		return fmt.Errorf("bench: %d epochs do not fit in a tree of depth %d", p.Epochs, p.Depth)
+	}
+	if p.K == 0 || p.VerkleDepth == 0 || p.LeavesPerEpoch == 0 {
+		return errors.New("bench: k, verkle depth and leaves per epoch must be positive")
+	}
+	capacity := uint64(1)
+	for i := uint32(0); i < p.VerkleDepth && capacity < uint64(p.LeavesPerEpoch); i++ {
+		capacity *= uint64(p.K)
+	}
+	if capacity < uint64(p.LeavesPerEpoch) {
+		return fmt.Errorf("bench: %d leaves do not fit in a verkle tree with k=%d depth=%d", p.LeavesPerEpoch, p.K, p.VerkleDepth)
+	}
+	return nil
+}
+
+// Run 按参数建一个log并测量, h为nil时使用crypto.Default
+func Run(p Params, h crypto.Hasher) (*Result, error) {
+	if p.Epochs == 0 {
+		p.Epochs = 1 << p.Depth
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = crypto.Default
+	}
+	res := &Result{Params: p}
+
+	values := make([][]byte, p.LeavesPerEpoch)
+	for i := range values {
+		values[i] = []byte(strconv.Itoa(i))
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	m := core.NewMerklePT(p.Depth, h)
+	start := time.Now()
+	for i := uint32(0); i < p.Epochs; i++ {
+		if _, err := m.AppendValues(p.K, p.VerkleDepth, values); err != nil {
+			return nil, err
+		}
+	}
+	res.Append = time.Since(start) / time.Duration(p.Epochs)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		res.HeapBytes = after.HeapAlloc - before.HeapAlloc
+	}
+	res.TreeBytes = m.MemorySize()
+
+	snap := m.Snapshot()
+	digest := snap.Digest()
+	var err error
+	if res.DigestBytes, err = encodedSize(digest); err != nil {
+		return nil, err
+	}
+
+	// 每个epoch都测一次, 取平均值
+	n := time.Duration(p.Epochs)
+	for epoch := uint32(0); epoch < p.Epochs; epoch++ {
+		start = time.Now()
+		inclusion, err := snap.GenerateInclusionProof(epoch)
+		if err != nil {
+			return nil, err
+		}
+		res.InclusionProve += time.Since(start)
+		start = time.Now()
+		if !core.VerifyInclusionProof(h, digest, inclusion) {
+			return nil, fmt.Errorf("bench: inclusion proof for epoch %d failed", epoch)
+		}
+		res.InclusionVerify += time.Since(start)
+
+		pos := epoch % p.LeavesPerEpoch
+		start = time.Now()
+		lookup, err := snap.GenerateLookupProof(epoch, pos)
+		if err != nil {
+			return nil, err
+		}
+		res.LookupProve += time.Since(start)
+		start = time.Now()
+		if !core.VerifyLookupProof(h, p.K, p.VerkleDepth, digest, lookup) {
+			return nil, fmt.Errorf("bench: lookup proof for epoch %d failed", epoch)
+		}
+		res.LookupVerify += time.Since(start)
+
+		// 从大小epoch+1到当前大小的consistency proof
+		old, err := snap.GetOldDigest(epoch + 1)
+		if err != nil {
+			return nil, err
+		}
+		start = time.Now()
+		consistency, err := snap.GenerateConsistencyProof(epoch+1, p.Epochs)
+		if err != nil {
+			return nil, err
+		}
+		res.ConsistencyProve += time.Since(start)
+		start = time.Now()
+		if !core.VerifyExtensionProof(h, old, digest, consistency) {
+			return nil, fmt.Errorf("bench: consistency proof from size %d failed", epoch+1)
+		}
+		res.ConsistencyVerify += time.Since(start)
+
+		// 大小取最大值
+		if err := maxEncodedSize(&res.InclusionBytes, inclusion); err != nil {
+			return nil, err
+		}
+		if err := maxEncodedSize(&res.LookupBytes, lookup); err != nil {
+			return nil, err
+		}
+		if err := maxEncodedSize(&res.ConsistencyBytes, consistency); err != nil {
+			return nil, err
+		}
+	}
+	res.InclusionProve /= n
+	res.InclusionVerify /= n
+	res.LookupProve /= n
+	res.LookupVerify /= n
+	res.ConsistencyProve /= n
+	res.ConsistencyVerify /= n
+	return res, nil
+}
+
+func encodedSize(v encoding.BinaryMarshaler) (int, error) {
+	data, err := v.MarshalBinary()
+	return len(data), err
+}
+
+func maxEncodedSize(max *int, v encoding.BinaryMarshaler) error {
+	size, err := encodedSize(v)
+	if size > *max {
+		*max = size
+	}
+	return err
+}
+
+// CSVHeader 是WriteCSV输出的列, 时间的单位是纳秒
+var CSVHeader = []string{
+	"depth", "k", "verkle_depth", "leaves_per_epoch", "epochs",
+	"append_ns",
+	"consistency_prove_ns", "consistency_verify_ns",
+	"inclusion_prove_ns", "inclusion_verify_ns",
+	"lookup_prove_ns", "lookup_verify_ns",
+	"digest_bytes", "consistency_bytes", "inclusion_bytes", "lookup_bytes",
+	"tree_bytes", "heap_bytes",
+}
+
+func (r *Result) record() []string {
+	u := func(v uint32) string { return strconv.FormatUint(uint64(v), 10) }
+	d := func(v time.Duration) string { return strconv.FormatInt(v.Nanoseconds(), 10) }
+	return []string{
+		u(r.Depth), u(r.K), u(r.VerkleDepth), u(r.LeavesPerEpoch), u(r.Epochs),
+		d(r.Append),
+		d(r.ConsistencyProve), d(r.ConsistencyVerify),
+		d(r.InclusionProve), d(r.InclusionVerify),
+		d(r.LookupProve), d(r.LookupVerify),
+		strconv.Itoa(r.DigestBytes), strconv.Itoa(r.ConsistencyBytes), strconv.Itoa(r.InclusionBytes), strconv.Itoa(r.LookupBytes),
+		strconv.Itoa(r.TreeBytes), strconv.FormatUint(r.HeapBytes, 10),
+	}
+}
+
+// WriteCSV 以CSV格式输出结果, 第一行是CSVHeader
+func WriteCSV(w io.Writer, results []*Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write(r.record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
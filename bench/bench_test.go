@@ -0,0 +1,50 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	params := Sweep([]uint32{3, 4}, []uint32{2, 3}, []uint32{2}, []uint32{4}, 6)
+	if len(params) != 4 {
+		t.Fatalf("%d params", len(params))
+	}
+	var results []*Result
+	for _, p := range params {
+		r, err := Run(p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.InclusionBytes == 0 || r.LookupBytes <= r.InclusionBytes || r.TreeBytes == 0 {
+			t.Errorf("%+v", r)
+		}
+		results = append(results, r)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || len(records[1]) != len(CSVHeader) {
+		t.Errorf("got %d records", len(records))
+	}
+}
+
+func TestRunInvalidParams(t *testing.T) {
+	for _, p := range []Params{
+		{Depth: 2, K: 2, VerkleDepth: 2, LeavesPerEpoch: 4, Epochs: 5},
+		{Depth: 4, K: 2, VerkleDepth: 2, LeavesPerEpoch: 5, Epochs: 1},
+		{Depth: 4, K: 0, VerkleDepth: 2, LeavesPerEpoch: 1, Epochs: 1},
+		{Depth: 0, K: 2, VerkleDepth: 2, LeavesPerEpoch: 1, Epochs: 1},
+	} {
+		if _, err := Run(p, nil); err == nil {
+			t.Errorf("%+v should fail", p)
+		}
+	}
+}
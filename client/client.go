@@ -0,0 +1,647 @@
+// Package client 访问log server并验证所有响应。
+// 客户端在状态文件中保存最后一个验证过的digest, 新的digest只有在consistency proof验证通过后才会被接受。
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/note"
+	"MerkleVerkle/pool"
+	"MerkleVerkle/server"
+	"MerkleVerkle/tile"
+)
+
+var (
+	ErrRollback      = errors.New("client: log returned a smaller digest than the trusted one")
+	ErrInconsistent  = errors.New("client: new digest is not consistent with the trusted digest")
+	ErrInvalidProof  = errors.New("client: proof verification failed")
+	ErrNoTrusted     = errors.New("client: no trusted digest, call Update first")
+	ErrPending       = errors.New("client: submission is not sealed yet")
+	ErrBrokenPromise = errors.New("client: log broke its inclusion promise")
+	ErrNoVerkle      = errors.New("client: verkle tree parameters unknown, call UseVerkle first")
+	ErrNotTrusted    = errors.New("client: signed digest is not the trusted digest")
+)
+
+const maxResponseSize = 64 << 20
+
+// InconsistencyError 记录consistency proof验证失败时的证据, errors.Is(err, ErrInconsistent)为true
+type InconsistencyError struct {
+	Trusted *core.Digest
+	Digest  *core.Digest
+	Proof   *core.MerkleConsistencyProof
+}
+
+func (e *InconsistencyError) Error() string {
+	return fmt.Sprintf("%v: trusted size %d, new size %d", ErrInconsistent, e.Trusted.Size, e.Digest.Size)
+}
+
+func (e *InconsistencyError) Unwrap() error { return ErrInconsistent }
+
+// RollbackError 记录log返回的比信任的digest小的digest, errors.Is(err, ErrRollback)为true
+type RollbackError struct {
+	Trusted *core.Digest
+	Digest  *core.Digest
+}
+
+func (e *RollbackError) Error() string {
+	return fmt.Sprintf("%v: trusted size %d, new size %d", ErrRollback, e.Trusted.Size, e.Digest.Size)
+}
+
+func (e *RollbackError) Unwrap() error { return ErrRollback }
+
+// BrokenPromiseError 是log没有兑现promise的错误, Evidence可以交给第三方用pool.VerifyBrokenPromise复核
+type BrokenPromiseError struct {
+	Evidence     *pool.PromiseEvidence
+	Misbehaviour *core.Misbehaviour
+}
+
+func (e *BrokenPromiseError) Error() string {
+	return fmt.Sprintf("%v: epoch %d, conclusive %v", ErrBrokenPromise, e.Evidence.Promise.Epoch, e.Misbehaviour.Conclusive)
+}
+
+func (e *BrokenPromiseError) Unwrap() error { return ErrBrokenPromise }
+
+// Client 是验证log响应的客户端, 可以并发使用
+type Client struct {
+	baseURL   string
+	hasher    crypto.Hasher
+	statePath string
+	http      *http.Client
+	tiles     int    // 大于0时从这个高度的tile计算proof
+	k         uint32 // verkle tree的参数, 由UseVerkle设置, 验证lookup proof需要
+	depth     uint32
+
+	mu      sync.Mutex
+	trusted *core.Digest
+	signed  *core.SignedDigest // log对trusted的签名, 由KeepSignature保存
+}
+
+// 状态文件的内容。旧的状态文件只有digest
+type state struct {
+	Digest *core.Digest       `json:"digest"`
+	Signed *core.SignedDigest `json:"signed,omitempty"`
+}
+
+// New 创建Client, statePath中已经保存的digest会作为信任的起点, statePath为空时不保存状态
+func New(baseURL string, statePath string, h crypto.Hasher) (*Client, error) {
+	c := &Client{
+		baseURL:   baseURL,
+		hasher:    h,
+		statePath: statePath,
+		http:      http.DefaultClient,
+	}
+	if statePath == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("client: state file %s: %w", statePath, err)
+	}
+	if st.Digest == nil {
+		st.Digest = new(core.Digest)
+		if err := json.Unmarshal(data, st.Digest); err != nil {
+			return nil, fmt.Errorf("client: state file %s: %w", statePath, err)
+		}
+	}
+	c.trusted = st.Digest
+	if st.Signed != nil && c.sameDigest(st.Signed.Digest, st.Digest) {
+		c.signed = st.Signed
+	}
+	return c, nil
+}
+
+// UseVerkle 设置log的verkle tree的分叉数k和深度, 参数必须带外获得, 例如log的配置。
+// LookUp, Resolve和CheckPromise用它从lookup proof的路径计算叶子的位置
+func (c *Client) UseVerkle(k uint32, depth uint32) {
+	c.k, c.depth = k, depth
+}
+
+// UseTiles 让Client从tile.Export导出的静态tile中自己计算inclusion proof和consistency proof,
+// baseURL可以是任何提供导出目录的web server。LookUp需要verkle tree, 仍然要访问log server
+func (c *Client) UseTiles(height int) {
+	c.tiles = height
+}
+
+func (c *Client) tileReader(size uint32) *tile.Reader {
+	return tile.NewReader(func(path string) ([]byte, error) {
+		return c.fetch("/"+path, nil)
+	}, c.tiles, size, c.hasher)
+}
+
+// Trusted 返回最后一个验证过的digest, 没有时为nil
+func (c *Client) Trusted() *core.Digest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.trusted
+}
+
+// TrustedSignature 返回KeepSignature保存的log对信任的digest的签名, 没有时为nil
+func (c *Client) TrustedSignature() *core.SignedDigest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.signed
+}
+
+// KeepSignature 把log对信任的digest的签名和digest一起保存, 重启之后仍然可以作为证据。
+// 签名的digest必须与信任的digest相同, 签名由调用者验证, 例如用SignedDigestAt获取
+func (c *Client) KeepSignature(signed *core.SignedDigest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.trusted == nil || !c.sameDigest(signed.Digest, c.trusted) {
+		return ErrNotTrusted
+	}
+	if err := c.save(c.trusted, signed); err != nil {
+		return err
+	}
+	c.signed = signed
+	return nil
+}
+
+func (c *Client) sameDigest(a *core.Digest, b *core.Digest) bool {
+	return a != nil && a.Size == b.Size && bytes.Equal(a.BaggedRoot(c.hasher), b.BaggedRoot(c.hasher))
+}
+
+// Update 获取log当前的digest。
+// 第一次使用时直接信任(trust on first use), 之后必须能从信任的digest验证consistency proof。
+func (c *Client) Update() (*core.Digest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	digest, err := c.fetchDigest()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.accept(digest); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// Fetch 获取log当前的digest并像Update一样验证, 但是不保存它。
+// 调用者可以先做其他检查(例如重新计算新的epoch), 再用Trust保存
+func (c *Client) Fetch() (*core.Digest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	digest, err := c.fetchDigest()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verify(digest); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// Trust 重新验证Fetch返回的digest仍然是信任的digest的扩展, 然后保存它
+func (c *Client) Trust(digest *core.Digest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.accept(digest)
+}
+
+func (c *Client) fetchDigest() (*core.Digest, error) {
+	var digest core.Digest
+	if err := c.get(server.PathDigest, nil, &digest); err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// 验证digest是否是信任的digest的扩展, 是的话保存它
+func (c *Client) accept(digest *core.Digest) error {
+	if err := c.verify(digest); err != nil {
+		return err
+	}
+	signed := c.signed
+	if signed != nil && !c.sameDigest(signed.Digest, digest) {
+		signed = nil
+	}
+	if err := c.save(digest, signed); err != nil {
+		return err
+	}
+	c.trusted, c.signed = digest, signed
+	return nil
+}
+
+// 验证digest是否是信任的digest的扩展
+func (c *Client) verify(digest *core.Digest) error {
+	trusted := c.trusted
+	switch {
+	case trusted == nil || trusted.Size == 0:
+		// 空的log和任何digest都一致
+		if !digest.WellFormed(c.hasher) {
+			return ErrInvalidProof
+		}
+	case digest.Size < trusted.Size:
+		return &RollbackError{Trusted: trusted, Digest: digest}
+	default:
+		proof, err := c.consistencyProof(trusted.Size, digest.Size)
+		if err != nil {
+			return err
+		}
+		if !core.VerifyExtensionProof(c.hasher, trusted, digest, proof) {
+			return &InconsistencyError{Trusted: trusted, Digest: digest, Proof: proof}
+		}
+	}
+	return nil
+}
+
+func (c *Client) consistencyProof(oldSize uint32, newSize uint32) (*core.MerkleConsistencyProof, error) {
+	if c.tiles > 0 {
+		return core.ProveConsistency(c.tileReader(newSize).NodeHash, oldSize, newSize)
+	}
+	var proof core.MerkleConsistencyProof
+	query := url.Values{
+		"old": {strconv.FormatUint(uint64(oldSize), 10)},
+		"new": {strconv.FormatUint(uint64(newSize), 10)},
+	}
+	if err := c.get(server.PathConsistency, query, &proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// 先写临时文件再rename, 避免崩溃时状态文件损坏
+func (c *Client) save(digest *core.Digest, signed *core.SignedDigest) error {
+	if c.statePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(state{Digest: digest, Signed: signed})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.statePath), filepath.Base(c.statePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.statePath)
+}
+
+// VerifyInclusion 获取第epoch个叶子对信任的digest的存在证明并验证
+func (c *Client) VerifyInclusion(epoch uint32) (*core.MerkleInclusionProof, error) {
+	trusted := c.Trusted()
+	if trusted == nil {
+		return nil, ErrNoTrusted
+	}
+	return c.VerifyInclusionAt(epoch, trusted)
+}
+
+// VerifyInclusionAt 获取第epoch个叶子对digest的存在证明并验证, digest应该是Fetch验证过的
+func (c *Client) VerifyInclusionAt(epoch uint32, digest *core.Digest) (*core.MerkleInclusionProof, error) {
+	proof, err := c.inclusionProof(epoch, digest.Size)
+	if err != nil {
+		return nil, err
+	}
+	if proof.Epoch != epoch || !core.VerifyInclusionProof(c.hasher, digest, proof) {
+		return nil, ErrInvalidProof
+	}
+	return proof, nil
+}
+
+// LeafHeader 返回链式log中第epoch个叶子的header, 没有验证, 调用者要自己检查它与签名的digest一致
+func (c *Client) LeafHeader(epoch uint32, size uint32) (*core.LeafHeader, error) {
+	proof, err := c.inclusionProof(epoch, size)
+	if err != nil {
+		return nil, err
+	}
+	if proof.Leaf.Header == nil {
+		return nil, fmt.Errorf("%w: leaf %d has no header", ErrInvalidProof, epoch)
+	}
+	return proof.Leaf.Header, nil
+}
+
+func (c *Client) inclusionProof(epoch uint32, size uint32) (*core.MerkleInclusionProof, error) {
+	if c.tiles > 0 {
+		r := c.tileReader(size)
+		acc, err := r.LeafAcc(epoch)
+		if err != nil {
+			return nil, err
+		}
+		return core.ProveInclusion(r.NodeHash, epoch, size, acc)
+	}
+	var proof core.MerkleInclusionProof
+	query := url.Values{
+		"epoch": {strconv.FormatUint(uint64(epoch), 10)},
+		"size":  {strconv.FormatUint(uint64(size), 10)},
+	}
+	if err := c.get(server.PathInclusion, query, &proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// LookUp 获取第epoch个verkle tree中第pos个叶子对信任的digest的证明并验证
+func (c *Client) LookUp(epoch uint32, pos uint32) (*core.LookupProof, error) {
+	trusted := c.Trusted()
+	if trusted == nil {
+		return nil, ErrNoTrusted
+	}
+	return c.lookUp(epoch, pos, trusted)
+}
+
+// 获取第epoch个verkle tree中第pos个叶子对digest的证明并验证
+func (c *Client) lookUp(epoch uint32, pos uint32, digest *core.Digest) (*core.LookupProof, error) {
+	if c.k == 0 || c.depth == 0 {
+		return nil, ErrNoVerkle
+	}
+	var proof core.LookupProof
+	query := url.Values{
+		"epoch": {strconv.FormatUint(uint64(epoch), 10)},
+		"pos":   {strconv.FormatUint(uint64(pos), 10)},
+		"size":  {strconv.FormatUint(uint64(digest.Size), 10)},
+	}
+	if err := c.get(server.PathLookup, query, &proof); err != nil {
+		return nil, err
+	}
+	if proof.Epoch != epoch || proof.Position != pos || !core.VerifyLookupProof(c.hasher, c.k, c.depth, digest, &proof) {
+		return nil, ErrInvalidProof
+	}
+	return &proof, nil
+}
+
+// DigestAt 返回时刻t(毫秒)的digest, 也就是t时最新的epoch之后的digest, 以及对信任的digest验证过的TimeProof
+func (c *Client) DigestAt(t uint64) (*core.Digest, *core.TimeProof, error) {
+	trusted := c.Trusted()
+	if trusted == nil {
+		return nil, nil, ErrNoTrusted
+	}
+	var proof core.TimeProof
+	query := url.Values{
+		"t":    {strconv.FormatUint(t, 10)},
+		"size": {strconv.FormatUint(uint64(trusted.Size), 10)},
+	}
+	if err := c.get(server.PathTime, query, &proof); err != nil {
+		return nil, nil, err
+	}
+	if proof.Time != t || !core.VerifyTimeProof(c.hasher, trusted, &proof) {
+		return nil, nil, ErrInvalidProof
+	}
+	var digest core.Digest
+	query = url.Values{"size": {strconv.FormatUint(uint64(proof.DigestSize()), 10)}}
+	if err := c.get(server.PathDigest, query, &digest); err != nil {
+		return nil, nil, err
+	}
+	if !core.VerifyDigestAtTime(c.hasher, trusted, &proof, &digest) {
+		return nil, nil, ErrInvalidProof
+	}
+	return &digest, &proof, nil
+}
+
+// Submit 向pool提交key的新值, 返回log签名的promise, promise.Ticket用于Resolve。
+// server必须由server.NewWithPool创建, promise的签名需要调用方用pool.VerifyPromise验证
+func (c *Client) Submit(key []byte, value []byte) (*pool.Promise, error) {
+	data, err := json.Marshal(server.SubmitRequest{Key: key, Value: value})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Post(c.baseURL+server.PathSubmit, server.ContentTypeJSON, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("client: POST %s: %s: %s", server.PathSubmit, resp.Status, body)
+	}
+	var promise pool.Promise
+	if err := json.Unmarshal(body, &promise); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(promise.Key, key) || !bytes.Equal(promise.ValueHash, c.hasher.Hash(value)) {
+		return nil, fmt.Errorf("client: promise for another entry")
+	}
+	return &promise, nil
+}
+
+// Resolve 获取ticket的提交对信任的digest的lookup proof, 并验证key在ticket的epoch中的值为value。
+// 还没有seal时返回ErrPending, 信任的digest还不包含ticket的epoch时需要先Update
+func (c *Client) Resolve(ticket *pool.Ticket, value []byte) (*core.LookupProof, error) {
+	trusted := c.Trusted()
+	if trusted == nil {
+		return nil, ErrNoTrusted
+	}
+	if ticket.Epoch >= trusted.Size {
+		return nil, ErrPending
+	}
+	if c.k == 0 || c.depth == 0 {
+		return nil, ErrNoVerkle
+	}
+	var proof core.LookupProof
+	query := url.Values{
+		"epoch": {strconv.FormatUint(uint64(ticket.Epoch), 10)},
+		"key":   {base64.RawURLEncoding.EncodeToString(ticket.Key)},
+		"size":  {strconv.FormatUint(uint64(trusted.Size), 10)},
+	}
+	if err := c.get(server.PathTickets, query, &proof); err != nil {
+		return nil, err
+	}
+	if !pool.VerifyTicket(c.hasher, c.k, c.depth, trusted, ticket, value, &proof) {
+		return nil, ErrInvalidProof
+	}
+	return &proof, nil
+}
+
+// CheckPromise 检查log兑现了Submit返回的promise: 对log签名的当前digest获取promise的epoch中key的lookup proof。
+// epoch还没有seal时返回ErrPending, promise没有兑现时返回*BrokenPromiseError
+func (c *Client) CheckPromise(pub ed25519.PublicKey, promise *pool.Promise, value []byte) (*core.LookupProof, error) {
+	if !pool.VerifyPromise(pub, c.hasher, promise, value) {
+		return nil, ErrInvalidProof
+	}
+	if c.k == 0 || c.depth == 0 {
+		return nil, ErrNoVerkle
+	}
+	signed, err := c.SignedDigest(pub)
+	if err != nil {
+		return nil, err
+	}
+	digest := signed.Digest
+	if digest.Size <= promise.Epoch {
+		return nil, ErrPending
+	}
+
+	// 叶子按key排序, 找到key的位置, key不存在时证明它前后的叶子
+	values, err := c.Values(promise.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	pos := sort.Search(len(values), func(i int) bool {
+		key, _, err := pool.DecodeEntry(values[i])
+		return err != nil || bytes.Compare(key, promise.Key) >= 0
+	})
+	ev := &pool.PromiseEvidence{Promise: promise, Digest: signed}
+	if pos < len(values) {
+		if key, _, err := pool.DecodeEntry(values[pos]); pos == 0 || (err == nil && bytes.Equal(key, promise.Key)) {
+			if ev.Lookup, err = c.lookUp(promise.Epoch, uint32(pos), digest); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ev.Lookup == nil && pos > 0 {
+		if ev.Lookup, err = c.lookUp(promise.Epoch, uint32(pos-1), digest); err != nil {
+			return nil, err
+		}
+		if pos < len(values) {
+			if ev.Next, err = c.lookUp(promise.Epoch, uint32(pos), digest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m, err := pool.VerifyBrokenPromise(pub, c.k, c.depth, ev)
+	if errors.Is(err, core.ErrNoMisbehaviour) {
+		return ev.Lookup, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return nil, &BrokenPromiseError{Evidence: ev, Misbehaviour: m}
+}
+
+// Values 获取第epoch个epoch的所有值, 这些值需要调用方自己验证
+func (c *Client) Values(epoch uint32) ([][]byte, error) {
+	body, err := c.fetch(server.PathEpochs+"/"+strconv.FormatUint(uint64(epoch), 10), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp server.EpochResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Epoch != epoch {
+		return nil, fmt.Errorf("client: asked for epoch %d, got %d", epoch, resp.Epoch)
+	}
+	return resp.Values, nil
+}
+
+// Info 获取log的参数和公钥, 多个log的server上baseURL为 .../logs/{name}。
+// 参数没有经过验证, 调用方应该与带外获得的tree ID和公钥比较
+func (c *Client) Info() (*server.InfoResponse, error) {
+	body, err := c.fetch(server.PathInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+	var info server.InfoResponse
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SignedDigest 获取当前的digest和log的签名, 签名必须能用pub验证。
+// 签名只说明log承诺了这个digest, 不检查与信任的digest是否一致
+func (c *Client) SignedDigest(pub ed25519.PublicKey) (*core.SignedDigest, error) {
+	return c.signedDigest(nil, pub)
+}
+
+// SignedDigestAt 获取大小为size的digest和log的签名
+func (c *Client) SignedDigestAt(size uint32, pub ed25519.PublicKey) (*core.SignedDigest, error) {
+	signed, err := c.signedDigest(url.Values{"size": {strconv.FormatUint(uint64(size), 10)}}, pub)
+	if err != nil {
+		return nil, err
+	}
+	if signed.Digest.Size != size {
+		return nil, ErrInvalidProof
+	}
+	return signed, nil
+}
+
+// Checkpoint 获取当前的checkpoint并验证签名, verifiers[0]是log的key, 其他的是可选的witness或者BLS签名
+func (c *Client) Checkpoint(verifiers ...note.Verifier) (*core.Checkpoint, error) {
+	msg, err := c.fetch(server.PathCheckpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	cp, _, err := core.OpenCheckpoint(msg, verifiers...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return cp, nil
+}
+
+func (c *Client) signedDigest(query url.Values, pub ed25519.PublicKey) (*core.SignedDigest, error) {
+	var signed core.SignedDigest
+	if err := c.get(server.PathSigned, query, &signed); err != nil {
+		return nil, err
+	}
+	if !core.VerifySignedDigest(pub, &signed) {
+		return nil, ErrInvalidProof
+	}
+	return &signed, nil
+}
+
+// 以二进制编码请求并解码
+func (c *Client) get(path string, query url.Values, out encoding.BinaryUnmarshaler) error {
+	body, err := c.fetch(path, query)
+	if err != nil {
+		return err
+	}
+	return out.UnmarshalBinary(body)
+}
+
+func (c *Client) fetch(path string, query url.Values) ([]byte, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", server.ContentTypeBinary)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, ErrPending
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("client: GET %s: %s: %s", path, resp.Status, body)
+	}
+	return body, nil
+}
@@ -0,0 +1,435 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/note"
+	"MerkleVerkle/lib/storage"
+	"MerkleVerkle/pool"
+	"MerkleVerkle/server"
+	"MerkleVerkle/tile"
+)
+
+func newTestLog(t *testing.T) (*ledger.Log, *httptest.Server) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{
+		Depth:       8,
+		K:           2,
+		VerkleDepth: 2,
+		Hash:        "shake128",
+		HashSize:    32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.New(l))
+	t.Cleanup(ts.Close)
+	return l, ts
+}
+
+func appendN(t *testing.T, l *ledger.Log, n int) {
+	for i := 0; i < n; i++ {
+		if _, err := l.Append([][]byte{[]byte("a"), []byte("b")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	l, ts := newTestLog(t)
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	c, err := New(ts.URL, state, l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.LookUp(0, 0); err != ErrNoTrusted {
+		t.Errorf("got %v", err)
+	}
+
+	appendN(t, l, 3)
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 4)
+
+	// 重新打开, 从状态文件中的digest继续
+	c, err = New(ts.URL, state, l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UseVerkle(2, 2)
+	if c.Trusted().Size != 3 {
+		t.Fatalf("trusted size %d", c.Trusted().Size)
+	}
+	digest, err := c.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest.Size != 7 {
+		t.Errorf("size %d", digest.Size)
+	}
+
+	if _, err := c.VerifyInclusion(5); err != nil {
+		t.Error(err)
+	}
+	proof, err := c.LookUp(6, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(proof.Value) != "b" {
+		t.Errorf("got %q", proof.Value)
+	}
+}
+
+func TestClientKeepSignature(t *testing.T) {
+	l, ts := newTestLog(t)
+	appendN(t, l, 3)
+	state := filepath.Join(t.TempDir(), "state.json")
+	c, err := New(ts.URL, state, l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	other, err := c.SignedDigestAt(2, l.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.KeepSignature(other); !errors.Is(err, ErrNotTrusted) {
+		t.Errorf("got %v", err)
+	}
+	signed, err := c.SignedDigestAt(3, l.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.KeepSignature(signed); err != nil {
+		t.Fatal(err)
+	}
+
+	// 签名和信任的digest一起保存
+	c, err = New(ts.URL, state, l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.TrustedSignature(); got == nil || !bytes.Equal(got.Signature, signed.Signature) {
+		t.Errorf("got %+v", got)
+	}
+	// 信任新的digest后旧的签名不再保存
+	appendN(t, l, 1)
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if c.TrustedSignature() != nil {
+		t.Error("signature of the old digest was kept")
+	}
+
+	// 只有digest的旧状态文件
+	data, _ := json.Marshal(c.Trusted())
+	os.WriteFile(state, data, 0o644)
+	c, err = New(ts.URL, state, l.Tree().Hasher())
+	if err != nil || c.Trusted().Size != 4 || c.TrustedSignature() != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClientRejectsFork(t *testing.T) {
+	l, ts := newTestLog(t)
+	appendN(t, l, 5)
+	state := filepath.Join(t.TempDir(), "state.json")
+	c, err := New(ts.URL, state, l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个内容不同的log
+	other, otherServer := newTestLog(t)
+	for i := 0; i < 6; i++ {
+		other.Append([][]byte{[]byte("x")})
+	}
+	c.baseURL = otherServer.URL
+	_, err = c.Update()
+	var inconsistency *InconsistencyError
+	if !errors.Is(err, ErrInconsistent) || !errors.As(err, &inconsistency) || inconsistency.Digest.Size != 6 {
+		t.Errorf("got %v", err)
+	}
+
+	// 更小的log
+	_, small := newTestLog(t)
+	c.baseURL = small.URL
+	_, err = c.Update()
+	var rollback *RollbackError
+	if !errors.Is(err, ErrRollback) || !errors.As(err, &rollback) || rollback.Digest.Size != 0 {
+		t.Errorf("got %v", err)
+	}
+	if c.Trusted().Size != 5 {
+		t.Error("trusted digest should not change")
+	}
+}
+
+func TestClientNamedLog(t *testing.T) {
+	r, err := ledger.OpenRegistry(storage.NewMemoryStorage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := r.Create("tenant", ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 2)
+	ts := httptest.NewServer(server.NewMulti(r))
+	defer ts.Close()
+
+	// 先读取参数, 再用带有tree ID的hasher验证
+	base := ts.URL + server.PathLogs + "/tenant"
+	state := filepath.Join(t.TempDir(), "state.json")
+	c, err := New(base, state, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Config.TreeID != l.Config().TreeID {
+		t.Fatalf("got tree id %q", info.Config.TreeID)
+	}
+	h, err := info.Config.Hasher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err = New(base, state, h); err != nil {
+		t.Fatal(err)
+	}
+	c.UseVerkle(info.Config.K, info.Config.VerkleDepth)
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.LookUp(1, 1); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientTiles(t *testing.T) {
+	l, _ := newTestLog(t)
+	dir := t.TempDir()
+	export := func() {
+		snap := l.Tree().Snapshot()
+		if _, err := tile.Export(dir, 2, snap, l.Sign(snap.Digest())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	appendN(t, l, 3)
+	export()
+	ts := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer ts.Close()
+
+	c, err := New(ts.URL, filepath.Join(t.TempDir(), "state.json"), l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UseTiles(2)
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 10)
+	export()
+	digest, err := c.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest.Size != 13 {
+		t.Errorf("got size %d", digest.Size)
+	}
+	for epoch := uint32(0); epoch < 13; epoch++ {
+		if _, err := c.VerifyInclusion(epoch); err != nil {
+			t.Errorf("epoch %d: %v", epoch, err)
+		}
+	}
+	if signed, err := c.SignedDigest(l.PublicKey()); err != nil || signed.Digest.Size != 13 {
+		t.Errorf("signed digest: %v", err)
+	}
+}
+
+func TestClientCheckpoint(t *testing.T) {
+	l, ts := newTestLog(t)
+	appendN(t, l, 3)
+	c, err := New(ts.URL, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := note.NewVerifier(l.VerifierKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := c.Checkpoint(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := l.Tree().GetOldDigest(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Size != 3 || !cp.Matches(l.Tree().Hasher(), want) {
+		t.Errorf("got %+v", cp)
+	}
+
+	other, _ := ledger.Create(storage.NewMemoryStorage(), l.Config())
+	wrong, _ := note.NewVerifier(other.VerifierKey())
+	if _, err := c.Checkpoint(wrong); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClientDigestAt(t *testing.T) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32, Chained: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.New(l))
+	defer ts.Close()
+	for _, at := range []uint64{1000, 2000, 3000} {
+		if _, err := l.AppendWithHeader([][]byte{[]byte("a")}, at, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := New(ts.URL, "", l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		t    uint64
+		size uint32
+	}{{1000, 1}, {2500, 2}, {3000, 3}, {9000, 3}}
+	for _, table := range tables {
+		digest, proof, err := c.DigestAt(table.t)
+		if err != nil {
+			t.Errorf("%d: %v", table.t, err)
+			continue
+		}
+		want, err := l.Tree().GetOldDigest(table.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest.Size != table.size || proof.Epoch.Epoch != table.size-1 || !bytes.Equal(digest.BaggedRoot(l.Tree().Hasher()), want.BaggedRoot(l.Tree().Hasher())) {
+			t.Errorf("%d: got digest of size %d", table.t, digest.Size)
+		}
+	}
+	if _, _, err := c.DigestAt(999); err == nil {
+		t.Error("time before the first epoch should fail")
+	}
+}
+
+func TestClientSubmit(t *testing.T) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := pool.New(l, storage.NewMemoryStorage(), pool.Config{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.NewWithPool(l, p))
+	defer ts.Close()
+	c, err := New(ts.URL, "", l.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UseVerkle(2, 2)
+
+	promise, err := c.Submit([]byte("alice"), []byte("key-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pool.VerifyPromise(l.PublicKey(), l.Tree().Hasher(), promise, []byte("key-1")) {
+		t.Error("promise signature failed")
+	}
+	if _, err := c.Submit([]byte("alice"), []byte("key-2")); err == nil {
+		t.Error("different pending value should fail")
+	}
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Resolve(&promise.Ticket, []byte("key-1")); !errors.Is(err, ErrPending) {
+		t.Errorf("got %v", err)
+	}
+
+	// 第二个提交seal了batch
+	if _, err := c.Submit([]byte("bob"), []byte("key-3")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := c.Resolve(&promise.Ticket, []byte("key-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Epoch != 0 {
+		t.Errorf("epoch %d", proof.Epoch)
+	}
+	if _, err := c.Resolve(&promise.Ticket, []byte("key-2")); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClientCheckPromise(t *testing.T) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := pool.New(l, storage.NewMemoryStorage(), pool.Config{})
+	ts := httptest.NewServer(server.NewWithPool(l, p))
+	defer ts.Close()
+	c, _ := New(ts.URL, "", l.Tree().Hasher())
+	c.UseVerkle(2, 2)
+
+	kept, err := c.Submit([]byte("b"), []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CheckPromise(l.PublicKey(), kept, []byte("value")); !errors.Is(err, ErrPending) {
+		t.Errorf("got %v", err)
+	}
+	if _, _, err := p.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CheckPromise(l.PublicKey(), kept, []byte("value")); err != nil {
+		t.Error(err)
+	}
+	if _, err := c.CheckPromise(l.PublicKey(), kept, []byte("other")); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("got %v", err)
+	}
+
+	// log没有把提交放入promise的epoch
+	broken, err := c.Submit([]byte("b"), []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append([][]byte{pool.EncodeEntry([]byte("a"), nil), pool.EncodeEntry([]byte("c"), nil)}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CheckPromise(l.PublicKey(), broken, []byte("value"))
+	var bpe *BrokenPromiseError
+	if !errors.As(err, &bpe) || !bpe.Misbehaviour.Conclusive {
+		t.Fatalf("got %v", err)
+	}
+	if m, err := pool.VerifyBrokenPromise(l.PublicKey(), 2, 2, bpe.Evidence); err != nil || !m.Conclusive {
+		t.Errorf("got %+v, %v", m, err)
+	}
+}
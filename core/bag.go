@@ -0,0 +1,51 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+// bagged root的domain, 与树中的hash区分
+const baggedRootContext = "MerkleVerkle/bagged-root"
+
+// BaggedRootSize 是bagged root的长度, 与log的hash函数无关
+const BaggedRootSize = sha256.Size
+
+// FoldRoots 把digest的roots从右向左用HashChildren合并成一个hash, 空树是h.Hash()。
+// 对rfc6962的log它就是RFC 9162的root hash, RFCTreeHead和checkpoint使用它。
+// h是log的hash函数(带tree ID时混入tree ID), 长度为h.Size(), 结果不包含Size
+func (dg *Digest) FoldRoots(h crypto.Hasher) []byte {
+	if len(dg.Roots) == 0 {
+		return h.Hash()
+	}
+	root := dg.Roots[len(dg.Roots)-1]
+	for i := len(dg.Roots) - 2; i >= 0; i-- {
+		root = HashChildren(h, dg.Roots[i], root)
+	}
+	return root
+}
+
+// BaggedRoot 是digest的32字节commitment:
+// SHA-256(context | HashID | HashSize | Size | len(Acc) | Acc | FoldRoots(h)), 整数为小端序。
+// 链式叶子的Prev用它, 外层固定为SHA-256, 所以header的布局不随log的hash长度变化;
+// roots用h折叠, 所以混入了tree ID。Roots的个数由Size决定, 所以bagged root唯一地确定了digest
+func (dg *Digest) BaggedRoot(h crypto.Hasher) []byte {
+	s := sha256.New()
+	s.Write([]byte(baggedRootContext))
+	s.Write([]byte{byte(dg.HashID)})
+	s.Write(binary.LittleEndian.AppendUint32(nil, dg.HashSize))
+	s.Write(binary.LittleEndian.AppendUint32(nil, dg.Size))
+	s.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(dg.Acc))))
+	s.Write(dg.Acc)
+	s.Write(dg.FoldRoots(h))
+	return s.Sum(nil)
+}
+
+// VerifyBaggedRoot 检查展开的digest由h计算得到, 并且它的bagged root是root。
+// 只保存了bagged root的一方可以用它检查服务器给出的digest, 之后的proof仍然对展开的digest验证
+func VerifyBaggedRoot(h crypto.Hasher, dg *Digest, root []byte) bool {
+	return dg.WellFormed(h) && bytes.Equal(dg.BaggedRoot(h), root)
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+func TestBaggedRoot(t *testing.T) {
+	m := createTestingTree(11, 4)
+	h := m.Hasher()
+	seen := make(map[string]uint32)
+	for size := uint32(0); size <= 11; size++ {
+		dg := m.mustOldDigest(size)
+		root := dg.BaggedRoot(h)
+		if len(root) != BaggedRootSize {
+			t.Fatalf("size %d: root is %d bytes", size, len(root))
+		}
+		if other, ok := seen[string(root)]; ok {
+			t.Errorf("sizes %d and %d have the same root", other, size)
+		}
+		seen[string(root)] = size
+		if !VerifyBaggedRoot(h, dg, root) {
+			t.Errorf("size %d: root should verify", size)
+		}
+		// checkpoint签名的是RFC的root, 不是bagged root
+		if !bytes.Equal(dg.FoldRoots(h), NewCheckpoint("example.com/log", h, dg).Hash) {
+			t.Errorf("size %d: checkpoint signs another root", size)
+		}
+	}
+
+	dg := m.mustOldDigest(11)
+	root := dg.BaggedRoot(h)
+	// 交换roots, 大小不同但是roots个数相同, 或者acc不同
+	swapped := *dg
+	swapped.Roots = [][]byte{dg.Roots[1], dg.Roots[0], dg.Roots[2]}
+	if VerifyBaggedRoot(h, &swapped, root) {
+		t.Error("swapped roots should not verify")
+	}
+	size := *dg
+	size.Size = 13
+	if bytes.Equal(size.BaggedRoot(h), root) {
+		t.Error("bagged root should commit to the size")
+	}
+	acc := *dg
+	acc.Acc = []byte("2")
+	if bytes.Equal(acc.BaggedRoot(h), root) {
+		t.Error("bagged root should commit to the acc")
+	}
+	sha, _ := crypto.NewHasher(crypto.SHA256, 32)
+	if VerifyBaggedRoot(sha, dg, root) {
+		t.Error("digest should not verify with another hasher")
+	}
+
+	// roots用log的hash函数折叠, 所以混入了tree ID
+	named, err := crypto.WithTreeID(h, []byte("tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dg.BaggedRoot(named), root) {
+		t.Error("bagged root should depend on the tree id")
+	}
+
+	// 检查过bagged root的展开形式仍然可以验证proof
+	old := m.mustOldDigest(5)
+	if !VerifyBaggedRoot(h, old, old.BaggedRoot(h)) || !VerifyExtensionProof(h, old, dg, m.mustConsistencyProof(5, 11)) {
+		t.Error("consistency proof should verify against the expanded digests")
+	}
+}
+
+func TestBaggedRootSize(t *testing.T) {
+	// bagged root的长度与log的hash长度无关
+	h, err := crypto.NewHasher(crypto.SHAKE128, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewChainedMerklePT(3, h)
+	for i := uint64(1); i <= 3; i++ {
+		if _, err := m.AppendTreeWithHeader(newTestingKaryTree(t, 2, 2, h), i, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dg := m.mustOldDigest(3)
+	if root := dg.BaggedRoot(h); len(root) != BaggedRootSize {
+		t.Errorf("root is %d bytes", len(root))
+	}
+	proof, err := m.GenerateInclusionProof(2, 3)
+	if err != nil || !VerifyChainLink(h, m.mustOldDigest(2), dg, proof) {
+		t.Errorf("chain link failed: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+var ErrNotChained = errors.New("core: leaf headers need a chained tree")
+
+// MaxMetadataSize 是LeafHeader中Metadata的最大长度
+const MaxMetadataSize = maxFieldLen
+
+// 链式叶子的domain, 与ComputeContentHash的输入区分
+const leafHeaderContext = "MerkleVerkle/chained-leaf"
+
+// LeafHeader 是链式MerklePT中叶子额外commit的内容。
+// Prev是添加这个叶子之前的digest(大小为epoch)的bagged root, 所以一个叶子确定了之前的全部历史
+type LeafHeader struct {
+	Prev      []byte
+	Timestamp uint64 // 毫秒, 0表示没有
+	Metadata  []byte // 可选, 由log决定内容
+}
+
+func (hd *LeafHeader) valid() bool {
+	return len(hd.Prev) == BaggedRootSize && len(hd.Metadata) <= MaxMetadataSize
+}
+
+// ComputeLeafHash 计算叶子的hash, header为nil时与ComputeContentHash相同
+func ComputeLeafHash(h crypto.Hasher, acc []byte, pos uint32, header *LeafHeader) []byte {
+	if header == nil {
+		return ComputeContentHash(h, acc, pos)
+	}
+	posAsByte := binary.LittleEndian.AppendUint32(nil, pos)
+	ts := binary.LittleEndian.AppendUint64(nil, header.Timestamp)
+	metaLen := binary.LittleEndian.AppendUint32(nil, uint32(len(header.Metadata)))
+	ms := [][]byte{acc, posAsByte, []byte(leafHeaderContext), header.Prev, ts, metaLen, header.Metadata}
+
+	if th, ok := h.(crypto.TreeHasher); ok {
+		return th.HashLeaf(ms...)
+	}
+	return h.Hash(ms...)
+}
+
+// NewChainedMerklePT 返回链式的MerklePT, 每个叶子都commit前一个digest的bagged root
+func NewChainedMerklePT(depth uint32, h crypto.Hasher) *MerklePT {
+	m := NewMerklePT(depth, h)
+	m.chained = true
+	return m
+}
+
+// Chained 返回MerklePT的叶子是否是链式的
+func (m *MerklePT) Chained() bool {
+	return m.chained
+}
+
+// AppendTreeWithHeader 与AppendTree相同, 同时在叶子中commit timestamp和metadata, 只能用于链式的MerklePT
+func (m *MerklePT) AppendTreeWithHeader(tree *KaryTree, timestamp uint64, metadata []byte) (uint32, error) {
+	if !m.chained {
+		return 0, ErrNotChained
+	}
+	if len(metadata) > MaxMetadataSize {
+		return 0, ErrFieldTooLarge
+	}
+	return m.appendTree(tree, timestamp, metadata)
+}
+
+// LeafHeader 返回第epoch个叶子的header, 不是链式的MerklePT时返回nil
+func (s *Snapshot) LeafHeader(epoch uint32) (*LeafHeader, error) {
+	if epoch >= s.Size {
+		return nil, ErrInvalidSize
+	}
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return s.m.getLeafNode(epoch).(*LeafNode).header, nil
+}
+
+// VerifyChainLink 验证proof是digest中第prev.Size个叶子, 并且它的Prev是prev的bagged root。
+// 通过时prev一定是digest之前的状态, 不需要consistency proof
+func VerifyChainLink(h crypto.Hasher, prev *Digest, digest *Digest, proof *MerkleInclusionProof) bool {
+	if proof.Leaf.Header == nil || proof.Epoch != prev.Size || !prev.WellFormed(h) {
+		return false
+	}
+	if !VerifyInclusionProof(h, digest, proof) {
+		return false
+	}
+	return bytes.Equal(proof.Leaf.Header.Prev, prev.BaggedRoot(h))
+}
+
+// Chained 返回snapshot的叶子是否是链式的
+func (s *Snapshot) Chained() bool {
+	return s.m.chained
+}
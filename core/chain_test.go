@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+func createChainedTree(t *testing.T, size int) *MerklePT {
+	m := NewChainedMerklePT(4, nil)
+	for i := 0; i < size; i++ {
+		tree := newTestingKaryTree(t, 2, 2, m.Hasher())
+		tree.AddValue([]byte(fmt.Sprint("v", i)))
+		if _, err := m.AppendTreeWithHeader(tree, uint64(1000+i), []byte(fmt.Sprint("meta", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestChainedMerklePT(t *testing.T) {
+	h := crypto.Default
+	m := createChainedTree(t, 11)
+	digest := m.mustOldDigest(11)
+
+	for epoch := uint32(0); epoch < 11; epoch++ {
+		proof, err := m.GenerateInclusionProof(epoch, 11)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := proof.Leaf.Header
+		if header == nil || header.Timestamp != uint64(1000+epoch) || string(header.Metadata) != fmt.Sprint("meta", epoch) {
+			t.Fatalf("epoch %d: got header %+v", epoch, header)
+		}
+		if !VerifyInclusionProof(h, digest, proof) {
+			t.Errorf("epoch %d: inclusion proof failed", epoch)
+		}
+		// 一个叶子确定了之前的digest
+		if !VerifyChainLink(h, m.mustOldDigest(epoch), digest, proof) {
+			t.Errorf("epoch %d: chain link failed", epoch)
+		}
+		if epoch > 0 && VerifyChainLink(h, m.mustOldDigest(epoch-1), digest, proof) {
+			t.Errorf("epoch %d: chain link to an older digest should fail", epoch)
+		}
+	}
+
+	// 去掉或修改header后proof失败
+	proof, _ := m.GenerateInclusionProof(6, 11)
+	stripped := *proof
+	stripped.Leaf.Header = nil
+	if VerifyInclusionProof(h, digest, &stripped) {
+		t.Error("proof without header should fail")
+	}
+	changed := *proof
+	changed.Leaf.Header = &LeafHeader{Prev: proof.Leaf.Header.Prev, Timestamp: 1}
+	if VerifyInclusionProof(h, digest, &changed) {
+		t.Error("proof with a changed header should fail")
+	}
+
+	// 普通的MerklePT不能添加header
+	plain := NewMerklePT(4, nil)
+	if _, err := plain.AppendTreeWithHeader(newTestingKaryTree(t, 2, 2, nil), 1, nil); !errors.Is(err, ErrNotChained) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestLeafHeaderEncoding(t *testing.T) {
+	m := createChainedTree(t, 5)
+	proof, _ := m.GenerateInclusionProof(3, 5)
+
+	data, _ := proof.MarshalBinary()
+	var decoded MerkleInclusionProof
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyInclusionProof(crypto.Default, m.mustOldDigest(5), &decoded) {
+		t.Error("decoded binary proof failed")
+	}
+
+	data, _ = json.Marshal(proof)
+	decoded = MerkleInclusionProof{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyInclusionProof(crypto.Default, m.mustOldDigest(5), &decoded) {
+		t.Error("decoded JSON proof failed")
+	}
+	if !bytes.Contains(data, []byte(`"header"`)) {
+		t.Errorf("got %s", data)
+	}
+
+	// 没有header的叶子后面不能有多余的字节, 有标记的叶子必须有header
+	leaf, _ := (&LeafHash{NodeContentHash: []byte("content")}).MarshalBinary()
+	if err := (&LeafHash{}).UnmarshalBinary(append(leaf, 0)); !errors.Is(err, ErrTrailingBytes) {
+		t.Errorf("got %v", err)
+	}
+	marked := append([]byte{encodingVersion, leafHeaderMarker}, leaf[1:]...)
+	if err := (&LeafHash{}).UnmarshalBinary(marked); !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestForkedChainEvidence(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	a, b := NewChainedMerklePT(4, nil), NewChainedMerklePT(4, nil)
+	for i := 0; i < 8; i++ {
+		value := []byte(fmt.Sprint("v", i))
+		_, _ = a.AppendValues(2, 2, [][]byte{value})
+		if i == 1 {
+			value = []byte("forked")
+		}
+		_, _ = b.AppendValues(2, 2, [][]byte{value})
+	}
+	inclusion, _ := b.GenerateInclusionProof(3, 8)
+
+	// 没有共同的root, 只有一个叶子的proof
+	ev := &MisbehaviourEvidence{
+		First:           SignDigest(key, nil, a.mustOldDigest(3)),
+		Second:          SignDigest(key, nil, b.mustOldDigest(8)),
+		SecondInclusion: inclusion,
+	}
+	got, err := VerifyMisbehaviour(pub, ev)
+	if err != nil || got.Kind != ForkedChain || !got.Conclusive {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	ev.First = SignDigest(key, nil, b.mustOldDigest(3))
+	if _, err := VerifyMisbehaviour(pub, ev); !errors.Is(err, ErrNoMisbehaviour) {
+		t.Errorf("got %v", err)
+	}
+	wrong, _ := b.GenerateInclusionProof(4, 8)
+	ev.SecondInclusion = wrong
+	if _, err := VerifyMisbehaviour(pub, ev); !errors.Is(err, ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/note"
+)
+
+var ErrInvalidCheckpoint = errors.New("core: invalid checkpoint")
+
+// Checkpoint 是C2SP tlog-checkpoint格式的digest, 作为signed note的文本:
+//
+//	<origin>
+//	<size>
+//	<base64 root hash>
+//	[extension lines]
+//
+// root hash是RFCTreeHead的root, witness可以用RFC的consistency proof检查两个checkpoint
+type Checkpoint struct {
+	Origin     string
+	Size       uint64
+	Hash       []byte
+	Extensions []string // root hash之后的行, 没有时为nil
+}
+
+// NewCheckpoint 返回digest的checkpoint, h是log使用的hash函数
+func NewCheckpoint(origin string, h crypto.Hasher, dg *Digest) *Checkpoint {
+	return &Checkpoint{Origin: origin, Size: uint64(dg.Size), Hash: dg.RFCTreeHead(h).RootHash}
+}
+
+// Matches 检查checkpoint是否与digest对应
+func (c *Checkpoint) Matches(h crypto.Hasher, dg *Digest) bool {
+	head := dg.RFCTreeHead(h)
+	return c.Size == head.TreeSize && bytes.Equal(c.Hash, head.RootHash)
+}
+
+// Text 返回note的文本, 以换行结尾
+func (c *Checkpoint) Text() (string, error) {
+	if c.Origin == "" || strings.ContainsAny(c.Origin, "\n") || len(c.Hash) == 0 {
+		return "", ErrInvalidCheckpoint
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%d\n%s\n", c.Origin, c.Size, base64.StdEncoding.EncodeToString(c.Hash))
+	for _, ext := range c.Extensions {
+		if ext == "" || strings.Contains(ext, "\n") {
+			return "", ErrInvalidCheckpoint
+		}
+		b.WriteString(ext + "\n")
+	}
+	return b.String(), nil
+}
+
+// ParseCheckpoint 解析note的文本, size必须是规范的十进制
+func ParseCheckpoint(text string) (*Checkpoint, error) {
+	if !strings.HasSuffix(text, "\n") {
+		return nil, ErrInvalidCheckpoint
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) < 3 || lines[0] == "" {
+		return nil, ErrInvalidCheckpoint
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil || strconv.FormatUint(size, 10) != lines[1] {
+		return nil, fmt.Errorf("%w: size %q", ErrInvalidCheckpoint, lines[1])
+	}
+	hash, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("%w: root hash %q", ErrInvalidCheckpoint, lines[2])
+	}
+	c := &Checkpoint{Origin: lines[0], Size: size, Hash: hash}
+	for _, ext := range lines[3:] {
+		if ext == "" {
+			return nil, ErrInvalidCheckpoint
+		}
+		c.Extensions = append(c.Extensions, ext)
+	}
+	return c, nil
+}
+
+// OpenCheckpoint 验证signed note并解析checkpoint, origin必须与第一个verifier的名字相同。
+// 其他verifier(例如witness或者BLS签名)的签名是可选的, 不认识的签名被忽略
+func OpenCheckpoint(msg []byte, verifiers ...note.Verifier) (*Checkpoint, *note.Note, error) {
+	if len(verifiers) == 0 {
+		return nil, nil, errors.New("core: no checkpoint verifier")
+	}
+	n, err := note.Open(msg, verifiers...)
+	if err != nil {
+		return nil, nil, err
+	}
+	signed := false
+	for _, sig := range n.Sigs {
+		if sig.Name == verifiers[0].Name() && sig.Hash == verifiers[0].KeyHash() {
+			signed = true
+		}
+	}
+	if !signed {
+		return nil, nil, fmt.Errorf("%w from %s", note.ErrNoSignature, verifiers[0].Name())
+	}
+	c, err := ParseCheckpoint(n.Text)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Origin != verifiers[0].Name() {
+		return nil, nil, fmt.Errorf("%w: origin %q", ErrInvalidCheckpoint, c.Origin)
+	}
+	return c, n, nil
+}
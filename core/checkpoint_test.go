@@ -0,0 +1,62 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+
+	"MerkleVerkle/lib/note"
+)
+
+func TestCheckpoint(t *testing.T) {
+	h := rfcHasher(t)
+	m := NewMerklePT(4, h)
+	for i := 0; i < 5; i++ {
+		m.Append(3, 3, 27)
+	}
+	dg := m.mustOldDigest(5)
+	c := NewCheckpoint("example.com/log", h, dg)
+	c.Extensions = []string{"extra data"}
+	text, err := c.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(text, "\n"); len(lines) != 5 || lines[0] != "example.com/log" || lines[1] != "5" {
+		t.Fatalf("got %q", text)
+	}
+	parsed, err := ParseCheckpoint(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Matches(h, dg) || parsed.Matches(h, m.mustOldDigest(4)) || len(parsed.Extensions) != 1 {
+		t.Errorf("got %+v", parsed)
+	}
+	for _, bad := range []string{"origin\n05\nAAAA\n", "origin\n5\n!!\n", "origin\n5\n", "origin\n5\nAAAA"} {
+		if _, err := ParseCheckpoint(bad); !errors.Is(err, ErrInvalidCheckpoint) {
+			t.Errorf("%q: got %v", bad, err)
+		}
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	signer, _ := note.NewEd25519Signer("example.com/log", key)
+	msg, err := note.Sign(text, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := note.NewEd25519Verifier("example.com/log", key.Public().(ed25519.PublicKey))
+	if got, _, err := OpenCheckpoint(msg, v); err != nil || !got.Matches(h, dg) {
+		t.Errorf("open: %v", err)
+	}
+	// origin与key name不同
+	other, _ := note.NewEd25519Signer("other", key)
+	otherMsg, _ := note.Sign(text, other)
+	ov, _ := note.NewEd25519Verifier("other", key.Public().(ed25519.PublicKey))
+	if _, _, err := OpenCheckpoint(otherMsg, ov); !errors.Is(err, ErrInvalidCheckpoint) {
+		t.Errorf("got %v", err)
+	}
+	// 只有witness的签名不够
+	if _, _, err := OpenCheckpoint(otherMsg, v, ov); !errors.Is(err, note.ErrNoSignature) {
+		t.Errorf("got %v", err)
+	}
+}
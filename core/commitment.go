@@ -0,0 +1,35 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+// OpeningSize 是hiding commitment的opening长度
+const OpeningSize = 32
+
+// NewOpening 生成一个随机的opening, 每个叶子每个epoch使用不同的opening
+func NewOpening() ([]byte, error) {
+	opening := make([]byte, OpeningSize)
+	if _, err := rand.Read(opening); err != nil {
+		return nil, err
+	}
+	return opening, nil
+}
+
+// Commit 计算value的hiding commitment hash(opening, value)。
+// opening是随机的, 所以不知道opening就无法通过穷举value打开commitment;
+// 和ComputeContentHash一样, 叶子只保存commitment, lookup proof只给被查询的叶子附上opening和value。
+func Commit(h crypto.Hasher, opening []byte, value []byte) []byte {
+	return h.Hash([]byte("core/commit"), opening, value)
+}
+
+// VerifyCommitment 检查commitment是否是value和opening的commitment
+func VerifyCommitment(h crypto.Hasher, commitment []byte, opening []byte, value []byte) bool {
+	if len(opening) != OpeningSize {
+		return false
+	}
+	return bytes.Equal(Commit(h, opening, value), commitment)
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+func TestCommit(t *testing.T) {
+	h := crypto.Default
+	o1, err := NewOpening()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o2, _ := NewOpening()
+	if bytes.Equal(o1, o2) {
+		t.Fatal("openings should be random")
+	}
+
+	c := Commit(h, o1, []byte("pk"))
+	if !VerifyCommitment(h, c, o1, []byte("pk")) {
+		t.Error("valid opening rejected")
+	}
+	if VerifyCommitment(h, c, o2, []byte("pk")) || VerifyCommitment(h, c, o1, []byte("pk2")) || VerifyCommitment(h, c, o1[:16], []byte("pk")) {
+		t.Error("wrong opening accepted")
+	}
+	// 相同的值使用不同的opening, commitment不同
+	if bytes.Equal(c, Commit(h, o2, []byte("pk"))) {
+		t.Error("commitment does not hide repeated values")
+	}
+
+	// commitment作为verkle tree的叶子, lookup proof只揭示被查询的叶子
+	tree := newTestingKaryTree(t, 2, 2, h)
+	tree.AddValue(c)
+	tree.AddValue(Commit(h, o2, []byte("other")))
+	acc := tree.ComputeAcc()
+	value, path, err := tree.GenerateLookupProof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyLookupPath(h, 2, 2, 0, value, path, acc) || !VerifyCommitment(h, value, o1, []byte("pk")) {
+		t.Error("committed leaf does not verify")
+	}
+}
@@ -0,0 +1,374 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+// 二进制编码的版本号，所有对象的第一个字节
+const encodingVersion = 1
+
+// 有header的LeafHash以这个字节开头。没有header的LeafHash与加入header之前的布局相同,
+// 以NodeContentHash的4字节长度开头, 长度不超过maxFieldLen, 第一个字节不会是0xff
+const leafHeaderMarker = 0xff
+
+const (
+	maxFieldLen    = 1 << 24 // 单个字段的最大长度
+	maxListLen     = 64      // roots/siblings的最大个数, 树的深度不超过32
+	maxChildrenLen = 1 << 16 // verkle tree中一个节点的最大子节点个数
+)
+
+var (
+	ErrEncodingVersion = errors.New("core: unsupported encoding version")
+	ErrTruncated       = errors.New("core: truncated encoding")
+	ErrTrailingBytes   = errors.New("core: trailing bytes after encoding")
+	ErrFieldTooLarge   = errors.New("core: encoded length too large")
+	ErrInvalidEncoding = errors.New("core: invalid encoding")
+)
+
+// 编码: 定长整数使用大端序, []byte前面加4字节长度
+type encoder struct {
+	buf []byte
+}
+
+func newEncoder() *encoder {
+	return &encoder{buf: []byte{encodingVersion}}
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uint32(uint32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// 解码时第一个错误之后的读取全部返回零值
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func newDecoder(data []byte) *decoder {
+	d := &decoder{buf: data}
+	if d.uint8() != encodingVersion && d.err == nil {
+		d.err = ErrEncodingVersion
+	}
+	return d
+}
+
+func (d *decoder) uint8() uint8 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 1 {
+		d.err = ErrTruncated
+		return 0
+	}
+	v := d.buf[0]
+	d.buf = d.buf[1:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 4 {
+		d.err = ErrTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.buf)
+	d.buf = d.buf[4:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 8 {
+		d.err = ErrTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.buf)
+	d.buf = d.buf[8:]
+	return v
+}
+
+// 长度为0时返回nil, 保证编码->解码->编码的结果不变
+func (d *decoder) bytes() []byte {
+	n := d.uint32()
+	if d.err != nil {
+		return nil
+	}
+	if n > maxFieldLen {
+		d.err = ErrFieldTooLarge
+		return nil
+	}
+	if uint32(len(d.buf)) < n {
+		d.err = ErrTruncated
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.buf[:n])
+	d.buf = d.buf[n:]
+	return b
+}
+
+// 列表的长度
+func (d *decoder) count() int {
+	return d.countMax(maxListLen)
+}
+
+func (d *decoder) countMax(max uint32) int {
+	n := d.uint32()
+	if d.err == nil && n > max {
+		d.err = ErrFieldTooLarge
+	}
+	return int(n)
+}
+
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		d.err = ErrTrailingBytes
+	}
+	return d.err
+}
+
+// MarshalBinary 编码Sibling
+func (s *Sibling) MarshalBinary() ([]byte, error) {
+	e := newEncoder()
+	s.encode(e)
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码Sibling
+func (s *Sibling) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res Sibling
+	res.decode(d)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*s = res
+	return nil
+}
+
+func (s *Sibling) encode(e *encoder) {
+	e.bytes(s.Hash)
+	e.bytes(s.Acc)
+}
+
+func (s *Sibling) decode(d *decoder) {
+	s.Hash = d.bytes()
+	s.Acc = d.bytes()
+}
+
+// MarshalBinary 编码MerkleConsistencyProof
+func (p *MerkleConsistencyProof) MarshalBinary() ([]byte, error) {
+	e := newEncoder()
+	e.uint32(uint32(len(p.Siblings)))
+	for i := range p.Siblings {
+		p.Siblings[i].encode(e)
+	}
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码MerkleConsistencyProof
+func (p *MerkleConsistencyProof) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res MerkleConsistencyProof
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		var s Sibling
+		s.decode(d)
+		res.Siblings = append(res.Siblings, s)
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*p = res
+	return nil
+}
+
+// MarshalBinary 编码Digest
+func (dg *Digest) MarshalBinary() ([]byte, error) {
+	e := newEncoder()
+	e.uint8(uint8(dg.HashID))
+	e.uint32(dg.HashSize)
+	e.uint32(dg.Size)
+	e.bytes(dg.Acc)
+	e.uint32(uint32(len(dg.Roots)))
+	for _, root := range dg.Roots {
+		e.bytes(root)
+	}
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码Digest
+func (dg *Digest) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res Digest
+	res.HashID = crypto.HashID(d.uint8())
+	res.HashSize = d.uint32()
+	res.Size = d.uint32()
+	res.Acc = d.bytes()
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		res.Roots = append(res.Roots, d.bytes())
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*dg = res
+	return nil
+}
+
+// MarshalBinary 编码LeafHash
+func (l *LeafHash) MarshalBinary() ([]byte, error) {
+	e := newEncoder()
+	l.encode(e)
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码LeafHash
+func (l *LeafHash) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res LeafHash
+	res.decode(d)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*l = res
+	return nil
+}
+
+// 有header时: leafHeaderMarker | NodeContentHash | Acc | Prev | Timestamp | Metadata,
+// 没有header时只有NodeContentHash和Acc, 不认识header的解码方仍然可以解码
+func (l *LeafHash) encode(e *encoder) {
+	if l.Header != nil {
+		e.uint8(leafHeaderMarker)
+	}
+	e.bytes(l.NodeContentHash)
+	e.bytes(l.Acc)
+	if l.Header != nil {
+		e.bytes(l.Header.Prev)
+		e.uint64(l.Header.Timestamp)
+		e.bytes(l.Header.Metadata)
+	}
+}
+
+func (l *LeafHash) decode(d *decoder) {
+	header := d.err == nil && len(d.buf) > 0 && d.buf[0] == leafHeaderMarker
+	if header {
+		d.uint8()
+	}
+	l.NodeContentHash = d.bytes()
+	l.Acc = d.bytes()
+	if header {
+		l.Header = &LeafHeader{Prev: d.bytes(), Timestamp: d.uint64(), Metadata: d.bytes()}
+	}
+}
+
+// MarshalBinary 编码MerkleInclusionProof
+func (p *MerkleInclusionProof) MarshalBinary() ([]byte, error) {
+	e := newEncoder()
+	p.encode(e)
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码MerkleInclusionProof
+func (p *MerkleInclusionProof) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res MerkleInclusionProof
+	res.decode(d)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*p = res
+	return nil
+}
+
+func (p *MerkleInclusionProof) encode(e *encoder) {
+	e.uint32(p.Epoch)
+	e.uint32(p.Size)
+	p.Leaf.encode(e)
+	e.uint32(uint32(len(p.Siblings)))
+	for i := range p.Siblings {
+		p.Siblings[i].encode(e)
+	}
+}
+
+func (p *MerkleInclusionProof) decode(d *decoder) {
+	p.Epoch = d.uint32()
+	p.Size = d.uint32()
+	p.Leaf.decode(d)
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		var s Sibling
+		s.decode(d)
+		p.Siblings = append(p.Siblings, s)
+	}
+}
+
+// MarshalBinary 编码LookupProof, Inclusion不能为nil
+func (p *LookupProof) MarshalBinary() ([]byte, error) {
+	if p.Inclusion == nil {
+		return nil, errors.New("core: lookup proof without inclusion proof")
+	}
+	e := newEncoder()
+	e.uint32(p.Epoch)
+	e.uint32(p.Position)
+	e.bytes(p.Value)
+	e.uint32(uint32(len(p.Path)))
+	for _, level := range p.Path {
+		e.uint32(level.Index)
+		e.uint32(uint32(len(level.Children)))
+		for _, child := range level.Children {
+			e.bytes(child)
+		}
+	}
+	p.Inclusion.encode(e)
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码LookupProof
+func (p *LookupProof) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res LookupProof
+	res.Epoch = d.uint32()
+	res.Position = d.uint32()
+	res.Value = d.bytes()
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		var level LookupLevel
+		level.Index = d.uint32()
+		m := d.countMax(maxChildrenLen)
+		for j := 0; j < m && d.err == nil; j++ {
+			level.Children = append(level.Children, d.bytes())
+		}
+		res.Path = append(res.Path, level)
+	}
+	res.Inclusion = &MerkleInclusionProof{}
+	res.Inclusion.decode(d)
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*p = res
+	return nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type binaryObject interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	m := createTestingTree(11, 4)
+	inclusion, _ := m.GenerateInclusionProof(6, 11)
+	lookup, _ := m.GenerateLookupProof(6, 13, 11)
+
+	tables := []struct {
+		name string
+		in   binaryObject
+		out  binaryObject
+	}{
+		{"digest", m.mustOldDigest(11), &Digest{}},
+		{"empty digest", m.mustOldDigest(0), &Digest{}},
+		{"proof", m.mustConsistencyProof(3, 11), &MerkleConsistencyProof{}},
+		{"empty proof", &MerkleConsistencyProof{}, &MerkleConsistencyProof{}},
+		{"sibling", &Sibling{Hash: []byte("hash"), Acc: []byte("acc")}, &Sibling{}},
+		{"leaf hash", &LeafHash{NodeContentHash: []byte("content")}, &LeafHash{}},
+		{"inclusion proof", inclusion, &MerkleInclusionProof{}},
+		{"lookup proof", lookup, &LookupProof{}},
+	}
+
+	for _, table := range tables {
+		data, err := table.in.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.out.UnmarshalBinary(data); err != nil {
+			t.Errorf("%s: %v", table.name, err)
+			continue
+		}
+		again, _ := table.out.MarshalBinary()
+		if !bytes.Equal(data, again) {
+			t.Errorf("%s: round trip changed the encoding", table.name)
+		}
+
+		// 多余的字节, 截断, 错误的版本号都要拒绝
+		if err := table.out.UnmarshalBinary(append(data, 0)); !errors.Is(err, ErrTrailingBytes) {
+			t.Errorf("%s: trailing bytes: got %v", table.name, err)
+		}
+		if err := table.out.UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, ErrTruncated) {
+			t.Errorf("%s: truncated: got %v", table.name, err)
+		}
+		bad := append([]byte{}, data...)
+		bad[0] = encodingVersion + 1
+		if err := table.out.UnmarshalBinary(bad); !errors.Is(err, ErrEncodingVersion) {
+			t.Errorf("%s: version: got %v", table.name, err)
+		}
+	}
+
+	digest := m.mustOldDigest(11)
+	data, _ := digest.MarshalBinary()
+	var decoded Digest
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	proof := m.mustConsistencyProof(3, 11)
+	if !VerifyExtensionProof(m.Hasher(), m.mustOldDigest(3), &decoded, proof) {
+		t.Error("decoded digest should verify")
+	}
+}
+
+// 没有header的LeafHash与加入header之前的布局相同, 有header时以leafHeaderMarker开头
+func TestBinaryLeafLayout(t *testing.T) {
+	m := createTestingTree(5, 3)
+	proof, err := m.GenerateInclusionProof(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newEncoder()
+	e.uint32(proof.Epoch)
+	e.uint32(proof.Size)
+	e.bytes(proof.Leaf.NodeContentHash)
+	e.bytes(proof.Leaf.Acc)
+	e.uint32(uint32(len(proof.Siblings)))
+	for i := range proof.Siblings {
+		proof.Siblings[i].encode(e)
+	}
+	if data, _ := proof.MarshalBinary(); !bytes.Equal(data, e.buf) {
+		t.Error("leaf without header changed its layout")
+	}
+	var decoded MerkleInclusionProof
+	if err := decoded.UnmarshalBinary(e.buf); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Leaf.Header != nil || !VerifyInclusionProof(m.Hasher(), m.mustOldDigest(5), &decoded) {
+		t.Error("old layout should verify")
+	}
+
+	leaf := LeafHash{NodeContentHash: []byte{1}, Acc: []byte{2}, Header: &LeafHeader{Prev: []byte{3}, Timestamp: 4}}
+	data, _ := leaf.MarshalBinary()
+	if data[0] != encodingVersion || data[1] != leafHeaderMarker {
+		t.Errorf("got %x", data)
+	}
+	var decodedLeaf LeafHash
+	if err := decodedLeaf.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(decodedLeaf, leaf) {
+		t.Errorf("got %+v, %v", decodedLeaf, err)
+	}
+}
+
+func TestBinaryOversizedLength(t *testing.T) {
+	// 声明的长度超过上限
+	data := []byte{encodingVersion, 0xff, 0xff, 0xff, 0xff}
+	var s Sibling
+	if err := s.UnmarshalBinary(data); !errors.Is(err, ErrFieldTooLarge) {
+		t.Errorf("got %v", err)
+	}
+
+	var p MerkleConsistencyProof
+	if err := p.UnmarshalBinary([]byte{encodingVersion, 0, 0, 1, 0}); !errors.Is(err, ErrFieldTooLarge) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+var (
+	ErrInvalidEvidence = errors.New("core: invalid misbehaviour evidence")
+	ErrNoMisbehaviour  = errors.New("core: evidence does not show misbehaviour")
+)
+
+// MisbehaviourKind 是log的错误行为的类型
+type MisbehaviourKind string
+
+const (
+	Equivocation      MisbehaviourKind = "equivocation"       // 对同一个大小签名了两个不同的digest
+	ForkedRoot        MisbehaviourKind = "forked_root"        // 两个digest中同一个完整子树的root不同
+	ForkedLeaf        MisbehaviourKind = "forked_leaf"        // 同一个epoch在两个digest中的叶子不同
+	FailedConsistency MisbehaviourKind = "failed_consistency" // log给出的consistency proof验证失败
+	ForkedChain       MisbehaviourKind = "forked_chain"       // 较大的digest中链式叶子的Prev不是较小的digest
+)
+
+// MisbehaviourEvidence 是log签名了互相矛盾的digest的证据, 可以交给第三方用VerifyMisbehaviour复核。
+// First和Second是log签名的两个digest, 其余的proof是可选的:
+// Consistency是log给出的从较小的digest到较大的digest的consistency proof,
+// FirstInclusion和SecondInclusion是同一个epoch分别对First和Second的inclusion proof。
+// 链式的log只需要较大的digest的一个inclusion proof, 它的epoch是较小的digest的大小
+type MisbehaviourEvidence struct {
+	First           *SignedDigest           `json:"first"`
+	Second          *SignedDigest           `json:"second"`
+	Consistency     *MerkleConsistencyProof `json:"consistency,omitempty"`
+	FirstInclusion  *MerkleInclusionProof   `json:"first_inclusion,omitempty"`
+	SecondInclusion *MerkleInclusionProof   `json:"second_inclusion,omitempty"`
+}
+
+// Misbehaviour 是复核的结果。
+// Conclusive为true时两个签名本身就互相矛盾, 否则只说明log给出的proof验证失败,
+// 第三方可以向log重新请求proof确认
+type Misbehaviour struct {
+	Kind       MisbehaviourKind
+	Conclusive bool
+}
+
+// Hasher 返回证据中digest使用的hash函数, 包括签名中的tree ID
+func (ev *MisbehaviourEvidence) Hasher() (crypto.Hasher, error) {
+	if ev.First == nil || ev.First.Digest == nil {
+		return nil, ErrInvalidEvidence
+	}
+	dg := ev.First.Digest
+	h, err := crypto.NewHasher(dg.HashID, int(dg.HashSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+	}
+	return crypto.WithTreeID(h, ev.First.TreeID)
+}
+
+// VerifyMisbehaviour 用log的公钥复核证据, 不需要访问log。
+// 签名无效或者证据不完整时返回ErrInvalidEvidence, 两个digest一致时返回ErrNoMisbehaviour
+func VerifyMisbehaviour(pub ed25519.PublicKey, ev *MisbehaviourEvidence) (*Misbehaviour, error) {
+	if ev.First == nil || ev.Second == nil {
+		return nil, fmt.Errorf("%w: missing signed digest", ErrInvalidEvidence)
+	}
+	if !VerifySignedDigest(pub, ev.First) || !VerifySignedDigest(pub, ev.Second) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidEvidence)
+	}
+	if !bytes.Equal(ev.First.TreeID, ev.Second.TreeID) {
+		return nil, fmt.Errorf("%w: digests of different trees", ErrInvalidEvidence)
+	}
+	h, err := ev.Hasher()
+	if err != nil {
+		return nil, err
+	}
+	a, b := ev.First.Digest, ev.Second.Digest
+	firstInclusion, secondInclusion := ev.FirstInclusion, ev.SecondInclusion
+	if a.Size > b.Size {
+		a, b = b, a
+		firstInclusion, secondInclusion = secondInclusion, firstInclusion
+	}
+	if !a.WellFormed(h) || !b.WellFormed(h) {
+		return nil, fmt.Errorf("%w: malformed digest", ErrInvalidEvidence)
+	}
+
+	if a.Size == b.Size {
+		if bytes.Equal(a.BaggedRoot(h), b.BaggedRoot(h)) {
+			return nil, ErrNoMisbehaviour
+		}
+		return &Misbehaviour{Kind: Equivocation, Conclusive: true}, nil
+	}
+
+	// 旧森林中与新森林位置相同的root必须相同
+	newRoots := forestRoots(b.Size)
+	for i, root := range forestRoots(a.Size) {
+		if i < len(newRoots) && root == newRoots[i] && !bytes.Equal(a.Roots[i], b.Roots[i]) {
+			return &Misbehaviour{Kind: ForkedRoot, Conclusive: true}, nil
+		}
+	}
+
+	// 链式叶子的Prev必须是较小的digest
+	if firstInclusion == nil && secondInclusion != nil && secondInclusion.Leaf.Header != nil {
+		if secondInclusion.Epoch != a.Size || !VerifyInclusionProof(h, b, secondInclusion) {
+			return nil, fmt.Errorf("%w: chained leaf proof failed", ErrInvalidEvidence)
+		}
+		if !VerifyChainLink(h, a, b, secondInclusion) {
+			return &Misbehaviour{Kind: ForkedChain, Conclusive: true}, nil
+		}
+		secondInclusion = nil
+	}
+
+	if firstInclusion != nil || secondInclusion != nil {
+		if firstInclusion == nil || secondInclusion == nil || firstInclusion.Epoch != secondInclusion.Epoch {
+			return nil, fmt.Errorf("%w: inclusion proofs must be for the same epoch", ErrInvalidEvidence)
+		}
+		if !VerifyInclusionProof(h, a, firstInclusion) || !VerifyInclusionProof(h, b, secondInclusion) {
+			return nil, fmt.Errorf("%w: inclusion proof failed", ErrInvalidEvidence)
+		}
+		if !bytes.Equal(firstInclusion.Leaf.NodeContentHash, secondInclusion.Leaf.NodeContentHash) {
+			return &Misbehaviour{Kind: ForkedLeaf, Conclusive: true}, nil
+		}
+	}
+
+	if ev.Consistency == nil {
+		return nil, ErrNoMisbehaviour
+	}
+	if VerifyExtensionProof(h, a, b, ev.Consistency) {
+		return nil, ErrNoMisbehaviour
+	}
+	return &Misbehaviour{Kind: FailedConsistency}, nil
+}
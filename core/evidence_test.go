@@ -0,0 +1,102 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+// 两棵树的epoch forkAt的值不同, 其他相同
+func forkedTrees(t *testing.T, h crypto.Hasher, size int, forkAt int) (*MerklePT, *MerklePT) {
+	a, b := NewMerklePT(4, h), NewMerklePT(4, h)
+	for i := 0; i < size; i++ {
+		value := []byte(fmt.Sprint("v", i))
+		if _, err := a.AppendValues(2, 2, [][]byte{value}); err != nil {
+			t.Fatal(err)
+		}
+		if i == forkAt {
+			value = []byte("forked")
+		}
+		if _, err := b.AppendValues(2, 2, [][]byte{value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b
+}
+
+func TestVerifyMisbehaviour(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	treeID := []byte("tree")
+	sign := func(m *MerklePT, size uint32) *SignedDigest {
+		return SignDigest(key, treeID, m.mustOldDigest(size))
+	}
+	h, _ := crypto.WithTreeID(crypto.Default, treeID)
+	a, b := forkedTrees(t, h, 8, 1)
+	firstInclusion, _ := a.GenerateInclusionProof(1, 5)
+	secondInclusion, _ := b.GenerateInclusionProof(1, 8)
+
+	tables := []struct {
+		name       string
+		ev         *MisbehaviourEvidence
+		kind       MisbehaviourKind
+		conclusive bool
+	}{
+		{"equivocation", &MisbehaviourEvidence{First: sign(a, 3), Second: sign(b, 3)}, Equivocation, true},
+		// 大小4的root也是大小6的第一个root
+		{"forked root", &MisbehaviourEvidence{First: sign(b, 6), Second: sign(a, 4)}, ForkedRoot, true},
+		{"failed consistency", &MisbehaviourEvidence{
+			First: sign(a, 5), Second: sign(b, 8), Consistency: b.mustConsistencyProof(5, 8),
+		}, FailedConsistency, false},
+		{"forked leaf", &MisbehaviourEvidence{
+			First: sign(a, 5), Second: sign(b, 8), FirstInclusion: firstInclusion, SecondInclusion: secondInclusion,
+		}, ForkedLeaf, true},
+	}
+
+	for _, table := range tables {
+		// 证据经过JSON编码后仍然可以复核
+		data, err := json.Marshal(table.ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ev MisbehaviourEvidence
+		if err := json.Unmarshal(data, &ev); err != nil {
+			t.Fatal(err)
+		}
+		got, err := VerifyMisbehaviour(pub, &ev)
+		if err != nil {
+			t.Errorf("%s: %v", table.name, err)
+			continue
+		}
+		if got.Kind != table.kind || got.Conclusive != table.conclusive {
+			t.Errorf("%s: got %+v", table.name, got)
+		}
+	}
+
+	// 一致的digest
+	consistent := &MisbehaviourEvidence{First: sign(a, 5), Second: sign(a, 8), Consistency: a.mustConsistencyProof(5, 8)}
+	if _, err := VerifyMisbehaviour(pub, consistent); !errors.Is(err, ErrNoMisbehaviour) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := VerifyMisbehaviour(pub, &MisbehaviourEvidence{First: sign(a, 3), Second: sign(a, 3)}); !errors.Is(err, ErrNoMisbehaviour) {
+		t.Errorf("got %v", err)
+	}
+	// 其他key的签名
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if _, err := VerifyMisbehaviour(otherPub, tables[0].ev); !errors.Is(err, ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+	// 不同tree的digest
+	other := &MisbehaviourEvidence{First: sign(a, 3), Second: SignDigest(key, []byte("other"), b.mustOldDigest(3))}
+	if _, err := VerifyMisbehaviour(pub, other); !errors.Is(err, ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+	// inclusion proof不属于这两个digest
+	wrong := &MisbehaviourEvidence{First: sign(a, 5), Second: sign(b, 8), FirstInclusion: secondInclusion, SecondInclusion: firstInclusion}
+	if _, err := VerifyMisbehaviour(pub, wrong); !errors.Is(err, ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+// JSON编码的版本号, 与二进制编码分开维护
+const jsonVersion = 1
+
+// JSON中的hash统一使用标准base64, 空值编码为"", 列表为空时编码为[]
+type siblingJSON struct {
+	Hash string `json:"hash"`
+	Acc  string `json:"acc"`
+}
+
+type leafHashJSON struct {
+	ContentHash string          `json:"content_hash"`
+	Acc         string          `json:"acc"`
+	Header      *leafHeaderJSON `json:"header,omitempty"`
+}
+
+type leafHeaderJSON struct {
+	Prev      string `json:"prev"`
+	Timestamp uint64 `json:"timestamp"`
+	Metadata  string `json:"metadata"`
+}
+
+type digestJSON struct {
+	Version  int      `json:"version"`
+	Hash     string   `json:"hash"`
+	HashSize uint32   `json:"hash_size"`
+	Size     uint32   `json:"size"`
+	Acc      string   `json:"acc"`
+	Roots    []string `json:"roots"`
+}
+
+type consistencyProofJSON struct {
+	Version  int           `json:"version"`
+	Siblings []siblingJSON `json:"siblings"`
+}
+
+type inclusionProofJSON struct {
+	Version  int           `json:"version"`
+	Epoch    uint32        `json:"epoch"`
+	Size     uint32        `json:"size"`
+	Leaf     leafHashJSON  `json:"leaf"`
+	Siblings []siblingJSON `json:"siblings"`
+}
+
+type lookupLevelJSON struct {
+	Index    uint32   `json:"index"`
+	Children []string `json:"children"`
+}
+
+type lookupProofJSON struct {
+	Version   int                   `json:"version"`
+	Epoch     uint32                `json:"epoch"`
+	Position  uint32                `json:"position"`
+	Value     string                `json:"value"`
+	Path      []lookupLevelJSON     `json:"path"`
+	Inclusion *MerkleInclusionProof `json:"inclusion"`
+}
+
+func encodeB64(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func encodeB64List(list [][]byte) []string {
+	res := make([]string, len(list))
+	for i, b := range list {
+		res[i] = encodeB64(b)
+	}
+	return res
+}
+
+// 与二进制编码一样, 空值解码为nil
+func decodeB64(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.Strict().DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("core: invalid base64 field: %w", err)
+	}
+	return b, nil
+}
+
+func decodeB64List(list []string) ([][]byte, error) {
+	var res [][]byte
+	for _, s := range list {
+		b, err := decodeB64(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func encodeLeafHashJSON(l *LeafHash) leafHashJSON {
+	res := leafHashJSON{
+		ContentHash: encodeB64(l.NodeContentHash),
+		Acc:         encodeB64(l.Acc),
+	}
+	if l.Header != nil {
+		res.Header = &leafHeaderJSON{
+			Prev:      encodeB64(l.Header.Prev),
+			Timestamp: l.Header.Timestamp,
+			Metadata:  encodeB64(l.Header.Metadata),
+		}
+	}
+	return res
+}
+
+func decodeLeafHashJSON(v leafHashJSON) (LeafHash, error) {
+	var l LeafHash
+	var err error
+	if l.NodeContentHash, err = decodeB64(v.ContentHash); err != nil {
+		return LeafHash{}, err
+	}
+	if l.Acc, err = decodeB64(v.Acc); err != nil {
+		return LeafHash{}, err
+	}
+	if v.Header != nil {
+		l.Header = &LeafHeader{Timestamp: v.Header.Timestamp}
+		if l.Header.Prev, err = decodeB64(v.Header.Prev); err != nil {
+			return LeafHash{}, err
+		}
+		if l.Header.Metadata, err = decodeB64(v.Header.Metadata); err != nil {
+			return LeafHash{}, err
+		}
+	}
+	return l, nil
+}
+
+func encodeSiblingsJSON(siblings []Sibling) []siblingJSON {
+	res := make([]siblingJSON, len(siblings))
+	for i, s := range siblings {
+		res[i] = siblingJSON{Hash: encodeB64(s.Hash), Acc: encodeB64(s.Acc)}
+	}
+	return res
+}
+
+func decodeSiblingsJSON(list []siblingJSON) ([]Sibling, error) {
+	var res []Sibling
+	for _, s := range list {
+		hash, err := decodeB64(s.Hash)
+		if err != nil {
+			return nil, err
+		}
+		acc, err := decodeB64(s.Acc)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Sibling{Hash: hash, Acc: acc})
+	}
+	return res, nil
+}
+
+// 严格解析: 不允许未知字段, 缺少字段和多余的数据, 版本号必须一致
+func unmarshalStrict(data []byte, v interface{}, version *int) error {
+	if err := requireFields(data, v); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("core: trailing data after JSON value")
+	}
+	if *version != jsonVersion {
+		return fmt.Errorf("core: unsupported JSON version %d", *version)
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// 检查v(指向结构体)的所有JSON字段都出现在data中, 有UnmarshalJSON的嵌套对象由自己检查
+func requireFields(data []byte, v interface{}) error {
+	return checkNested(data, reflect.TypeOf(v).Elem())
+}
+
+func checkFields(fields map[string]json.RawMessage, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")
+		name := tag[0]
+		raw, ok := fields[name]
+		if !ok {
+			// omitempty的字段是可选的
+			if len(tag) > 1 && tag[1] == "omitempty" {
+				continue
+			}
+			return fmt.Errorf("core: missing JSON field %q", name)
+		}
+		// 嵌套的wire结构体和结构体列表也必须完整
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Pointer && ft.Elem().Kind() == reflect.Struct && !ft.Implements(unmarshalerType) && string(raw) != "null" {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			if err := checkNested(raw, ft); err != nil {
+				return err
+			}
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			var list []json.RawMessage
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return err
+			}
+			for _, item := range list {
+				if err := checkNested(item, ft.Elem()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkNested(raw json.RawMessage, t reflect.Type) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	return checkFields(fields, t)
+}
+
+// MarshalJSON 编码Digest
+func (dg *Digest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(digestJSON{
+		Version:  jsonVersion,
+		Hash:     dg.HashID.String(),
+		HashSize: dg.HashSize,
+		Size:     dg.Size,
+		Acc:      encodeB64(dg.Acc),
+		Roots:    encodeB64List(dg.Roots),
+	})
+}
+
+// UnmarshalJSON 解码Digest
+func (dg *Digest) UnmarshalJSON(data []byte) error {
+	var v digestJSON
+	if err := unmarshalStrict(data, &v, &v.Version); err != nil {
+		return err
+	}
+	id, err := crypto.ParseHashID(v.Hash)
+	if err != nil {
+		return err
+	}
+	acc, err := decodeB64(v.Acc)
+	if err != nil {
+		return err
+	}
+	roots, err := decodeB64List(v.Roots)
+	if err != nil {
+		return err
+	}
+	*dg = Digest{Roots: roots, Acc: acc, Size: v.Size, HashID: id, HashSize: v.HashSize}
+	return nil
+}
+
+// MarshalJSON 编码MerkleConsistencyProof
+func (p *MerkleConsistencyProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(consistencyProofJSON{
+		Version:  jsonVersion,
+		Siblings: encodeSiblingsJSON(p.Siblings),
+	})
+}
+
+// UnmarshalJSON 解码MerkleConsistencyProof
+func (p *MerkleConsistencyProof) UnmarshalJSON(data []byte) error {
+	var v consistencyProofJSON
+	if err := unmarshalStrict(data, &v, &v.Version); err != nil {
+		return err
+	}
+	siblings, err := decodeSiblingsJSON(v.Siblings)
+	if err != nil {
+		return err
+	}
+	*p = MerkleConsistencyProof{Siblings: siblings}
+	return nil
+}
+
+// MarshalJSON 编码MerkleInclusionProof
+func (p *MerkleInclusionProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(inclusionProofJSON{
+		Version:  jsonVersion,
+		Epoch:    p.Epoch,
+		Size:     p.Size,
+		Leaf:     encodeLeafHashJSON(&p.Leaf),
+		Siblings: encodeSiblingsJSON(p.Siblings),
+	})
+}
+
+// UnmarshalJSON 解码MerkleInclusionProof
+func (p *MerkleInclusionProof) UnmarshalJSON(data []byte) error {
+	var v inclusionProofJSON
+	if err := unmarshalStrict(data, &v, &v.Version); err != nil {
+		return err
+	}
+	leaf, err := decodeLeafHashJSON(v.Leaf)
+	if err != nil {
+		return err
+	}
+	siblings, err := decodeSiblingsJSON(v.Siblings)
+	if err != nil {
+		return err
+	}
+	*p = MerkleInclusionProof{
+		Epoch:    v.Epoch,
+		Size:     v.Size,
+		Leaf:     leaf,
+		Siblings: siblings,
+	}
+	return nil
+}
+
+// MarshalJSON 编码LookupProof, Inclusion不能为nil
+func (p *LookupProof) MarshalJSON() ([]byte, error) {
+	if p.Inclusion == nil {
+		return nil, errors.New("core: lookup proof without inclusion proof")
+	}
+	path := make([]lookupLevelJSON, len(p.Path))
+	for i, level := range p.Path {
+		path[i] = lookupLevelJSON{Index: level.Index, Children: encodeB64List(level.Children)}
+	}
+	return json.Marshal(lookupProofJSON{
+		Version:   jsonVersion,
+		Epoch:     p.Epoch,
+		Position:  p.Position,
+		Value:     encodeB64(p.Value),
+		Path:      path,
+		Inclusion: p.Inclusion,
+	})
+}
+
+// UnmarshalJSON 解码LookupProof
+func (p *LookupProof) UnmarshalJSON(data []byte) error {
+	var v lookupProofJSON
+	if err := unmarshalStrict(data, &v, &v.Version); err != nil {
+		return err
+	}
+	if v.Inclusion == nil {
+		return errors.New("core: lookup proof without inclusion proof")
+	}
+	value, err := decodeB64(v.Value)
+	if err != nil {
+		return err
+	}
+	var path []LookupLevel
+	for _, level := range v.Path {
+		children, err := decodeB64List(level.Children)
+		if err != nil {
+			return err
+		}
+		path = append(path, LookupLevel{Index: level.Index, Children: children})
+	}
+	*p = LookupProof{
+		Epoch:     v.Epoch,
+		Position:  v.Position,
+		Value:     value,
+		Path:      path,
+		Inclusion: v.Inclusion,
+	}
+	return nil
+}
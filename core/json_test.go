@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	m := createTestingTree(11, 4)
+	inclusion, _ := m.GenerateInclusionProof(6, 11)
+	lookup, _ := m.GenerateLookupProof(6, 13, 11)
+
+	tables := []struct {
+		name string
+		in   interface{}
+		out  interface{}
+	}{
+		{"digest", m.mustOldDigest(11), &Digest{}},
+		{"empty digest", m.mustOldDigest(0), &Digest{}},
+		{"consistency proof", m.mustConsistencyProof(3, 11), &MerkleConsistencyProof{}},
+		{"inclusion proof", inclusion, &MerkleInclusionProof{}},
+		{"lookup proof", lookup, &LookupProof{}},
+	}
+
+	for _, table := range tables {
+		data, err := json.Marshal(table.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("null")) {
+			t.Errorf("%s: JSON should not contain null: %s", table.name, data)
+		}
+		if err := json.Unmarshal(data, table.out); err != nil {
+			t.Errorf("%s: %v", table.name, err)
+			continue
+		}
+		again, _ := json.Marshal(table.out)
+		if !bytes.Equal(data, again) {
+			t.Errorf("%s: round trip changed the encoding", table.name)
+		}
+	}
+
+	var decoded LookupProof
+	data, _ := json.Marshal(lookup)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyLookupProof(m.Hasher(), 3, 3, m.mustOldDigest(11), &decoded) {
+		t.Error("decoded lookup proof should verify")
+	}
+}
+
+func TestJSONStrict(t *testing.T) {
+	m := createTestingTree(3, 2)
+	data, _ := json.Marshal(m.mustOldDigest(3))
+	good := string(data)
+
+	bad := []string{
+		strings.Replace(good, `"version":1`, `"version":2`, 1),
+		strings.Replace(good, `"size":3`, `"size":3,"extra":1`, 1),
+		strings.Replace(good, `"size":3,`, ``, 1),
+		strings.Replace(good, `"hash":"shake128"`, `"hash":"md5"`, 1),
+		strings.Replace(good, `"acc":"`, `"acc":"!`, 1),
+		good + `{}`,
+	}
+	for _, s := range bad {
+		var d Digest
+		if err := json.Unmarshal([]byte(s), &d); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestJSONTypesAreDistinct(t *testing.T) {
+	m := createTestingTree(3, 2)
+	data, _ := json.Marshal(m.mustConsistencyProof(1, 3))
+
+	var inclusion MerkleInclusionProof
+	if err := json.Unmarshal(data, &inclusion); err == nil {
+		t.Error("a consistency proof should not parse as an inclusion proof")
+	}
+
+	proof, _ := m.GenerateInclusionProof(0, 3)
+	data, _ = json.Marshal(proof)
+	for _, field := range []string{`"content_hash"`, `"hash"`} {
+		i := strings.Index(string(data), field)
+		j := i + strings.Index(string(data[i:]), ",")
+		incomplete := string(data[:i]) + string(data[j+1:])
+		if err := json.Unmarshal([]byte(incomplete), &inclusion); err == nil {
+			t.Errorf("nested objects should be complete: %s", incomplete)
+		}
+	}
+}
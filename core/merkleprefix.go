@@ -3,19 +3,30 @@ package core
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"math/bits"
+	"sync"
 
-	crypto "github.com/ucbrise/MerkleSquare/lib/crypto"
+	"MerkleVerkle/lib/crypto"
 )
 
-// Merkle prefix tree
+var (
+	ErrInvalidSize   = errors.New("core: requested size or epoch out of range")
+	ErrTreeFull      = errors.New("core: tree is full")
+	ErrTooManyLeaves = errors.New("core: too many leaves for the verkle tree")
+)
+
+// Merkle prefix tree, 可以并发调用: Append持有写锁, 生成digest和proof持有读锁
 type MerklePT struct {
+	mu      sync.RWMutex
 	Roots   []MerkleNode
 	root    MerkleNode
 	next    MerkleNode
 	Size    uint32
 	depth   uint32
 	accroot []byte //pre-compute中历史root的acc
+	hasher  crypto.Hasher
+	chained bool //叶子是否commit前一个digest, 见NewChainedMerklePT
 }
 
 // MerkleConsistency proof contains an existence proof and subset proof 对于一个特定的leafnode
@@ -31,39 +42,101 @@ type Sibling struct {
 	Acc []byte
 }
 
+// MerkleInclusionProof 证明第Epoch个叶子包含在大小为Size的digest中
+type MerkleInclusionProof struct {
+	Epoch    uint32
+	Size     uint32
+	Leaf     LeafHash
+	Siblings []Sibling //从叶子到所在森林root的兄弟节点
+}
+
+// LookupProof 证明第Epoch个epoch的verkle tree中第Position个叶子的值为Value。
+// 叶子由Path中的下标确定, Position只用于定位。
+type LookupProof struct {
+	Epoch     uint32
+	Position  uint32
+	Value     []byte
+	Path      []LookupLevel //自底向上
+	Inclusion *MerkleInclusionProof
+}
+
 // digest 对于当前Merkle prefix tree的状态
 type Digest struct {
-	Roots [][]byte
-	Acc   []byte //root 中的accumulator
-	Size  uint32
+	Roots    [][]byte
+	Acc      []byte //root 中的accumulator
+	Size     uint32
+	HashID   crypto.HashID //计算digest使用的hash函数
+	HashSize uint32
 }
 
 // 叶子节点的hash
 type LeafHash struct {
 	NodeContentHash []byte
 	Acc             []byte
+	Header          *LeafHeader //链式MerklePT的叶子才有
 }
 
 // 添加元素到Merkle prefix tree，hash(epo和acc),acc
 func (m *MerklePT) Append(k uint32, depth uint32, numverkle uint32) {
-	if m.isFull() {
-		return
+	tree, err := NewKaryTree(k, depth, m.hasher)
+	if err != nil {
+		panic(err)
 	}
-	node := m.next.(*LeafNode)
-	tree := NewKaryTree(k, depth)
 	for i := 0; i < int(numverkle); i++ {
 		tree.AddLeaf(uint32(i))
 	}
-	nodeAcc := tree.CalculateHashes(tree.Root)
+	_, _ = m.AppendTree(tree)
+}
 
-	node.completeLeaf(nodeAcc, m.Size)
+// AppendValues 用values作为叶子构造一个epoch的verkle tree并添加到Merkle prefix tree, 返回新的epoch
+func (m *MerklePT) AppendValues(k uint32, depth uint32, values [][]byte) (uint32, error) {
+	tree, err := NewKaryTree(k, depth, m.hasher)
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range values {
+		if !tree.AddValue(value) {
+			return 0, ErrTooManyLeaves
+		}
+	}
+	return m.AppendTree(tree)
+}
+
+// AppendTree 把一个epoch的verkle tree添加到Merkle prefix tree, tree必须使用相同的hasher, 返回新的epoch
+func (m *MerklePT) AppendTree(tree *KaryTree) (uint32, error) {
+	return m.appendTree(tree, 0, nil)
+}
+
+func (m *MerklePT) appendTree(tree *KaryTree, timestamp uint64, metadata []byte) (uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isFull() {
+		return 0, ErrTreeFull
+	}
+	epoch := m.Size
+	node := m.next.(*LeafNode)
+	nodeAcc := tree.ComputeAcc()
+
+	if m.chained {
+		if err := m.checkTimestamp(timestamp); err != nil {
+			return 0, err
+		}
+		node.header = &LeafHeader{
+			Prev:      m.getOldDigest(m.Size).BaggedRoot(m.hasher),
+			Timestamp: timestamp,
+			Metadata:  metadata,
+		}
+	}
+	node.completeLeaf(m.hasher, nodeAcc, m.Size)
+	node.verkle = tree
 	m.Size++
 	p := m.next
 
 	//如果节点是右节点，那么合并，合并的时候要取出来一个旧root，将新的root添加进去
 	for p.isRightChild() {
 		p = p.getParent()
-		p.complete()
+		p.complete(m.hasher)
 		m.pop()
 	}
 	m.addRoot(p)          //左节点作为新的root添加到森林中
@@ -71,7 +144,7 @@ func (m *MerklePT) Append(k uint32, depth uint32, numverkle uint32) {
 
 	//查看tree是否是满的
 	if m.isFull() {
-		return
+		return epoch, nil
 	}
 	_ = p.getParent().createRightChild()
 	p = p.getParent().getRightChild()
@@ -80,6 +153,18 @@ func (m *MerklePT) Append(k uint32, depth uint32, numverkle uint32) {
 		p = p.getLeftChild()
 	}
 	m.next = p
+	return epoch, nil
+}
+
+// GetValues 返回第epoch个verkle tree的所有叶子的值
+func (m *MerklePT) GetValues(epoch uint32) ([][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if epoch >= m.Size {
+		return nil, ErrInvalidSize
+	}
+	return m.getLeafNode(epoch).(*LeafNode).verkle.Values(), nil
 }
 
 func (m *MerklePT) getLeafNode(epo uint32) MerkleNode {
@@ -100,9 +185,14 @@ func (m *MerklePT) getLeafNode(epo uint32) MerkleNode {
 
 // GenerateExistenceProof 为给定的key/高度对生成存在证明
 
-// 给一个digest生成consistency proof
-func (m *MerklePT) GenerateConsistencyProof(oldSize uint32, requestedSize uint32) *MerkleConsistencyProof {
+// 给一个digest生成consistency proof, 需要 oldSize <= requestedSize <= m.Size
+func (m *MerklePT) GenerateConsistencyProof(oldSize uint32, requestedSize uint32) (*MerkleConsistencyProof, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
+	if oldSize > requestedSize || requestedSize > m.Size {
+		return nil, ErrInvalidSize
+	}
 	roots := m.getOldRoots(requestedSize)
 	oldDigestRoots := m.getOldRoots(oldSize)
 	res := &MerkleConsistencyProof{}
@@ -115,7 +205,62 @@ func (m *MerklePT) GenerateConsistencyProof(oldSize uint32, requestedSize uint32
 			break
 		}
 	}
-	return res
+	return res, nil
+}
+
+// GenerateInclusionProof 为第epoch个叶子生成对大小为size的digest的存在证明
+func (m *MerklePT) GenerateInclusionProof(epoch uint32, size uint32) (*MerkleInclusionProof, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.generateInclusionProof(epoch, size)
+}
+
+func (m *MerklePT) generateInclusionProof(epoch uint32, size uint32) (*MerkleInclusionProof, error) {
+	if size > m.Size || epoch >= size {
+		return nil, ErrInvalidSize
+	}
+	leaf := m.getLeafNode(epoch).(*LeafNode)
+	proof := &MerkleInclusionProof{
+		Epoch: epoch,
+		Size:  size,
+		Leaf: LeafHash{
+			NodeContentHash: leaf.getContentHash(),
+			Acc:             leaf.getAcc(),
+			Header:          leaf.header,
+		},
+	}
+
+	depth := GetOldDepth(epoch, size)
+	var node MerkleNode = leaf
+	for node.getDepth() != depth {
+		proof.Siblings = append(proof.Siblings, node.getSibling())
+		node = node.getParent()
+	}
+	return proof, nil
+}
+
+// GenerateLookupProof 为第epoch个verkle tree中的第pos个叶子生成对大小为size的digest的证明
+func (m *MerklePT) GenerateLookupProof(epoch uint32, pos uint32, size uint32) (*LookupProof, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	inclusion, err := m.generateInclusionProof(epoch, size)
+	if err != nil {
+		return nil, err
+	}
+	tree := m.getLeafNode(epoch).(*LeafNode).verkle
+	value, path, err := tree.GenerateLookupProof(pos)
+	if err != nil {
+		return nil, err
+	}
+	return &LookupProof{
+		Epoch:     epoch,
+		Position:  pos,
+		Value:     value,
+		Path:      path,
+		Inclusion: inclusion,
+	}, nil
 }
 
 func generateConsistencyProof(node MerkleNode, proof *MerkleConsistencyProof, depth uint32) {
@@ -220,21 +365,36 @@ func (m *MerklePT) addAccs(acc []byte) {
 }
 
 // 将uint32转换为[]byte用来计算Hash
-func ComputeContentHash(acc []byte, pos uint32) []byte {
+func ComputeContentHash(h crypto.Hasher, acc []byte, pos uint32) []byte {
 	posAsByte := make([]byte, 4)
 	binary.LittleEndian.PutUint32(posAsByte, pos) //使用小端序序列化，处理的更快
 
-	contentHash := crypto.Hash(acc, posAsByte)
+	if th, ok := h.(crypto.TreeHasher); ok {
+		return th.HashLeaf(acc, posAsByte)
+	}
+	contentHash := h.Hash(acc, posAsByte)
 
 	return contentHash
 }
 
-// NewMerklePT是构造MerklePT对象的工厂方法
-func NewMerklePT(depth uint32) *MerklePT {
+// HashChildren 计算内部节点的hash, h是TreeHasher时使用它的内部节点hash
+func HashChildren(h crypto.Hasher, left []byte, right []byte) []byte {
+	if th, ok := h.(crypto.TreeHasher); ok {
+		return th.HashChildren(left, right)
+	}
+	return h.Hash(left, right)
+}
+
+// NewMerklePT是构造MerklePT对象的工厂方法, h为nil时使用crypto.Default
+func NewMerklePT(depth uint32, h crypto.Hasher) *MerklePT {
+	if h == nil {
+		h = crypto.Default
+	}
 	m := &MerklePT{
-		Roots: []MerkleNode{},
-		Size:  0,
-		depth: depth,
+		Roots:  []MerkleNode{},
+		Size:   0,
+		depth:  depth,
+		hasher: h,
 	}
 
 	next := createRootNode(depth)
@@ -248,42 +408,112 @@ func NewMerklePT(depth uint32) *MerklePT {
 	return m
 }
 
-// VerifyExtensionProof verifies an ExtensionProof
-func VerifyExtensionProof(oldDigest *Digest, newDigest *Digest, proof *MerkleConsistencyProof) bool {
+// MemorySize 估计树(包括每个epoch的verkle tree)占用的字节数
+func (m *MerklePT) MemorySize() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.root.getSize()
+}
+
+// Hasher 返回MerklePT使用的hash函数
+func (m *MerklePT) Hasher() crypto.Hasher {
+	return m.hasher
+}
+
+// VerifyExtensionProof verifies an ExtensionProof.
+// 新旧digest的大小决定了旧的最后一个root所在的新root的位置index: 之前的root必须相同,
+// 旧的index之后的所有root和proof中的所有sibling必须正好合成新的第index个root
+func VerifyExtensionProof(h crypto.Hasher, oldDigest *Digest, newDigest *Digest, proof *MerkleConsistencyProof) bool {
+	if !oldDigest.WellFormed(h) || !newDigest.WellFormed(h) || oldDigest.Size > newDigest.Size {
+		return false
+	}
+	if oldDigest.Size == 0 {
+		return true
+	}
 
-	for i, oldRoot := range oldDigest.Roots {
-		if bytes.Equal(oldRoot, newDigest.Roots[i]) {
-			continue
+	index := getRootIndex(oldDigest.Size-1, newDigest.Size)
+	for i := 0; i < index; i++ {
+		if !bytes.Equal(oldDigest.Roots[i], newDigest.Roots[i]) {
+			return false
 		}
+	}
 
-		p := len(oldDigest.Roots) - 2
-		hash := oldDigest.Roots[p+1]
-		// acc := oldDigest.Acc
-		//acc
-
-		lastRootDepth := GetOldDepth(oldDigest.Size-1, oldDigest.Size)
-		newRootDepth := GetOldDepth(oldDigest.Size-1, newDigest.Size)
-		shift := oldDigest.Size - 1
-		siblingIndex := 0
-
-		for j := 0; uint32(j) < newRootDepth; j++ {
-			if uint32(j) >= lastRootDepth && isRight(shift) {
-				hash = crypto.Hash(oldDigest.Roots[p], hash)
-				// acc = []byte("1")
-				p = p - 1
-			} else if uint32(j) >= lastRootDepth {
-				hash = crypto.Hash(hash, proof.Siblings[siblingIndex].Hash)
-				// acc = []byte("1")
-				siblingIndex++
-			}
+	p := len(oldDigest.Roots) - 2
+	hash := oldDigest.Roots[p+1]
+
+	lastRootDepth := GetOldDepth(oldDigest.Size-1, oldDigest.Size)
+	newRootDepth := GetOldDepth(oldDigest.Size-1, newDigest.Size)
+	shift := oldDigest.Size - 1
+	siblingIndex := 0
 
-			shift = shift / 2
+	for j := uint32(0); j < newRootDepth; j++ {
+		if j >= lastRootDepth && isRight(shift) {
+			if p < index {
+				return false
+			}
+			hash = HashChildren(h, oldDigest.Roots[p], hash)
+			p = p - 1
+		} else if j >= lastRootDepth {
+			if siblingIndex >= len(proof.Siblings) {
+				return false
+			}
+			hash = HashChildren(h, hash, proof.Siblings[siblingIndex].Hash)
+			siblingIndex++
 		}
 
-		return bytes.Equal(hash, newDigest.Roots[i])
+		shift = shift / 2
 	}
 
-	return true
+	// 旧的root和sibling都必须用完
+	if p != index-1 || siblingIndex != len(proof.Siblings) {
+		return false
+	}
+	return bytes.Equal(hash, newDigest.Roots[index])
+}
+
+// VerifyInclusionProof 验证叶子proof.Leaf是digest中的第proof.Epoch个叶子
+func VerifyInclusionProof(h crypto.Hasher, digest *Digest, proof *MerkleInclusionProof) bool {
+	if !digest.WellFormed(h) || proof.Size != digest.Size || proof.Epoch >= digest.Size {
+		return false
+	}
+
+	if proof.Leaf.Header != nil && !proof.Leaf.Header.valid() {
+		return false
+	}
+	hash := ComputeLeafHash(h, proof.Leaf.Acc, proof.Epoch, proof.Leaf.Header)
+	if !bytes.Equal(hash, proof.Leaf.NodeContentHash) {
+		return false
+	}
+
+	index := getRootIndex(proof.Epoch, digest.Size)
+	if index >= len(digest.Roots) || int(GetOldDepth(proof.Epoch, digest.Size)) != len(proof.Siblings) {
+		return false
+	}
+
+	shift := proof.Epoch
+	for _, sibling := range proof.Siblings {
+		if isRight(shift) {
+			hash = HashChildren(h, sibling.Hash, hash)
+		} else {
+			hash = HashChildren(h, hash, sibling.Hash)
+		}
+		shift = shift / 2
+	}
+
+	return bytes.Equal(hash, digest.Roots[index])
+}
+
+// VerifyLookupProof 验证proof.Value是digest中第proof.Epoch个epoch的verkle tree的第proof.Position个叶子,
+// k和depth是verkle tree的参数, 验证通过后Position也是可信的
+func VerifyLookupProof(h crypto.Hasher, k uint32, depth uint32, digest *Digest, proof *LookupProof) bool {
+	if proof.Inclusion == nil || proof.Inclusion.Epoch != proof.Epoch {
+		return false
+	}
+	if !VerifyInclusionProof(h, digest, proof.Inclusion) {
+		return false
+	}
+	return VerifyLookupPath(h, k, depth, proof.Position, proof.Value, proof.Path, proof.Inclusion.Leaf.Acc)
 }
 
 // GetOldDepth given a position and size for an old forest, returns the depth of the tree pos belongs to
@@ -308,7 +538,17 @@ func GetOldDepth(pos uint32, size uint32) uint32 {
 
 // GetOldDigest returns a digest of the a MerkleSquare instance
 // when it only contained oldSize keys.
-func (m *MerklePT) GetOldDigest(oldSize uint32) *Digest {
+func (m *MerklePT) GetOldDigest(oldSize uint32) (*Digest, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if oldSize > m.Size {
+		return nil, ErrInvalidSize
+	}
+	return m.getOldDigest(oldSize), nil
+}
+
+func (m *MerklePT) getOldDigest(oldSize uint32) *Digest {
 	Roots := [][]byte{}
 
 	for _, root := range m.getOldRoots(oldSize) {
@@ -319,10 +559,28 @@ func (m *MerklePT) GetOldDigest(oldSize uint32) *Digest {
 		Roots: Roots, //全是hash
 		Size:  oldSize,
 		//加上acc?
-		Acc: []byte("1"),
+		Acc:      []byte("1"),
+		HashID:   m.hasher.ID(),
+		HashSize: uint32(m.hasher.Size()),
 	}
 }
 
+// WellFormed 检查digest是否由h计算得到, 并且roots的个数和长度与Size一致
+func (d *Digest) WellFormed(h crypto.Hasher) bool {
+	if d.HashID != h.ID() || d.HashSize != uint32(h.Size()) {
+		return false
+	}
+	if len(d.Roots) != bits.OnesCount32(d.Size) {
+		return false
+	}
+	for _, root := range d.Roots {
+		if len(root) != h.Size() {
+			return false
+		}
+	}
+	return true
+}
+
 // Returns the root index that pos belongs to given the forest Size
 func getRootIndex(pos uint32, Size uint32) int {
 
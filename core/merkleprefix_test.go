@@ -2,6 +2,8 @@ package core
 
 import (
 	"testing"
+
+	"MerkleVerkle/lib/crypto"
 )
 
 //*******************************
@@ -10,7 +12,7 @@ import (
 
 // 目前只是测试了Merkle tree，Merkle prefix tre中的prefix 在monitor的时候生成根据最后的epoch和自己的epoch生成。
 func TestAppend(t *testing.T) {
-	m := NewMerklePT(4)
+	m := NewMerklePT(4, nil)
 	m.Append(3, 3, 27)
 	m.Append(3, 3, 27)
 
@@ -35,8 +37,8 @@ func TestAppend(t *testing.T) {
 		t.Error()
 	}
 
-	//此处 0.008， Merkle2是1.022
-	m1 := NewMerklePT(20)
+	//此处 0.008， Merkle2是1.022, 可以用BenchmarkAppend或cpat bench复现
+	m1 := NewMerklePT(20, nil)
 	numAppends := 1
 	for i := 0; i < numAppends; i++ {
 		m1.Append(8192, 1, 8192) // k, depth, numbers
@@ -71,12 +73,12 @@ func TestGenerateExtensionProof(t *testing.T) {
 	}
 
 	for _, table := range tables {
-		oldDigest := table.ms.GetOldDigest(table.oldSize)
-		newDigest := table.ms.GetOldDigest(table.requestedSize)
+		oldDigest := table.ms.mustOldDigest(table.oldSize)
+		newDigest := table.ms.mustOldDigest(table.requestedSize)
 
-		proof := table.ms.GenerateConsistencyProof(table.oldSize, table.requestedSize)
+		proof := table.ms.mustConsistencyProof(table.oldSize, table.requestedSize)
 
-		if !VerifyExtensionProof(oldDigest, newDigest, proof) {
+		if !VerifyExtensionProof(table.ms.Hasher(), oldDigest, newDigest, proof) {
 			t.Log(table.ms.depth)
 			t.Error()
 		}
@@ -84,8 +86,21 @@ func TestGenerateExtensionProof(t *testing.T) {
 
 }
 
+func TestInvalidSizes(t *testing.T) {
+	m := createTestingTree(5, 3)
+	if _, err := m.GenerateConsistencyProof(4, 3); err != ErrInvalidSize {
+		t.Errorf("got %v", err)
+	}
+	if _, err := m.GenerateConsistencyProof(3, 6); err != ErrInvalidSize {
+		t.Errorf("got %v", err)
+	}
+	if _, err := m.GetOldDigest(6); err != ErrInvalidSize {
+		t.Errorf("got %v", err)
+	}
+}
+
 func createTestingTree(size uint32, depth uint32) *MerklePT {
-	m := NewMerklePT(depth)
+	m := NewMerklePT(depth, nil)
 
 	var i uint32
 	for i = 0; i < size; i++ {
@@ -94,9 +109,223 @@ func createTestingTree(size uint32, depth uint32) *MerklePT {
 	return m
 }
 
+// 测试中的大小都不超过m.Size
+func (m *MerklePT) mustOldDigest(oldSize uint32) *Digest {
+	dg, err := m.GetOldDigest(oldSize)
+	if err != nil {
+		panic(err)
+	}
+	return dg
+}
+
+func (m *MerklePT) mustConsistencyProof(oldSize uint32, requestedSize uint32) *MerkleConsistencyProof {
+	proof, err := m.GenerateConsistencyProof(oldSize, requestedSize)
+	if err != nil {
+		panic(err)
+	}
+	return proof
+}
+
 func TestComputeContentHash(t *testing.T) {
-	m := ComputeContentHash([]byte("1"), 0)
+	m := ComputeContentHash(crypto.Default, []byte("1"), 0)
 	if m == nil {
 		t.Error()
 	}
 }
+
+func TestHasherAgility(t *testing.T) {
+	h, err := crypto.NewHasher(crypto.SHA256, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMerklePT(4, h)
+	for i := 0; i < 11; i++ {
+		m.Append(3, 3, 27)
+	}
+
+	oldDigest := m.mustOldDigest(3)
+	newDigest := m.mustOldDigest(11)
+	if newDigest.HashID != crypto.SHA256 || newDigest.HashSize != 32 {
+		t.Error("digest should record the hash function")
+	}
+
+	proof := m.mustConsistencyProof(3, 11)
+	if !VerifyExtensionProof(h, oldDigest, newDigest, proof) {
+		t.Error("proof should verify with the tree's hasher")
+	}
+	if VerifyExtensionProof(crypto.Default, oldDigest, newDigest, proof) {
+		t.Error("proof should not verify with a different hasher")
+	}
+}
+
+func TestInclusionAndLookupProof(t *testing.T) {
+	m := createTestingTree(11, 4)
+
+	for _, size := range []uint32{1, 2, 7, 8, 11} {
+		digest := m.mustOldDigest(size)
+		for epoch := uint32(0); epoch < size; epoch++ {
+			proof, err := m.GenerateInclusionProof(epoch, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyInclusionProof(m.Hasher(), digest, proof) {
+				t.Errorf("inclusion proof for epoch %d in size %d failed", epoch, size)
+			}
+
+			lookup, err := m.GenerateLookupProof(epoch, 26, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyLookupProof(m.Hasher(), 3, 3, digest, lookup) {
+				t.Errorf("lookup proof for epoch %d in size %d failed", epoch, size)
+			}
+		}
+	}
+
+	proof, _ := m.GenerateLookupProof(5, 3, 11)
+	proof.Value = []byte("fake")
+	if VerifyLookupProof(m.Hasher(), 3, 3, m.mustOldDigest(11), proof) {
+		t.Error("modified value should not verify")
+	}
+	if VerifyInclusionProof(m.Hasher(), m.mustOldDigest(10), proof.Inclusion) {
+		t.Error("proof for size 11 should not verify against size 10")
+	}
+
+	if _, err := m.GenerateInclusionProof(11, 11); err != ErrInvalidSize {
+		t.Error("epoch beyond size should fail")
+	}
+	if _, err := m.GenerateLookupProof(0, 27, 11); err != ErrLeafNotFound {
+		t.Error("position beyond the verkle tree should fail")
+	}
+}
+
+func TestAppendValues(t *testing.T) {
+	m := NewMerklePT(1, nil)
+	values := [][]byte{[]byte("alice"), []byte("bob"), []byte("carol")}
+
+	if _, err := m.AppendValues(2, 2, values); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AppendValues(2, 1, values); err != ErrTooManyLeaves {
+		t.Error("3 values should not fit in a 2-ary tree of depth 1")
+	}
+	if epoch, err := m.AppendValues(2, 1, values[:1]); err != nil || epoch != 1 {
+		t.Fatal(epoch, err)
+	}
+	if _, err := m.AppendValues(2, 1, values[:1]); err != ErrTreeFull {
+		t.Error("a tree of depth 1 holds two epochs")
+	}
+
+	got, err := m.GetValues(0)
+	if err != nil || len(got) != 3 || string(got[2]) != "carol" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	proof, err := m.GenerateLookupProof(0, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(proof.Value) != "bob" || !VerifyLookupProof(m.Hasher(), 2, 2, m.mustOldDigest(2), proof) {
+		t.Error("lookup proof for bob failed")
+	}
+}
+
+func TestVerifyMalformedDigest(t *testing.T) {
+	m := createTestingTree(7, 3)
+	oldDigest := m.mustOldDigest(3)
+	newDigest := m.mustOldDigest(7)
+	proof := m.mustConsistencyProof(3, 7)
+
+	short := *newDigest
+	short.Roots = short.Roots[:1]
+	if VerifyExtensionProof(m.Hasher(), oldDigest, &short, proof) {
+		t.Error("digest with missing roots should not verify")
+	}
+	if VerifyExtensionProof(m.Hasher(), newDigest, oldDigest, proof) {
+		t.Error("digest should not shrink")
+	}
+	if VerifyExtensionProof(m.Hasher(), oldDigest, newDigest, &MerkleConsistencyProof{}) {
+		t.Error("proof with missing siblings should not verify")
+	}
+}
+
+func TestVerifyForgedDigest(t *testing.T) {
+	m := createTestingTree(7, 3)
+	h := m.Hasher()
+	sib := h.Hash([]byte("sibling"))
+
+	// 大小5的roots [A, B]: 伪造的大小7的digest把B合进第0个root, A没有被检查
+	old := m.mustOldDigest(5)
+	forged := *old
+	forged.Size = 7
+	forged.Roots = [][]byte{HashChildren(h, old.Roots[1], sib), sib, sib}
+	if VerifyExtensionProof(h, old, &forged, &MerkleConsistencyProof{Siblings: []Sibling{{Hash: sib}}}) {
+		t.Error("forged digest of size 7 should not verify")
+	}
+
+	// 大小相同的分叉 [A, C, X]
+	old = m.mustOldDigest(7)
+	forged = *old
+	forged.Roots = [][]byte{old.Roots[0], old.Roots[2], sib}
+	if VerifyExtensionProof(h, old, &forged, &MerkleConsistencyProof{}) {
+		t.Error("fork of the same size should not verify")
+	}
+
+	// 多余的sibling
+	proof := m.mustConsistencyProof(5, 7)
+	if !VerifyExtensionProof(h, m.mustOldDigest(5), old, proof) {
+		t.Fatal("extension proof failed")
+	}
+	proof.Siblings = append(proof.Siblings, Sibling{Hash: sib})
+	if VerifyExtensionProof(h, m.mustOldDigest(5), old, proof) {
+		t.Error("proof with extra siblings should not verify")
+	}
+}
+
+// 和TestAppend中与Merkle2比较的参数一样: 每个epoch 8192个叶子
+func BenchmarkAppend(b *testing.B) {
+	m := NewMerklePT(20, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Append(8192, 1, 8192)
+	}
+}
+
+func BenchmarkInclusionProof(b *testing.B) {
+	m := createTestingTree(1024, 10)
+	digest := m.mustOldDigest(m.Size)
+	b.Run("generate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.GenerateInclusionProof(uint32(i)%m.Size, m.Size)
+		}
+	})
+	b.Run("verify", func(b *testing.B) {
+		proof, _ := m.GenerateInclusionProof(513, m.Size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !VerifyInclusionProof(m.Hasher(), digest, proof) {
+				b.Fatal("verification failed")
+			}
+		}
+	})
+}
+
+func BenchmarkConsistencyProof(b *testing.B) {
+	m := createTestingTree(1000, 10)
+	old := m.mustOldDigest(513)
+	digest := m.mustOldDigest(m.Size)
+	b.Run("generate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.mustConsistencyProof(513, m.Size)
+		}
+	})
+	b.Run("verify", func(b *testing.B) {
+		proof := m.mustConsistencyProof(513, m.Size)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !VerifyExtensionProof(m.Hasher(), old, digest, proof) {
+				b.Fatal("verification failed")
+			}
+		}
+	})
+}
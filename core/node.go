@@ -4,7 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
-	crypto "github.com/ucbrise/MerkleSquare/lib/crypto"
+	"MerkleVerkle/lib/crypto"
 )
 
 // 先规定为8个字节，用于计算proof的大小。这只是hash的，后面可能会变。
@@ -35,7 +35,9 @@ type LeafNode struct {
 
 	acc []byte // accumulator
 
-	// accVerkle KaryTree
+	header *LeafHeader // 链式MerklePT中叶子commit的header, 否则为nil
+
+	verkle *KaryTree // 这个epoch的verkle tree, 用来生成lookup proof
 }
 
 type index struct {
@@ -48,8 +50,8 @@ type MerkleNode interface {
 	isLeafNode() bool
 	setParent(MerkleNode)
 	getHash() []byte
-	getAcc() []byte //得到accumulator
-	complete()      //prefix完成才能生成merkle,我这里不需要
+	getAcc() []byte           //得到accumulator
+	complete(h crypto.Hasher) //prefix完成才能生成merkle,我这里不需要
 	isComplete() bool
 	isRightChild() bool
 	getParent() MerkleNode
@@ -70,9 +72,9 @@ type MerkleNode interface {
 }
 
 // 创建叶子节点, 这里的acc先使用数字代替，后面补上
-func (node *LeafNode) completeLeaf(acc []byte, epo uint32) {
+func (node *LeafNode) completeLeaf(h crypto.Hasher, acc []byte, epo uint32) {
 
-	contentHash := ComputeContentHash(acc, epo)
+	contentHash := ComputeLeafHash(h, acc, epo, node.header)
 	// 添加verkle tree
 	node.contentHash = contentHash
 	node.hash = contentHash
@@ -123,9 +125,9 @@ func createRootNode(depth uint32) MerkleNode {
 	}
 }
 
-func (node *InternalNode) complete() {
+func (node *InternalNode) complete(h crypto.Hasher) {
 	// hashVal := crypto.Hash(node.leftChild.getHash(), node.rightChild.getHash(), []byte("1"))
-	hashVal := crypto.Hash(node.leftChild.getHash(), node.rightChild.getHash())
+	hashVal := HashChildren(h, node.leftChild.getHash(), node.rightChild.getHash())
 	node.hash = hashVal
 	// node.acc = []byte("1")
 	node.completed = true
@@ -207,6 +209,15 @@ func (node *LeafNode) getSize() int {
 	// size of index
 	total += binary.Size(node.index.depth) + binary.Size(node.index.shift)
 
+	// content hash, accumulator and the epoch's verkle tree
+	total += binary.Size(node.contentHash) + binary.Size(node.acc) + pointerSizeInBytes
+	if node.header != nil {
+		total += binary.Size(node.header.Prev) + binary.Size(node.header.Timestamp) + binary.Size(node.header.Metadata) + pointerSizeInBytes
+	}
+	if node.verkle != nil {
+		total += node.verkle.getSize()
+	}
+
 	return total
 }
 
@@ -221,6 +232,9 @@ func (node *InternalNode) getSize() int {
 	// size of index
 	total += binary.Size(node.index.depth) + binary.Size(node.index.shift)
 
+	// accumulator
+	total += binary.Size(node.acc)
+
 	// right child
 	if node.getRightChild() != nil {
 		total += node.getRightChild().getSize()
@@ -259,7 +273,7 @@ func (node *LeafNode) isLeafNode() bool             { return true }
 func (node *LeafNode) setParent(parent MerkleNode)  { node.parent = parent }
 func (node *LeafNode) getHash() []byte              { return node.hash }
 func (node *LeafNode) getAcc() []byte               { return node.acc }
-func (node *LeafNode) complete()                    {}
+func (node *LeafNode) complete(crypto.Hasher)       {}
 func (node *LeafNode) createLeftChild() MerkleNode  { return &LeafNode{} }
 func (node *LeafNode) createRightChild() MerkleNode { return &LeafNode{} }
 func (node *LeafNode) getRightChild() MerkleNode    { return &LeafNode{} }
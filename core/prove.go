@@ -0,0 +1,82 @@
+package core
+
+import "math/bits"
+
+// HashReader 按层和下标读取完整节点的hash, 例如从snapshot或者静态的tile中读取
+type HashReader func(depth uint32, shift uint32) ([]byte, error)
+
+// forest root的位置
+type rootIndex struct {
+	depth uint32
+	shift uint32
+}
+
+// 大小为size时森林中所有root的位置, 从左到右
+func forestRoots(size uint32) []rootIndex {
+	var roots []rootIndex
+	var start uint32
+	for size > 0 {
+		depth := uint32(31 - bits.LeadingZeros32(size))
+		roots = append(roots, rootIndex{depth: depth, shift: start >> depth})
+		start += 1 << depth
+		size -= 1 << depth
+	}
+	return roots
+}
+
+// ProveInclusion 用read读取节点, 生成第epoch个叶子对大小为size的digest的存在证明,
+// 结果与MerklePT.GenerateInclusionProof相同。leafAcc是这个epoch的verkle tree的commitment
+func ProveInclusion(read HashReader, epoch uint32, size uint32, leafAcc []byte) (*MerkleInclusionProof, error) {
+	if epoch >= size {
+		return nil, ErrInvalidSize
+	}
+	content, err := read(0, epoch)
+	if err != nil {
+		return nil, err
+	}
+	proof := &MerkleInclusionProof{
+		Epoch: epoch,
+		Size:  size,
+		Leaf:  LeafHash{NodeContentHash: content, Acc: leafAcc},
+	}
+	depth := GetOldDepth(epoch, size)
+	for j := uint32(0); j < depth; j++ {
+		hash, err := read(j, (epoch>>j)^1)
+		if err != nil {
+			return nil, err
+		}
+		proof.Siblings = append(proof.Siblings, Sibling{Hash: hash})
+	}
+	return proof, nil
+}
+
+// ProveConsistency 用read读取节点, 生成oldSize到newSize的consistency proof,
+// 结果与MerklePT.GenerateConsistencyProof相同
+func ProveConsistency(read HashReader, oldSize uint32, newSize uint32) (*MerkleConsistencyProof, error) {
+	if oldSize == 0 || oldSize > newSize {
+		return nil, ErrInvalidSize
+	}
+	oldRoots, newRoots := forestRoots(oldSize), forestRoots(newSize)
+	proof := &MerkleConsistencyProof{}
+	for i, root := range oldRoots {
+		if root == newRoots[i] {
+			continue
+		}
+		// 从旧森林的最后一个root向上走到新的root, 收集右侧的兄弟节点
+		last := oldRoots[len(oldRoots)-1]
+		siblings := []Sibling{}
+		for depth, shift := last.depth, last.shift; depth < newRoots[i].depth; depth, shift = depth+1, shift/2 {
+			if isRight(shift) {
+				continue
+			}
+			hash, err := read(depth, shift+1)
+			if err != nil {
+				return nil, err
+			}
+			siblings = append(siblings, Sibling{Hash: hash})
+		}
+		proof.Siblings = siblings
+		break
+	}
+	return proof, nil
+}
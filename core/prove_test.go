@@ -0,0 +1,43 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 从节点hash生成的proof与MerklePT生成的proof相同
+func TestProveFromHashes(t *testing.T) {
+	m := createTestingTree(23, 5)
+	snap := m.Snapshot()
+	for newSize := uint32(1); newSize <= snap.Size; newSize++ {
+		s, _ := snap.At(newSize)
+		for oldSize := uint32(1); oldSize <= newSize; oldSize++ {
+			got, err := ProveConsistency(s.NodeHash, oldSize, newSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := m.mustConsistencyProof(oldSize, newSize); !reflect.DeepEqual(got, want) {
+				t.Errorf("consistency %d -> %d: got %v, want %v", oldSize, newSize, got, want)
+			}
+		}
+		for epoch := uint32(0); epoch < newSize; epoch++ {
+			acc, _ := s.LeafAcc(epoch)
+			got, err := ProveInclusion(s.NodeHash, epoch, newSize, acc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := s.GenerateInclusionProof(epoch)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("inclusion of %d in %d differs", epoch, newSize)
+			}
+		}
+	}
+
+	// 不完整的节点不能读取
+	if _, err := snap.NodeHash(3, 2); err != ErrInvalidSize {
+		t.Errorf("got %v", err)
+	}
+	if _, err := snap.NodeHash(2, 4); err != nil {
+		t.Error(err)
+	}
+}
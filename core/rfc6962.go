@@ -0,0 +1,274 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"math/bits"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+// RFC 6962/9162兼容模式。
+// 使用crypto.RFC6962时MerklePT的叶子为SHA-256(0x00 || acc || epoch), epoch为4字节小端序,
+// 内部节点为SHA-256(0x01 || left || right)。森林的roots正好是RFC中按小于n的最大2的幂次切分得到的完整子树,
+// 所以RFC的tree head, audit path和consistency proof都可以由完整节点的hash计算, CT的monitor可以直接验证。
+
+// RFCTreeHead 是RFC 6962格式的tree head, 整棵树只有一个root
+type RFCTreeHead struct {
+	TreeSize uint64 `json:"tree_size"`
+	RootHash []byte `json:"sha256_root_hash"`
+}
+
+// RFCTreeHead 由digest的roots从右向左合并得到RFC的root, 空树的root是空串的hash
+func (dg *Digest) RFCTreeHead(h crypto.Hasher) *RFCTreeHead {
+	return &RFCTreeHead{TreeSize: uint64(dg.Size), RootHash: dg.FoldRoots(h)}
+}
+
+// SignedTreeHead 是RFC 6962 3.5的signed tree head, 也就是CT get-sth的响应。timestamp为毫秒
+type SignedTreeHead struct {
+	TreeSize          uint64 `json:"tree_size"`
+	Timestamp         uint64 `json:"timestamp"`
+	RootHash          []byte `json:"sha256_root_hash"`
+	TreeHeadSignature []byte `json:"tree_head_signature"`
+}
+
+// tree_head_signature是TLS的DigitallySigned结构, Ed25519的SignatureScheme为0x0807(RFC 8446)
+const (
+	sthVersionV1      = 0
+	sthTypeTreeHash   = 1
+	sthSignatureHigh  = 0x08
+	sthSignatureLow   = 0x07
+	sthSignatureBytes = 4 + ed25519.SignatureSize
+)
+
+// 被签名的TreeHeadSignature: version | signature_type | timestamp | tree_size | root hash, 整数为大端序。
+// 以0x00 0x01开头, 不会与digest和checkpoint的签名消息混淆
+func treeHeadSignatureInput(sth *SignedTreeHead) []byte {
+	msg := []byte{sthVersionV1, sthTypeTreeHash}
+	msg = binary.BigEndian.AppendUint64(msg, sth.Timestamp)
+	msg = binary.BigEndian.AppendUint64(msg, sth.TreeSize)
+	return append(msg, sth.RootHash...)
+}
+
+// SignTreeHead 用log的私钥对tree head签名
+func SignTreeHead(key ed25519.PrivateKey, head *RFCTreeHead, timestamp uint64) *SignedTreeHead {
+	sth := &SignedTreeHead{TreeSize: head.TreeSize, Timestamp: timestamp, RootHash: head.RootHash}
+	sig := ed25519.Sign(key, treeHeadSignatureInput(sth))
+	sth.TreeHeadSignature = append([]byte{sthSignatureHigh, sthSignatureLow, 0, ed25519.SignatureSize}, sig...)
+	return sth
+}
+
+// VerifySignedTreeHead 验证signed tree head的签名, 只接受Ed25519
+func VerifySignedTreeHead(pub ed25519.PublicKey, sth *SignedTreeHead) bool {
+	sig := sth.TreeHeadSignature
+	if len(pub) != ed25519.PublicKeySize || len(sig) != sthSignatureBytes ||
+		sig[0] != sthSignatureHigh || sig[1] != sthSignatureLow || binary.BigEndian.Uint16(sig[2:4]) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pub, treeHeadSignatureInput(sth), sig[4:])
+}
+
+// RFCRoot 用read读取节点, 计算大小为size的树的RFC root
+func RFCRoot(h crypto.Hasher, read HashReader, size uint32) ([]byte, error) {
+	if size == 0 {
+		return h.Hash(), nil
+	}
+	return rfcRange(h, read, 0, size)
+}
+
+// RFCInclusionProof 用read读取节点, 生成第index个叶子在大小为size的树中的audit path(RFC 9162 2.1.3.1),
+// 从叶子向root排列
+func RFCInclusionProof(h crypto.Hasher, read HashReader, index uint32, size uint32) ([][]byte, error) {
+	if index >= size {
+		return nil, ErrInvalidSize
+	}
+	return rfcPath(h, read, index, 0, size)
+}
+
+// RFCConsistencyProof 用read读取节点, 生成oldSize到newSize的consistency proof(RFC 9162 2.1.4.1)。
+// oldSize为0或者等于newSize时proof为空
+func RFCConsistencyProof(h crypto.Hasher, read HashReader, oldSize uint32, newSize uint32) ([][]byte, error) {
+	if oldSize > newSize {
+		return nil, ErrInvalidSize
+	}
+	if oldSize == 0 {
+		return [][]byte{}, nil
+	}
+	return rfcSubproof(h, read, oldSize, 0, newSize, true)
+}
+
+// 小于n的最大的2的幂次, n > 1
+func rfcSplit(n uint32) uint32 {
+	return 1 << (31 - bits.LeadingZeros32(n-1))
+}
+
+// 叶子[start, end)的MTH。RFC的切分总是对齐的, 所以大小为2的幂次的区间就是一个完整节点
+func rfcRange(h crypto.Hasher, read HashReader, start uint32, end uint32) ([]byte, error) {
+	n := end - start
+	if n&(n-1) == 0 {
+		depth := uint32(bits.TrailingZeros32(n))
+		return read(depth, start>>depth)
+	}
+	k := rfcSplit(n)
+	left, err := rfcRange(h, read, start, start+k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := rfcRange(h, read, start+k, end)
+	if err != nil {
+		return nil, err
+	}
+	return HashChildren(h, left, right), nil
+}
+
+// PATH(m, D[start:end]), m是叶子的绝对下标
+func rfcPath(h crypto.Hasher, read HashReader, m uint32, start uint32, end uint32) ([][]byte, error) {
+	if end-start == 1 {
+		return [][]byte{}, nil
+	}
+	k := rfcSplit(end - start)
+	var path [][]byte
+	var sibling []byte
+	var err error
+	if m < start+k {
+		if path, err = rfcPath(h, read, m, start, start+k); err != nil {
+			return nil, err
+		}
+		sibling, err = rfcRange(h, read, start+k, end)
+	} else {
+		if path, err = rfcPath(h, read, m, start+k, end); err != nil {
+			return nil, err
+		}
+		sibling, err = rfcRange(h, read, start, start+k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(path, sibling), nil
+}
+
+// SUBPROOF(m, D[start:end], b), m是旧树在这个区间中的叶子个数
+func rfcSubproof(h crypto.Hasher, read HashReader, m uint32, start uint32, end uint32, b bool) ([][]byte, error) {
+	n := end - start
+	if m == n {
+		if b {
+			return [][]byte{}, nil
+		}
+		hash, err := rfcRange(h, read, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{hash}, nil
+	}
+	k := rfcSplit(n)
+	var proof [][]byte
+	var hash []byte
+	var err error
+	if m <= k {
+		if proof, err = rfcSubproof(h, read, m, start, start+k, b); err != nil {
+			return nil, err
+		}
+		hash, err = rfcRange(h, read, start+k, end)
+	} else {
+		if proof, err = rfcSubproof(h, read, m-k, start+k, end, false); err != nil {
+			return nil, err
+		}
+		hash, err = rfcRange(h, read, start, start+k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(proof, hash), nil
+}
+
+// VerifyRFCInclusion 按照RFC 9162 2.1.3.2验证leafHash是大小为size、root为root的树中的第index个叶子
+func VerifyRFCInclusion(h crypto.Hasher, index uint32, size uint32, leafHash []byte, path [][]byte, root []byte) bool {
+	if index >= size {
+		return false
+	}
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = HashChildren(h, p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = HashChildren(h, r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
+
+// VerifyRFCConsistency 按照RFC 9162 2.1.4.2验证大小为oldSize的树是大小为newSize的树的前缀
+func VerifyRFCConsistency(h crypto.Hasher, oldSize uint32, newSize uint32, oldRoot []byte, newRoot []byte, proof [][]byte) bool {
+	switch {
+	case oldSize > newSize:
+		return false
+	case oldSize == 0:
+		return len(proof) == 0
+	case oldSize == newSize:
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot)
+	case len(proof) == 0:
+		return false
+	}
+	// 旧树是完整的子树时proof中省略了它的root
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = HashChildren(h, c, fr)
+			sr = HashChildren(h, c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = HashChildren(h, sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot)
+}
+
+// RFCTreeHead 返回snapshot的RFC tree head
+func (s *Snapshot) RFCTreeHead() *RFCTreeHead {
+	return s.Digest().RFCTreeHead(s.m.hasher)
+}
+
+// RFCLeafHash 返回第epoch个叶子的RFC leaf hash, 也就是MerklePT中叶子的hash
+func (s *Snapshot) RFCLeafHash(epoch uint32) ([]byte, error) {
+	return s.NodeHash(0, epoch)
+}
+
+// RFCInclusionProof 返回第epoch个叶子对snapshot的RFC audit path
+func (s *Snapshot) RFCInclusionProof(epoch uint32) ([][]byte, error) {
+	return RFCInclusionProof(s.m.hasher, s.NodeHash, epoch, s.Size)
+}
+
+// RFCConsistencyProof 返回oldSize到newSize的RFC consistency proof, newSize不能超过snapshot的大小
+func (s *Snapshot) RFCConsistencyProof(oldSize uint32, newSize uint32) ([][]byte, error) {
+	if newSize > s.Size {
+		return nil, ErrInvalidSize
+	}
+	return RFCConsistencyProof(s.m.hasher, s.NodeHash, oldSize, newSize)
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+func rfcHasher(t *testing.T) crypto.Hasher {
+	h, err := crypto.NewHasher(crypto.RFC6962, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func decodeHashes(t *testing.T, hs ...string) [][]byte {
+	out := [][]byte{}
+	for _, s := range hs {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// RFC 6962的测试向量, 与certificate-transparency的实现相同
+func TestRFC6962Vectors(t *testing.T) {
+	h := rfcHasher(t).(crypto.TreeHasher)
+	var leaves [][]byte
+	for _, s := range []string{"", "00", "10", "2021", "3031", "40414243",
+		"5051525354555657", "606162636465666768696a6b6c6d6e6f"} {
+		data, _ := hex.DecodeString(s)
+		leaves = append(leaves, h.HashLeaf(data))
+	}
+	// 直接按定义计算完整节点
+	var node func(depth, shift uint32) []byte
+	node = func(depth, shift uint32) []byte {
+		if depth == 0 {
+			return leaves[shift]
+		}
+		return h.HashChildren(node(depth-1, shift*2), node(depth-1, shift*2+1))
+	}
+	read := func(depth, shift uint32) ([]byte, error) {
+		if (uint64(shift)+1)<<depth > uint64(len(leaves)) {
+			return nil, ErrInvalidSize
+		}
+		return node(depth, shift), nil
+	}
+
+	roots := decodeHashes(t,
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	)
+	for i, want := range roots {
+		got, err := RFCRoot(h, read, uint32(i+1))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("root of %d: got %x, %v", i+1, got, err)
+		}
+	}
+	empty, _ := RFCRoot(h, read, 0)
+	if hex.EncodeToString(empty) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("empty root: got %x", empty)
+	}
+
+	inclusion := []struct {
+		index, size uint32
+		path        [][]byte
+	}{
+		{0, 1, decodeHashes(t)},
+		{0, 8, decodeHashes(t,
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4")},
+		{5, 8, decodeHashes(t,
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7")},
+		{2, 3, decodeHashes(t,
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125")},
+		{1, 5, decodeHashes(t,
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b")},
+	}
+	for _, v := range inclusion {
+		got, err := RFCInclusionProof(h, read, v.index, v.size)
+		if err != nil || !equalHashes(got, v.path) {
+			t.Errorf("path %d/%d: got %x, %v", v.index, v.size, got, err)
+		}
+		root := roots[v.size-1]
+		if !VerifyRFCInclusion(h, v.index, v.size, leaves[v.index], v.path, root) {
+			t.Errorf("path %d/%d should verify", v.index, v.size)
+		}
+		if VerifyRFCInclusion(h, v.index, v.size, leaves[(v.index+1)%8], v.path, root) {
+			t.Errorf("path %d/%d should not verify for another leaf", v.index, v.size)
+		}
+	}
+
+	consistency := []struct {
+		oldSize, newSize uint32
+		proof            [][]byte
+	}{
+		{1, 1, decodeHashes(t)},
+		{1, 8, decodeHashes(t,
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4")},
+		{6, 8, decodeHashes(t,
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7")},
+		{2, 5, decodeHashes(t,
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b")},
+	}
+	for _, v := range consistency {
+		got, err := RFCConsistencyProof(h, read, v.oldSize, v.newSize)
+		if err != nil || !equalHashes(got, v.proof) {
+			t.Errorf("consistency %d -> %d: got %x, %v", v.oldSize, v.newSize, got, err)
+		}
+		oldRoot, newRoot := roots[v.oldSize-1], roots[v.newSize-1]
+		if !VerifyRFCConsistency(h, v.oldSize, v.newSize, oldRoot, newRoot, v.proof) {
+			t.Errorf("consistency %d -> %d should verify", v.oldSize, v.newSize)
+		}
+		if v.oldSize != v.newSize && VerifyRFCConsistency(h, v.oldSize, v.newSize, roots[v.oldSize], newRoot, v.proof) {
+			t.Errorf("consistency %d -> %d should not verify with another old root", v.oldSize, v.newSize)
+		}
+	}
+}
+
+func equalHashes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// 使用RFC6962的MerklePT: digest的roots合并后就是RFC的root, 每个大小的proof都能按RFC验证
+func TestRFC6962Tree(t *testing.T) {
+	h := rfcHasher(t)
+	m := NewMerklePT(5, h)
+	for i := 0; i < 13; i++ {
+		m.Append(3, 3, 27)
+	}
+	snap := m.Snapshot()
+	for newSize := uint32(1); newSize <= snap.Size; newSize++ {
+		s, _ := snap.At(newSize)
+		head := s.RFCTreeHead()
+		root, err := RFCRoot(h, s.NodeHash, newSize)
+		if err != nil || !bytes.Equal(root, head.RootHash) || head.TreeSize != uint64(newSize) {
+			t.Fatalf("tree head of %d differs", newSize)
+		}
+		for epoch := uint32(0); epoch < newSize; epoch++ {
+			path, err := s.RFCInclusionProof(epoch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leaf, _ := s.RFCLeafHash(epoch)
+			acc, _ := s.LeafAcc(epoch)
+			if !bytes.Equal(leaf, ComputeContentHash(h, acc, epoch)) {
+				t.Fatal("leaf hash should be the MerklePT leaf")
+			}
+			if !VerifyRFCInclusion(h, epoch, newSize, leaf, path, head.RootHash) {
+				t.Errorf("path %d/%d should verify", epoch, newSize)
+			}
+		}
+		for oldSize := uint32(1); oldSize <= newSize; oldSize++ {
+			proof, err := snap.RFCConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			oldHead := m.mustOldDigest(oldSize).RFCTreeHead(h)
+			if !VerifyRFCConsistency(h, oldSize, newSize, oldHead.RootHash, head.RootHash, proof) {
+				t.Errorf("consistency %d -> %d should verify", oldSize, newSize)
+			}
+		}
+	}
+
+	// 原来的proof格式仍然可以使用
+	if !VerifyExtensionProof(h, m.mustOldDigest(5), m.mustOldDigest(13), m.mustConsistencyProof(5, 13)) {
+		t.Error("extension proof should verify with the RFC hasher")
+	}
+	if _, err := crypto.WithTreeID(h, []byte("id")); err == nil {
+		t.Error("RFC 6962 hashes should not take a tree id")
+	}
+}
+
+func TestSignedTreeHead(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	head := &RFCTreeHead{TreeSize: 7, RootHash: bytes.Repeat([]byte{1}, 32)}
+	sth := SignTreeHead(key, head, 1700000000000)
+	if !VerifySignedTreeHead(pub, sth) {
+		t.Fatal("valid signed tree head rejected")
+	}
+	// DigitallySigned: ed25519(0x0807) | 2字节长度 | 签名
+	if !bytes.Equal(sth.TreeHeadSignature[:4], []byte{8, 7, 0, 64}) {
+		t.Errorf("got signature header %x", sth.TreeHeadSignature[:4])
+	}
+	for _, tamper := range []func(*SignedTreeHead){
+		func(s *SignedTreeHead) { s.Timestamp++ },
+		func(s *SignedTreeHead) { s.TreeSize++ },
+		func(s *SignedTreeHead) { s.RootHash = bytes.Repeat([]byte{2}, 32) },
+		func(s *SignedTreeHead) { s.TreeHeadSignature = s.TreeHeadSignature[1:] },
+	} {
+		forged := *sth
+		tamper(&forged)
+		if VerifySignedTreeHead(pub, &forged) {
+			t.Errorf("forged tree head %+v accepted", forged)
+		}
+	}
+}
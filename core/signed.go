@@ -0,0 +1,119 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+)
+
+// 签名消息的前缀, 与其他用途的签名区分
+const signedDigestContext = "MerkleVerkle/signed-digest"
+
+// SignedDigest 是log对digest的签名, 签名同时覆盖log的tree ID
+type SignedDigest struct {
+	TreeID    []byte
+	Digest    *Digest
+	Signature []byte
+}
+
+type signedDigestJSON struct {
+	Version   int     `json:"version"`
+	TreeID    string  `json:"tree_id"`
+	Digest    *Digest `json:"digest"`
+	Signature string  `json:"signature"`
+}
+
+// 被签名的消息: context | tree ID | digest的二进制编码
+func signedDigestMessage(treeID []byte, dg *Digest) []byte {
+	e := newEncoder()
+	e.bytes([]byte(signedDigestContext))
+	e.bytes(treeID)
+	data, _ := dg.MarshalBinary()
+	e.bytes(data)
+	return e.buf
+}
+
+// SignDigest 用log的私钥对digest签名
+func SignDigest(key ed25519.PrivateKey, treeID []byte, dg *Digest) *SignedDigest {
+	return &SignedDigest{
+		TreeID:    treeID,
+		Digest:    dg,
+		Signature: ed25519.Sign(key, signedDigestMessage(treeID, dg)),
+	}
+}
+
+// VerifySignedDigest 验证签名, 公钥长度不对时返回false
+func VerifySignedDigest(pub ed25519.PublicKey, sd *SignedDigest) bool {
+	if len(pub) != ed25519.PublicKeySize || sd.Digest == nil {
+		return false
+	}
+	return ed25519.Verify(pub, signedDigestMessage(sd.TreeID, sd.Digest), sd.Signature)
+}
+
+// MarshalBinary 编码SignedDigest, Digest不能为nil
+func (sd *SignedDigest) MarshalBinary() ([]byte, error) {
+	if sd.Digest == nil {
+		return nil, errors.New("core: signed digest without digest")
+	}
+	data, err := sd.Digest.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	e := newEncoder()
+	e.bytes(sd.TreeID)
+	e.bytes(data)
+	e.bytes(sd.Signature)
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码SignedDigest
+func (sd *SignedDigest) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	var res SignedDigest
+	res.TreeID = d.bytes()
+	digest := d.bytes()
+	res.Signature = d.bytes()
+	if err := d.finish(); err != nil {
+		return err
+	}
+	res.Digest = &Digest{}
+	if err := res.Digest.UnmarshalBinary(digest); err != nil {
+		return err
+	}
+	*sd = res
+	return nil
+}
+
+// MarshalJSON 编码SignedDigest, Digest不能为nil
+func (sd *SignedDigest) MarshalJSON() ([]byte, error) {
+	if sd.Digest == nil {
+		return nil, errors.New("core: signed digest without digest")
+	}
+	return json.Marshal(signedDigestJSON{
+		Version:   jsonVersion,
+		TreeID:    encodeB64(sd.TreeID),
+		Digest:    sd.Digest,
+		Signature: encodeB64(sd.Signature),
+	})
+}
+
+// UnmarshalJSON 解码SignedDigest
+func (sd *SignedDigest) UnmarshalJSON(data []byte) error {
+	var v signedDigestJSON
+	if err := unmarshalStrict(data, &v, &v.Version); err != nil {
+		return err
+	}
+	if v.Digest == nil {
+		return errors.New("core: signed digest without digest")
+	}
+	treeID, err := decodeB64(v.TreeID)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeB64(v.Signature)
+	if err != nil {
+		return err
+	}
+	*sd = SignedDigest{TreeID: treeID, Digest: v.Digest, Signature: sig}
+	return nil
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+)
+
+func TestSignedDigest(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := createTestingTree(5, 4)
+	sd := SignDigest(key, []byte("tree-a"), m.mustOldDigest(5))
+	if !VerifySignedDigest(pub, sd) {
+		t.Fatal("valid signature rejected")
+	}
+
+	// 二进制和JSON编码后签名仍然有效
+	data, _ := sd.MarshalBinary()
+	var fromBinary SignedDigest
+	if err := fromBinary.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(sd)
+	var fromJSON SignedDigest
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifySignedDigest(pub, &fromBinary) || !VerifySignedDigest(pub, &fromJSON) {
+		t.Error("decoded signature rejected")
+	}
+
+	// 签名覆盖tree ID和digest
+	moved := *sd
+	moved.TreeID = []byte("tree-b")
+	older := *sd
+	older.Digest = m.mustOldDigest(4)
+	other, _, _ := ed25519.GenerateKey(nil)
+	if VerifySignedDigest(pub, &moved) || VerifySignedDigest(pub, &older) || VerifySignedDigest(other, sd) {
+		t.Error("signature accepted for a different log or digest")
+	}
+}
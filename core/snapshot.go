@@ -0,0 +1,93 @@
+package core
+
+// Snapshot 是MerklePT在大小为Size时的只读视图。
+// MerklePT只会追加, 所以Size之前的节点不会再改变, 多个goroutine可以在写入的同时并发读取同一个snapshot。
+type Snapshot struct {
+	m    *MerklePT
+	Size uint32
+}
+
+// Snapshot 返回当前大小的只读视图
+func (m *MerklePT) Snapshot() *Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return &Snapshot{m: m, Size: m.Size}
+}
+
+// CurrentSize 返回当前的叶子个数
+func (m *MerklePT) CurrentSize() uint32 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.Size
+}
+
+// At 返回大小为size的更早的snapshot
+func (s *Snapshot) At(size uint32) (*Snapshot, error) {
+	if size > s.Size {
+		return nil, ErrInvalidSize
+	}
+	return &Snapshot{m: s.m, Size: size}, nil
+}
+
+// Digest 返回snapshot的digest
+func (s *Snapshot) Digest() *Digest {
+	// snapshot的大小不会超过MerklePT的大小
+	dg, _ := s.m.GetOldDigest(s.Size)
+	return dg
+}
+
+// GetOldDigest 返回大小为oldSize时的digest, oldSize不能超过snapshot的大小
+func (s *Snapshot) GetOldDigest(oldSize uint32) (*Digest, error) {
+	if oldSize > s.Size {
+		return nil, ErrInvalidSize
+	}
+	return s.m.GetOldDigest(oldSize)
+}
+
+// GenerateConsistencyProof 生成oldSize到newSize的consistency proof, 需要 0 < oldSize <= newSize <= s.Size
+func (s *Snapshot) GenerateConsistencyProof(oldSize uint32, newSize uint32) (*MerkleConsistencyProof, error) {
+	if oldSize == 0 || oldSize > newSize || newSize > s.Size {
+		return nil, ErrInvalidSize
+	}
+	return s.m.GenerateConsistencyProof(oldSize, newSize)
+}
+
+// GenerateInclusionProof 生成第epoch个叶子对snapshot digest的存在证明
+func (s *Snapshot) GenerateInclusionProof(epoch uint32) (*MerkleInclusionProof, error) {
+	return s.m.GenerateInclusionProof(epoch, s.Size)
+}
+
+// GenerateLookupProof 生成第epoch个verkle tree中第pos个叶子对snapshot digest的证明
+func (s *Snapshot) GenerateLookupProof(epoch uint32, pos uint32) (*LookupProof, error) {
+	return s.m.GenerateLookupProof(epoch, pos, s.Size)
+}
+
+// NodeHash 返回第depth层第shift个节点的hash, 节点在snapshot中必须是完整的, 也就是(shift+1)*2^depth <= Size。
+// 完整的节点不会再改变, 所以可以导出为静态的tile
+func (s *Snapshot) NodeHash(depth uint32, shift uint32) ([]byte, error) {
+	if depth > s.m.depth || !nodeComplete(depth, shift, s.Size) {
+		return nil, ErrInvalidSize
+	}
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return s.m.getNode(depth, shift).getHash(), nil
+}
+
+// LeafAcc 返回第epoch个叶子的acc, 也就是这个epoch的verkle tree的commitment
+func (s *Snapshot) LeafAcc(epoch uint32) ([]byte, error) {
+	if epoch >= s.Size {
+		return nil, ErrInvalidSize
+	}
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return s.m.getLeafNode(epoch).getAcc(), nil
+}
+
+// 大小为size时第depth层第shift个节点是否完整
+func nodeComplete(depth uint32, shift uint32, size uint32) bool {
+	return (uint64(shift)+1)<<depth <= uint64(size)
+}
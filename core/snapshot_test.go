@@ -0,0 +1,55 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+// 使用 go test -race 运行
+func TestConcurrentAppendAndProofs(t *testing.T) {
+	m := NewMerklePT(8, nil)
+	m.Append(3, 2, 9)
+	first := m.Snapshot()
+	firstDigest := first.Digest()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			m.Append(3, 2, 9)
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				snap := m.Snapshot()
+				digest := snap.Digest()
+
+				consistency, err := snap.GenerateConsistencyProof(first.Size, snap.Size)
+				if err != nil || !VerifyExtensionProof(m.Hasher(), firstDigest, digest, consistency) {
+					t.Errorf("consistency proof from %d to %d failed", first.Size, snap.Size)
+					return
+				}
+
+				epoch := uint32(i) % snap.Size
+				lookup, err := snap.GenerateLookupProof(epoch, 4)
+				if err != nil || !VerifyLookupProof(m.Hasher(), 3, 2, digest, lookup) {
+					t.Errorf("lookup proof for epoch %d in size %d failed", epoch, snap.Size)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m.CurrentSize() != 101 {
+		t.Error()
+	}
+	if _, err := first.GenerateConsistencyProof(1, 2); err != ErrInvalidSize {
+		t.Error("snapshot should not see later appends")
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+var (
+	ErrTimestamp     = errors.New("core: timestamp must be later than the previous epoch")
+	ErrNoTimestamp   = errors.New("core: epochs have no timestamps")
+	ErrBeforeLogTime = errors.New("core: time is before the first epoch")
+)
+
+// 链式MerklePT中timestamp必须严格递增, 开始时可以有一段0, 见EpochAt
+func timestampAfter(prev uint64, ts uint64) bool {
+	return ts > prev || (ts == 0 && prev == 0)
+}
+
+// CheckTimestamp 检查下一个epoch可以使用timestamp ts
+func (m *MerklePT) CheckTimestamp(ts uint64) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.checkTimestamp(ts)
+}
+
+func (m *MerklePT) checkTimestamp(ts uint64) error {
+	if !m.chained {
+		if ts != 0 {
+			return ErrNotChained
+		}
+		return nil
+	}
+	if !timestampAfter(m.lastTimestamp(), ts) {
+		return ErrTimestamp
+	}
+	return nil
+}
+
+// LastTimestamp 返回最后一个epoch的timestamp, 没有时为0
+func (m *MerklePT) LastTimestamp() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lastTimestamp()
+}
+
+func (m *MerklePT) lastTimestamp() uint64 {
+	if !m.chained || m.Size == 0 {
+		return 0
+	}
+	return m.getLeafNode(m.Size - 1).(*LeafNode).header.Timestamp
+}
+
+// Timestamp 返回第epoch个epoch的timestamp
+func (s *Snapshot) Timestamp(epoch uint32) (uint64, error) {
+	header, err := s.LeafHeader(epoch)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil || header.Timestamp == 0 {
+		return 0, ErrNoTimestamp
+	}
+	return header.Timestamp, nil
+}
+
+// EpochAt 返回时刻t时最新的epoch, 也就是timestamp不晚于t的最后一个epoch。
+// 链式log可以从一段timestamp为0的epoch开始, 这些epoch不属于任何时刻
+func (s *Snapshot) EpochAt(t uint64) (uint32, error) {
+	if s.Size == 0 {
+		return 0, ErrBeforeLogTime
+	}
+	// timestamp是一段0之后严格递增, 先二分查找第一个有timestamp的epoch
+	lo, hi := uint32(0), s.Size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ts, err := s.timestamp(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ts == 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == s.Size {
+		return 0, ErrNoTimestamp
+	}
+	first, err := s.timestamp(lo)
+	if err != nil {
+		return 0, err
+	}
+	if t < first {
+		return 0, ErrBeforeLogTime
+	}
+	// 再二分查找第一个晚于t的epoch
+	lo, hi = lo+1, s.Size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ts, err := s.timestamp(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ts > t {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo - 1, nil
+}
+
+// 第epoch个epoch的timestamp, 没有时为0
+func (s *Snapshot) timestamp(epoch uint32) (uint64, error) {
+	header, err := s.LeafHeader(epoch)
+	if err != nil || header == nil {
+		return 0, err
+	}
+	return header.Timestamp, nil
+}
+
+// TimeProof 证明时刻Time时最新的epoch是Epoch.Epoch: 它的timestamp不晚于Time,
+// 下一个epoch的timestamp晚于Time。Next为nil时Epoch是digest中的最后一个epoch。
+// 时刻Time的digest大小为Epoch.Epoch+1, 它的bagged root就是Next的header中的Prev
+type TimeProof struct {
+	Time  uint64
+	Epoch *MerkleInclusionProof
+	Next  *MerkleInclusionProof
+}
+
+type timeProofJSON struct {
+	Version int                   `json:"version"`
+	Time    uint64                `json:"time"`
+	Epoch   *MerkleInclusionProof `json:"epoch"`
+	Next    *MerkleInclusionProof `json:"next"`
+}
+
+// GenerateTimeProof 生成时刻t对snapshot digest的TimeProof
+func (s *Snapshot) GenerateTimeProof(t uint64) (*TimeProof, error) {
+	epoch, err := s.EpochAt(t)
+	if err != nil {
+		return nil, err
+	}
+	proof := &TimeProof{Time: t}
+	if proof.Epoch, err = s.GenerateInclusionProof(epoch); err != nil {
+		return nil, err
+	}
+	if epoch+1 < s.Size {
+		if proof.Next, err = s.GenerateInclusionProof(epoch + 1); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+// DigestSize 返回时刻Time的digest的大小
+func (p *TimeProof) DigestSize() uint32 {
+	return p.Epoch.Epoch + 1
+}
+
+// VerifyTimeProof 验证digest中两个相邻的epoch的timestamp包含了proof.Time
+func VerifyTimeProof(h crypto.Hasher, digest *Digest, proof *TimeProof) bool {
+	if proof.Epoch == nil || proof.Epoch.Leaf.Header == nil || !VerifyInclusionProof(h, digest, proof.Epoch) {
+		return false
+	}
+	ts := proof.Epoch.Leaf.Header.Timestamp
+	if ts == 0 || ts > proof.Time {
+		return false
+	}
+	if proof.Next == nil {
+		return proof.Epoch.Epoch == digest.Size-1
+	}
+	if proof.Next.Leaf.Header == nil || proof.Next.Epoch != proof.Epoch.Epoch+1 || !VerifyInclusionProof(h, digest, proof.Next) {
+		return false
+	}
+	next := proof.Next.Leaf.Header.Timestamp
+	return next > proof.Time && next > ts
+}
+
+// VerifyDigestAtTime 验证asOf是digest之前时刻proof.Time的digest
+func VerifyDigestAtTime(h crypto.Hasher, digest *Digest, proof *TimeProof, asOf *Digest) bool {
+	if !VerifyTimeProof(h, digest, proof) || !asOf.WellFormed(h) || asOf.Size != proof.DigestSize() {
+		return false
+	}
+	if proof.Next == nil {
+		return bytes.Equal(asOf.BaggedRoot(h), digest.BaggedRoot(h))
+	}
+	return bytes.Equal(asOf.BaggedRoot(h), proof.Next.Leaf.Header.Prev)
+}
+
+// MarshalBinary 编码TimeProof, Epoch不能为nil
+func (p *TimeProof) MarshalBinary() ([]byte, error) {
+	if p.Epoch == nil {
+		return nil, errors.New("core: time proof without epoch")
+	}
+	e := newEncoder()
+	e.uint64(p.Time)
+	p.Epoch.encode(e)
+	if p.Next == nil {
+		e.uint8(0)
+	} else {
+		e.uint8(1)
+		p.Next.encode(e)
+	}
+	return e.buf, nil
+}
+
+// UnmarshalBinary 解码TimeProof
+func (p *TimeProof) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	res := TimeProof{Time: d.uint64(), Epoch: &MerkleInclusionProof{}}
+	res.Epoch.decode(d)
+	switch d.uint8() {
+	case 0:
+	case 1:
+		res.Next = &MerkleInclusionProof{}
+		res.Next.decode(d)
+	default:
+		if d.err == nil {
+			d.err = ErrInvalidEncoding
+		}
+	}
+	if err := d.finish(); err != nil {
+		return err
+	}
+	*p = res
+	return nil
+}
+
+// MarshalJSON 编码TimeProof, Epoch不能为nil
+func (p *TimeProof) MarshalJSON() ([]byte, error) {
+	if p.Epoch == nil {
+		return nil, errors.New("core: time proof without epoch")
+	}
+	return json.Marshal(timeProofJSON{Version: jsonVersion, Time: p.Time, Epoch: p.Epoch, Next: p.Next})
+}
+
+// UnmarshalJSON 解码TimeProof
+func (p *TimeProof) UnmarshalJSON(data []byte) error {
+	var v timeProofJSON
+	if err := unmarshalStrict(data, &v, &v.Version); err != nil {
+		return err
+	}
+	if v.Epoch == nil {
+		return errors.New("core: time proof without epoch")
+	}
+	*p = TimeProof{Time: v.Time, Epoch: v.Epoch, Next: v.Next}
+	return nil
+}
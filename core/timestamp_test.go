@@ -0,0 +1,141 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+func TestTimestampMonotonic(t *testing.T) {
+	m := NewChainedMerklePT(4, nil)
+	if _, err := m.AppendTreeWithHeader(newTestingKaryTree(t, 2, 2, nil), 1000, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range []uint64{1000, 999, 0} {
+		if _, err := m.AppendTreeWithHeader(newTestingKaryTree(t, 2, 2, nil), ts, nil); !errors.Is(err, ErrTimestamp) {
+			t.Errorf("%d: got %v", ts, err)
+		}
+	}
+	if m.CurrentSize() != 1 || m.LastTimestamp() != 1000 {
+		t.Errorf("rejected epochs should not be appended")
+	}
+	if err := NewMerklePT(4, nil).CheckTimestamp(1); !errors.Is(err, ErrNotChained) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestTimeProof(t *testing.T) {
+	h := crypto.Default
+	m := createChainedTree(t, 11) // timestamp为1000到1010
+	snap := m.Snapshot()
+	digest := snap.Digest()
+
+	tables := []struct {
+		t     uint64
+		epoch uint32
+	}{{1000, 0}, {1005, 5}, {1010, 10}, {5000, 10}}
+	for _, table := range tables {
+		epoch, err := snap.EpochAt(table.t)
+		if err != nil || epoch != table.epoch {
+			t.Errorf("%d: got %d, %v", table.t, epoch, err)
+		}
+		proof, err := snap.GenerateTimeProof(table.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyTimeProof(h, digest, proof) {
+			t.Errorf("%d: time proof failed", table.t)
+		}
+		if !VerifyDigestAtTime(h, digest, proof, m.mustOldDigest(table.epoch+1)) {
+			t.Errorf("%d: digest at time failed", table.t)
+		}
+		if VerifyDigestAtTime(h, digest, proof, m.mustOldDigest(table.epoch)) {
+			t.Errorf("%d: older digest should fail", table.t)
+		}
+	}
+	if _, err := snap.EpochAt(999); !errors.Is(err, ErrBeforeLogTime) {
+		t.Errorf("got %v", err)
+	}
+
+	// 修改时间后proof失败
+	proof, _ := snap.GenerateTimeProof(1005)
+	for _, at := range []uint64{1004, 1006} {
+		changed := *proof
+		changed.Time = at
+		if VerifyTimeProof(h, digest, &changed) {
+			t.Errorf("%d: proof for another time should fail", at)
+		}
+	}
+	skipped := *proof
+	skipped.Next, _ = snap.GenerateInclusionProof(7)
+	if VerifyTimeProof(h, digest, &skipped) {
+		t.Error("epochs that are not adjacent should fail")
+	}
+
+	// 没有timestamp的MerklePT
+	if _, err := createTestingTree(3, 4).Snapshot().EpochAt(1); !errors.Is(err, ErrNoTimestamp) {
+		t.Errorf("got %v", err)
+	}
+}
+
+// 开始的epoch没有timestamp
+func TestTimeProofUntimedPrefix(t *testing.T) {
+	h := crypto.Default
+	m := NewChainedMerklePT(4, h)
+	for _, ts := range []uint64{0, 0, 1000, 1001, 1002} {
+		if _, err := m.AppendTreeWithHeader(newTestingKaryTree(t, 2, 2, h), ts, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := m.Snapshot()
+	for _, table := range []struct {
+		t     uint64
+		epoch uint32
+	}{{1000, 2}, {1001, 3}, {5000, 4}} {
+		epoch, err := snap.EpochAt(table.t)
+		if err != nil || epoch != table.epoch {
+			t.Errorf("%d: got %d, %v", table.t, epoch, err)
+		}
+		proof, err := snap.GenerateTimeProof(table.t)
+		if err != nil || !VerifyDigestAtTime(h, snap.Digest(), proof, m.mustOldDigest(table.epoch+1)) {
+			t.Errorf("%d: time proof failed: %v", table.t, err)
+		}
+	}
+	if _, err := snap.EpochAt(999); !errors.Is(err, ErrBeforeLogTime) {
+		t.Errorf("got %v", err)
+	}
+
+	untimed := NewChainedMerklePT(4, h)
+	untimed.AppendTreeWithHeader(newTestingKaryTree(t, 2, 2, h), 0, nil)
+	if _, err := untimed.Snapshot().EpochAt(1); !errors.Is(err, ErrNoTimestamp) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestTimeProofEncoding(t *testing.T) {
+	m := createChainedTree(t, 5)
+	digest := m.mustOldDigest(5)
+	for _, at := range []uint64{1002, 1004} {
+		proof, _ := m.Snapshot().GenerateTimeProof(at)
+
+		data, _ := proof.MarshalBinary()
+		var decoded TimeProof
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyTimeProof(crypto.Default, digest, &decoded) {
+			t.Errorf("%d: decoded binary proof failed", at)
+		}
+
+		data, _ = json.Marshal(proof)
+		decoded = TimeProof{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyTimeProof(crypto.Default, digest, &decoded) {
+			t.Errorf("%d: decoded JSON proof failed", at)
+		}
+	}
+}
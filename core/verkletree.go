@@ -1,44 +1,94 @@
 package core
 
 import (
-	"crypto/sha256"
+	"bytes"
 	"encoding/binary"
-	"encoding/hex"
+	"errors"
+
+	"MerkleVerkle/lib/crypto"
+)
+
+var (
+	ErrLeafNotFound      = errors.New("core: leaf not found")
+	ErrInvalidVerkleTree = errors.New("core: verkle tree needs k >= 2 and depth >= 1")
+)
+
+// acc中叶子和中间节点hash的前缀, 与RFC 6962相同, 叶子不能被当作中间节点
+const (
+	verkleLeafPrefix = 0x00
+	verkleNodePrefix = 0x01
 )
 
 type Node struct {
 	Children []*Node // 子节点
 	Hash     []byte  // 当前节点的哈希
+	Value    []byte  // 叶子节点的值, Hash = hash(Value)
+	acc      []byte  // ComputeAcc计算的定长hash
+}
+
+// LookupLevel 是lookup proof中的一层: 路径上节点所有子节点的hash, 以及路径经过的子节点下标
+type LookupLevel struct {
+	Index    uint32
+	Children [][]byte
 }
 
 type KaryTree struct {
-	Root  *Node  // 树的根节点
-	K     uint32 // 分叉因子
-	Depth uint32 // 树的高度
+	Root   *Node  // 树的根节点
+	K      uint32 // 分叉因子
+	Depth  uint32 // 树的高度
+	hasher crypto.Hasher
 }
 
-// 创建新的K叉树
-func NewKaryTree(k uint32, depth uint32) *KaryTree {
-	if depth < 1 {
-		panic("树的深度至少为1")
+// 创建新的K叉树, h为nil时使用crypto.Default。k至少为2, depth至少为1
+func NewKaryTree(k uint32, depth uint32, h crypto.Hasher) (*KaryTree, error) {
+	if k < 2 || depth < 1 {
+		return nil, ErrInvalidVerkleTree
 	}
-	return &KaryTree{
-		Root:  &Node{},
-		K:     k,
-		Depth: depth,
+	if h == nil {
+		h = crypto.Default
 	}
+	return &KaryTree{
+		Root:   &Node{},
+		K:      k,
+		Depth:  depth,
+		hasher: h,
+	}, nil
 }
 
 // 为树添加叶子节点
 func (t *KaryTree) AddLeaf(pos uint32) {
 	posAsByte := make([]byte, 4)
 	binary.LittleEndian.PutUint32(posAsByte, pos)
-	leaf := &Node{Hash: posAsByte}
-	if !t.addLeaf(t.Root, leaf, 1) {
+	if !t.AddValue(posAsByte) {
 		panic("无法添加更多叶子节点：树已满")
 	}
 }
 
+// 添加值为value的叶子节点, 树满时返回false
+func (t *KaryTree) AddValue(value []byte) bool {
+	leaf := &Node{Hash: t.hasher.Hash(value), Value: value}
+	return t.addLeaf(t.Root, leaf, 1)
+}
+
+// 按添加的顺序返回所有叶子的值
+func (t *KaryTree) Values() [][]byte {
+	var values [][]byte
+	var walk func(node *Node, depth uint32)
+	walk = func(node *Node, depth uint32) {
+		if depth == t.Depth {
+			for _, leaf := range node.Children {
+				values = append(values, leaf.Value)
+			}
+			return
+		}
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(t.Root, 1)
+	return values
+}
+
 // 递归地添加叶子节点
 func (t *KaryTree) addLeaf(current *Node, leaf *Node, depth uint32) bool {
 	// 如果达到树的最大深度，则添加叶子节点
@@ -79,16 +129,118 @@ func (t *KaryTree) CalculateHashes(node *Node) []byte {
 		t.CalculateHashes(child)
 		// hashes += child.Hash
 		hashes = append(hashes, child.Hash...)
-		println("中间节点hashes:", hashes)
 	}
-	// node.Hash = t.calculateHash(hashes)
 	node.Hash = hashes
-	println("最终的hashes:", node.Hash)
 	return node.Hash
 }
 
-// 计算字符串的SHA-256哈希值
-func (t *KaryTree) calculateHash(input string) string {
-	hashBytes := sha256.Sum256([]byte(input))
-	return hex.EncodeToString(hashBytes[:])
+// ComputeAcc 计算并返回root的定长hash, 作为MerklePT叶子中的acc:
+// 叶子为 hash(0x00 | 值), 中间节点为 hash(0x01 | 子节点hash的拼接)。
+// CalculateHashes的拼接随叶子个数变长, tile和lookup proof需要定长的acc;
+// 前缀区分叶子和中间节点, 否则中间节点的子节点拼接可以作为叶子的值伪造lookup proof。
+// 空树的root当作没有子节点的中间节点, acc为 hash(0x01), 同样是定长的
+func (t *KaryTree) ComputeAcc() []byte {
+	return t.computeAcc(t.Root)
+}
+
+func (t *KaryTree) computeAcc(node *Node) []byte {
+	if len(node.Children) == 0 && node.Hash != nil {
+		node.acc = t.hasher.Hash([]byte{verkleLeafPrefix}, node.Value)
+		return node.acc
+	}
+	hashes := []byte{verkleNodePrefix}
+	for _, child := range node.Children {
+		hashes = append(hashes, t.computeAcc(child)...)
+	}
+	node.acc = t.hasher.Hash(hashes)
+	return node.acc
+}
+
+// GenerateLookupProof 返回第pos个叶子的值和从叶子到root的路径(自底向上), 需要先调用ComputeAcc
+func (t *KaryTree) GenerateLookupProof(pos uint32) ([]byte, []LookupLevel, error) {
+	if t.K < 2 || t.Depth < 1 {
+		return nil, nil, ErrInvalidVerkleTree
+	}
+	// 叶子是按深度优先从左到右添加的, pos的K进制表示就是从root到叶子的路径
+	digits := make([]uint32, t.Depth)
+	p := pos
+	for i := int(t.Depth) - 1; i >= 0; i-- {
+		digits[i] = p % t.K
+		p /= t.K
+	}
+	if p != 0 {
+		return nil, nil, ErrLeafNotFound
+	}
+
+	levels := make([]LookupLevel, t.Depth)
+	node := t.Root
+	for i, digit := range digits {
+		if int(digit) >= len(node.Children) {
+			return nil, nil, ErrLeafNotFound
+		}
+		children := make([][]byte, len(node.Children))
+		for j, child := range node.Children {
+			children[j] = child.acc
+		}
+		levels[len(digits)-1-i] = LookupLevel{Index: digit, Children: children}
+		node = node.Children[digit]
+	}
+	return node.Value, levels, nil
+}
+
+// LookupPosition 从路径上的下标计算叶子的位置。
+// 路径的长度必须是depth, 每层最多k个子节点, 否则位置不唯一
+func LookupPosition(k uint32, depth uint32, levels []LookupLevel) (uint32, bool) {
+	if uint32(len(levels)) != depth {
+		return 0, false
+	}
+	var pos, base uint64 = 0, 1
+	for _, level := range levels {
+		if uint32(len(level.Children)) > k || level.Index >= uint32(len(level.Children)) {
+			return 0, false
+		}
+		pos += uint64(level.Index) * base
+		base *= uint64(k)
+	}
+	if pos >= 1<<32 {
+		return 0, false
+	}
+	return uint32(pos), true
+}
+
+// VerifyLookupPath 从叶子的值沿路径计算root并与acc比较, 同时验证路径经过第pos个叶子。
+// k和depth是verkle tree的参数
+func VerifyLookupPath(h crypto.Hasher, k uint32, depth uint32, pos uint32, value []byte, levels []LookupLevel, acc []byte) bool {
+	if p, ok := LookupPosition(k, depth, levels); !ok || p != pos {
+		return false
+	}
+	cur := h.Hash([]byte{verkleLeafPrefix}, value)
+	for _, level := range levels {
+		if !bytes.Equal(level.Children[level.Index], cur) {
+			return false
+		}
+		hashes := []byte{verkleNodePrefix}
+		for _, child := range level.Children {
+			// 子节点hash定长, 拼接才没有歧义
+			if len(child) != h.Size() {
+				return false
+			}
+			hashes = append(hashes, child...)
+		}
+		cur = h.Hash(hashes)
+	}
+	return bytes.Equal(cur, acc)
+}
+
+// 树占用的字节数, 和MerkleNode.getSize一样只计算指针和数据
+func (t *KaryTree) getSize() int {
+	var walk func(node *Node) int
+	walk = func(node *Node) int {
+		total := pointerSizeInBytes*len(node.Children) + len(node.Hash) + len(node.acc) + len(node.Value)
+		for _, child := range node.Children {
+			total += walk(child)
+		}
+		return total
+	}
+	return pointerSizeInBytes + binary.Size(t.K) + binary.Size(t.Depth) + walk(t.Root)
 }
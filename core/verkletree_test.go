@@ -1,14 +1,94 @@
 package core
 
 import (
+	"errors"
 	"testing"
+
+	"MerkleVerkle/lib/crypto"
 )
 
+func newTestingKaryTree(t *testing.T, k, depth uint32, h crypto.Hasher) *KaryTree {
+	t.Helper()
+	tree, err := NewKaryTree(k, depth, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestNewKaryTreeParams(t *testing.T) {
+	for _, c := range []struct{ k, depth uint32 }{{0, 2}, {1, 2}, {2, 0}} {
+		if _, err := NewKaryTree(c.k, c.depth, nil); !errors.Is(err, ErrInvalidVerkleTree) {
+			t.Errorf("k=%d depth=%d: got %v", c.k, c.depth, err)
+		}
+	}
+	// 直接构造的树也不能在lookup proof中除以0
+	tree := &KaryTree{Root: &Node{}, Depth: 2, hasher: crypto.Default}
+	if _, _, err := tree.GenerateLookupProof(0); !errors.Is(err, ErrInvalidVerkleTree) {
+		t.Errorf("got %v", err)
+	}
+}
+
 func TestAddLeaf(t *testing.T) {
-	v := NewKaryTree(3, 3)
+	v := newTestingKaryTree(t, 3, 3, nil)
 	numTotal := 27
 	for i := 0; i < numTotal; i++ {
 		v.AddLeaf(uint32(i))
 	}
 	v.CalculateHashes(v.Root)
+	// CalculateHashes是所有叶子hash的拼接, acc是定长的
+	if len(v.Root.Hash) != numTotal*v.hasher.Size() || len(v.ComputeAcc()) != v.hasher.Size() {
+		t.Errorf("got hashes of %d bytes", len(v.Root.Hash))
+	}
+}
+
+func TestEmptyKaryTreeAcc(t *testing.T) {
+	v := newTestingKaryTree(t, 3, 3, nil)
+	if acc := v.ComputeAcc(); len(acc) != v.hasher.Size() {
+		t.Errorf("empty tree acc has %d bytes", len(acc))
+	}
+}
+
+func TestKaryTreeLookupProof(t *testing.T) {
+	v := newTestingKaryTree(t, 3, 3, nil)
+	for i := 0; i < 20; i++ {
+		v.AddLeaf(uint32(i))
+	}
+	acc := v.ComputeAcc()
+
+	for i := uint32(0); i < 20; i++ {
+		value, path, err := v.GenerateLookupProof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyLookupPath(v.hasher, 3, 3, i, value, path, acc) {
+			t.Errorf("lookup proof for leaf %d failed", i)
+		}
+	}
+
+	if _, _, err := v.GenerateLookupProof(20); err != ErrLeafNotFound {
+		t.Error("missing leaf should fail")
+	}
+
+	// 位置由路径上的下标决定, 不能改成其他叶子
+	value, path, _ := v.GenerateLookupProof(5)
+	for _, pos := range []uint32{0, 4, 6} {
+		if VerifyLookupPath(v.hasher, 3, 3, pos, value, path, acc) {
+			t.Errorf("proof for leaf 5 verified as leaf %d", pos)
+		}
+	}
+	if VerifyLookupPath(v.hasher, 2, 3, 5, value, path, acc) {
+		t.Error("proof verified with another k")
+	}
+
+	// 中间节点的子节点拼接不能作为叶子的值
+	var children []byte
+	for _, child := range path[0].Children {
+		children = append(children, child...)
+	}
+	for _, depth := range []uint32{2, 3} {
+		if VerifyLookupPath(v.hasher, 3, depth, 1, children, path[1:], acc) {
+			t.Errorf("depth %d: internal node verified as a leaf", depth)
+		}
+	}
 }
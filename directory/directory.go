@@ -0,0 +1,589 @@
+// Package directory 是建立在MerklePT上的key transparency目录。
+// 每个epoch的全部公钥放在一个按key排序的verkle tree中, verkle tree的commitment作为MerklePT的一个叶子。
+// 用户的key由Indexer计算, 默认是用户名的hash; 为了不泄露用户名, 应该使用VRF(见lib/vrf)。
+// 排序后首尾各加一个哨兵叶子, 所以不存在的用户一定落在两个相邻的叶子之间,
+// 用这两个叶子的lookup proof就可以证明用户不存在。
+//
+// NewHiding创建的目录中, 叶子只保存key和hash(opening, 状态|公钥)的commitment, opening和明文保存在私有的storage中。
+// 查询时只揭示被查询用户的opening, 不存在证明中相邻的叶子只暴露key和commitment。
+package directory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/storage"
+)
+
+var (
+	ErrUserExists   = errors.New("directory: user already registered")
+	ErrUserNotFound = errors.New("directory: user not registered")
+	ErrRevoked      = errors.New("directory: user key revoked")
+	ErrFull         = errors.New("directory: too many users for the verkle tree")
+	ErrNoEpoch      = errors.New("directory: nothing published yet")
+	ErrInvalidProof = errors.New("directory: proof verification failed")
+	ErrCorrupted    = errors.New("directory: epoch is not a directory")
+)
+
+// Status 是用户在某个epoch中的状态
+type Status uint8
+
+const (
+	StatusAbsent  Status = iota // 没有注册, 或者哨兵
+	StatusActive                // 有一个有效的公钥
+	StatusRevoked               // 公钥已经被撤销
+
+	statusHidden // 叶子中只有状态和公钥的commitment
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusAbsent:
+		return "absent"
+	case StatusActive:
+		return "active"
+	case StatusRevoked:
+		return "revoked"
+	}
+	return fmt.Sprintf("status(%d)", uint8(s))
+}
+
+// 目录中的一个叶子: key | status | 公钥, 或者 key | statusHidden | commitment
+type entry struct {
+	key        []byte
+	status     Status
+	pubKey     []byte
+	opening    []byte // 只在hiding目录的服务端有
+	commitment []byte // 只在解码hidden叶子时有
+}
+
+func (e *entry) encode() []byte {
+	buf := make([]byte, 0, len(e.key)+1+len(e.pubKey))
+	buf = append(buf, e.key...)
+	buf = append(buf, byte(e.status))
+	return append(buf, e.pubKey...)
+}
+
+// 被commitment隐藏的部分: status | 公钥
+func payload(status Status, pubKey []byte) []byte {
+	return append([]byte{byte(status)}, pubKey...)
+}
+
+// 叶子的值, 有opening时隐藏状态和公钥
+func (e *entry) leaf(h crypto.Hasher) []byte {
+	if e.opening == nil {
+		return e.encode()
+	}
+	buf := append(bytes.Clone(e.key), byte(statusHidden))
+	return append(buf, core.Commit(h, e.opening, payload(e.status, e.pubKey))...)
+}
+
+func decodeEntry(value []byte, keySize int) (*entry, error) {
+	if len(value) < keySize+1 || Status(value[keySize]) > statusHidden {
+		return nil, ErrCorrupted
+	}
+	e := &entry{
+		key:    value[:keySize],
+		status: Status(value[keySize]),
+		pubKey: value[keySize+1:],
+	}
+	if e.status == statusHidden {
+		// commitment和key一样长, 都是log的hash长度
+		if len(e.pubKey) != keySize {
+			return nil, ErrCorrupted
+		}
+		e.commitment, e.pubKey = e.pubKey, nil
+	}
+	return e, nil
+}
+
+// hiding目录的私有记录: opening | key | status | 公钥, 哨兵的opening为0
+func (e *entry) encodePrivate() []byte {
+	opening := make([]byte, core.OpeningSize)
+	copy(opening, e.opening)
+	return append(opening, e.encode()...)
+}
+
+func decodePrivate(value []byte, keySize int) (*entry, error) {
+	if len(value) < core.OpeningSize {
+		return nil, ErrCorrupted
+	}
+	e, err := decodeEntry(value[core.OpeningSize:], keySize)
+	if err != nil || e.status == statusHidden {
+		return nil, ErrCorrupted
+	}
+	if e.status != StatusAbsent {
+		e.opening = value[:core.OpeningSize]
+	}
+	return e, nil
+}
+
+// 首尾的哨兵
+func sentinels(keySize int) (*entry, *entry) {
+	return &entry{key: make([]byte, keySize)}, &entry{key: bytes.Repeat([]byte{0xff}, keySize)}
+}
+
+// Indexer 计算用户在目录中的key和key的证明, key的长度必须等于log的hash长度
+type Indexer interface {
+	Index(user string) (key []byte, proof []byte, err error)
+}
+
+// IndexVerifier 验证Indexer的证明并返回key
+type IndexVerifier interface {
+	VerifyIndex(user string, proof []byte) (key []byte, ok bool)
+}
+
+// 默认的Indexer: key是用户名的hash, 没有证明。任何人都可以从key反查用户名
+type hashIndexer struct {
+	hasher crypto.Hasher
+}
+
+func (i hashIndexer) Index(user string) ([]byte, []byte, error) {
+	return i.hasher.Hash([]byte("directory/user"), []byte(user)), nil, nil
+}
+
+func (i hashIndexer) VerifyIndex(user string, proof []byte) ([]byte, bool) {
+	if len(proof) != 0 {
+		return nil, false
+	}
+	key, _, _ := i.Index(user)
+	return key, true
+}
+
+// Directory 是一个key transparency目录, 可以并发使用。
+// Register, Update和Revoke的修改在Publish之后才会出现在新的epoch中。
+type Directory struct {
+	mu      sync.Mutex
+	log     *ledger.Log
+	hasher  crypto.Hasher
+	indexer Indexer
+	private storage.Storage   // hiding目录保存opening和明文, 否则为nil
+	users   map[string]*entry // key -> 最新的状态, 包括还没有发布的修改
+	changed bool
+}
+
+func privateKey(epoch uint32) []byte {
+	return []byte("directory/epoch/" + strconv.FormatUint(uint64(epoch), 10))
+}
+
+// New 在log上创建目录, log中最后一个epoch是当前的公钥集合。idx为nil时key是用户名的hash
+func New(l *ledger.Log, idx Indexer) (*Directory, error) {
+	return open(l, idx, nil)
+}
+
+// NewHiding 创建叶子隐藏状态和公钥的目录, opening和明文保存在private中, private不能公开
+func NewHiding(l *ledger.Log, idx Indexer, private storage.Storage) (*Directory, error) {
+	if private == nil {
+		return nil, errors.New("directory: hiding directory needs a private storage")
+	}
+	return open(l, idx, private)
+}
+
+func open(l *ledger.Log, idx Indexer, private storage.Storage) (*Directory, error) {
+	d := &Directory{
+		log:     l,
+		hasher:  l.Tree().Hasher(),
+		indexer: idx,
+		private: private,
+		users:   make(map[string]*entry),
+	}
+	if d.indexer == nil {
+		d.indexer = hashIndexer{d.hasher}
+	}
+	size := l.Tree().CurrentSize()
+	if size == 0 {
+		return d, nil
+	}
+	entries, err := d.load(size - 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries[1 : len(entries)-1] {
+		d.users[string(e.key)] = e
+	}
+	return d, nil
+}
+
+// 读取第epoch个epoch的所有叶子, hiding目录从私有记录中读取并与log中的叶子比较
+func (d *Directory) load(epoch uint32) ([]*entry, error) {
+	values, err := d.log.Tree().GetValues(epoch)
+	if err != nil {
+		return nil, err
+	}
+	if d.private == nil {
+		entries, err := d.decodeEpoch(values, decodeEntry)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.status == statusHidden {
+				return nil, fmt.Errorf("%w: epoch %d is hidden, open it with NewHiding", ErrCorrupted, epoch)
+			}
+		}
+		return entries, nil
+	}
+
+	data, err := d.private.Get(privateKey(epoch))
+	if err != nil {
+		return nil, fmt.Errorf("directory: private record of epoch %d: %w", epoch, err)
+	}
+	records, err := ledger.DecodeValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: private record of epoch %d", ErrCorrupted, epoch)
+	}
+	entries, err := d.decodeEpoch(records, decodePrivate)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != len(values) {
+		return nil, ErrCorrupted
+	}
+	for i, e := range entries {
+		if !bytes.Equal(e.leaf(d.hasher), values[i]) {
+			return nil, fmt.Errorf("%w: private record of epoch %d does not match the log", ErrCorrupted, epoch)
+		}
+	}
+	return entries, nil
+}
+
+// 检查epoch的格式: 哨兵在首尾, key严格递增
+func (d *Directory) decodeEpoch(values [][]byte, decode func([]byte, int) (*entry, error)) ([]*entry, error) {
+	keySize := d.hasher.Size()
+	if len(values) < 2 {
+		return nil, ErrCorrupted
+	}
+	entries := make([]*entry, len(values))
+	for i, value := range values {
+		e, err := decode(value, keySize)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && bytes.Compare(entries[i-1].key, e.key) >= 0 {
+			return nil, ErrCorrupted
+		}
+		entries[i] = e
+	}
+	first, last := sentinels(keySize)
+	if !bytes.Equal(entries[0].encode(), first.encode()) || !bytes.Equal(entries[len(entries)-1].encode(), last.encode()) {
+		return nil, ErrCorrupted
+	}
+	return entries, nil
+}
+
+// 计算用户的key并检查长度
+func (d *Directory) index(user string) ([]byte, []byte, error) {
+	key, proof, err := d.indexer.Index(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(key) != d.hasher.Size() {
+		return nil, nil, fmt.Errorf("directory: index of length %d, want %d", len(key), d.hasher.Size())
+	}
+	return key, proof, nil
+}
+
+// 目录最多能放的用户数, 两个位置留给哨兵
+func (d *Directory) capacity() uint64 {
+	cfg := d.log.Config()
+	n := uint64(1)
+	for i := uint32(0); i < cfg.VerkleDepth && n < 1<<32; i++ {
+		n *= uint64(cfg.K)
+	}
+	return n - 2
+}
+
+// Register 注册一个新用户, 被撤销的用户可以重新注册
+func (d *Directory) Register(user string, pubKey []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, _, err := d.index(user)
+	if err != nil {
+		return err
+	}
+	e, ok := d.users[string(key)]
+	if ok && e.status != StatusRevoked {
+		return ErrUserExists
+	}
+	if !ok && uint64(len(d.users)) >= d.capacity() {
+		return ErrFull
+	}
+	first, last := sentinels(len(key))
+	if bytes.Equal(key, first.key) || bytes.Equal(key, last.key) {
+		return fmt.Errorf("directory: user %q collides with a sentinel", user)
+	}
+	d.users[string(key)] = &entry{key: key, status: StatusActive, pubKey: bytes.Clone(pubKey)}
+	d.changed = true
+	return nil
+}
+
+// Update 替换用户的公钥
+func (d *Directory) Update(user string, pubKey []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, err := d.active(user)
+	if err != nil {
+		return err
+	}
+	d.users[string(e.key)] = &entry{key: e.key, status: StatusActive, pubKey: bytes.Clone(pubKey)}
+	d.changed = true
+	return nil
+}
+
+// Revoke 撤销用户的公钥, 之后的epoch中用户的状态为StatusRevoked
+func (d *Directory) Revoke(user string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, err := d.active(user)
+	if err != nil {
+		return err
+	}
+	d.users[string(e.key)] = &entry{key: e.key, status: StatusRevoked}
+	d.changed = true
+	return nil
+}
+
+func (d *Directory) active(user string) (*entry, error) {
+	key, _, err := d.index(user)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := d.users[string(key)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if e.status == StatusRevoked {
+		return nil, ErrRevoked
+	}
+	return e, nil
+}
+
+// Pending 返回是否有还没有发布的修改
+func (d *Directory) Pending() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.changed
+}
+
+// Publish 把当前的公钥集合作为一个新的epoch添加到log中, 返回新的epoch
+func (d *Directory) Publish() (uint32, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries := make([]*entry, 0, len(d.users)+2)
+	for _, e := range d.users {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+	first, last := sentinels(d.hasher.Size())
+	entries = append([]*entry{first}, append(entries, last)...)
+
+	// 每个epoch使用新的opening, 否则不变的公钥在不同epoch中的commitment相同
+	if d.private != nil {
+		for i, e := range entries[1 : len(entries)-1] {
+			opening, err := core.NewOpening()
+			if err != nil {
+				return 0, err
+			}
+			entries[i+1] = &entry{key: e.key, status: e.status, pubKey: e.pubKey, opening: opening}
+		}
+	}
+	values := make([][]byte, len(entries))
+	for i, e := range entries {
+		values[i] = e.leaf(d.hasher)
+	}
+
+	// 先在log的锁中保存私有记录, 否则log中的epoch可能无法打开
+	epoch, err := d.log.AppendWith(values, func(epoch uint32) error {
+		if d.private == nil {
+			return nil
+		}
+		records := make([][]byte, len(entries))
+		for i, e := range entries {
+			records[i] = e.encodePrivate()
+		}
+		return d.private.Put(privateKey(epoch), ledger.EncodeValues(records))
+	})
+	if err != nil {
+		return 0, err
+	}
+	d.changed = false
+	return epoch, nil
+}
+
+// Digest 返回当前的digest, LookUp和Monitor的proof都对这个大小的digest生成
+func (d *Directory) Digest() *core.Digest {
+	return d.log.Tree().Snapshot().Digest()
+}
+
+// Verifier 返回验证这个目录的proof需要的参数, idx是Indexer对应的IndexVerifier, 默认的Indexer传nil
+func (d *Directory) Verifier(idx IndexVerifier) *Verifier {
+	cfg := d.log.Config()
+	return &Verifier{Hasher: d.hasher, K: cfg.K, VerkleDepth: cfg.VerkleDepth, Index: idx}
+}
+
+// LookupResult 是用户在一个epoch中的状态和证明。
+// IndexProof证明用户的key, 用户存在时Proofs只有一个, 否则是夹住用户key的两个相邻叶子的proof。
+// 叶子被隐藏时Opening打开叶子中的commitment
+type LookupResult struct {
+	Epoch      uint32
+	Status     Status
+	PubKey     []byte
+	Opening    []byte
+	IndexProof []byte
+	Proofs     []*core.LookupProof
+}
+
+// LookUp 返回用户在第epoch个epoch中的公钥和对当前digest的证明
+func (d *Directory) LookUp(user string, epoch uint32) (*LookupResult, error) {
+	key, proof, err := d.index(user)
+	if err != nil {
+		return nil, err
+	}
+	return d.lookUp(d.log.Tree().Snapshot(), key, proof, epoch)
+}
+
+// Monitor 返回用户在[from, to]中每个epoch的状态, 所有证明都对同一个digest生成
+func (d *Directory) Monitor(user string, from uint32, to uint32) ([]*LookupResult, error) {
+	if from > to {
+		return nil, fmt.Errorf("directory: invalid epoch range [%d, %d]", from, to)
+	}
+	key, proof, err := d.index(user)
+	if err != nil {
+		return nil, err
+	}
+	snap := d.log.Tree().Snapshot()
+	var res []*LookupResult
+	for epoch := from; ; epoch++ {
+		r, err := d.lookUp(snap, key, proof, epoch)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+		if epoch == to {
+			return res, nil
+		}
+	}
+}
+
+func (d *Directory) lookUp(snap *core.Snapshot, key []byte, indexProof []byte, epoch uint32) (*LookupResult, error) {
+	if snap.Size == 0 {
+		return nil, ErrNoEpoch
+	}
+	if epoch >= snap.Size {
+		return nil, core.ErrInvalidSize
+	}
+	entries, err := d.load(epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	// 第一个key不小于用户key的叶子, 哨兵保证0 < i < len(entries)
+	i := sort.Search(len(entries), func(i int) bool { return bytes.Compare(entries[i].key, key) >= 0 })
+	res := &LookupResult{Epoch: epoch, IndexProof: indexProof}
+	positions := []uint32{uint32(i)}
+	if bytes.Equal(entries[i].key, key) {
+		res.Status = entries[i].status
+		res.PubKey = entries[i].pubKey
+		res.Opening = entries[i].opening
+	} else {
+		positions = []uint32{uint32(i - 1), uint32(i)}
+	}
+	for _, pos := range positions {
+		proof, err := snap.GenerateLookupProof(epoch, pos)
+		if err != nil {
+			return nil, err
+		}
+		res.Proofs = append(res.Proofs, proof)
+	}
+	return res, nil
+}
+
+// Verifier 验证目录的证明
+type Verifier struct {
+	Hasher      crypto.Hasher
+	K           uint32
+	VerkleDepth uint32
+	Index       IndexVerifier // nil表示key是用户名的hash
+}
+
+// VerifyLookUp 验证user在res.Epoch中的状态
+func (v *Verifier) VerifyLookUp(digest *core.Digest, user string, res *LookupResult) error {
+	idx := v.Index
+	if idx == nil {
+		idx = hashIndexer{v.Hasher}
+	}
+	key, ok := idx.VerifyIndex(user, res.IndexProof)
+	if !ok || len(key) != v.Hasher.Size() {
+		return ErrInvalidProof
+	}
+	var entries []*entry
+	var positions []uint32
+	for _, proof := range res.Proofs {
+		if proof == nil || proof.Epoch != res.Epoch || !core.VerifyLookupProof(v.Hasher, v.K, v.VerkleDepth, digest, proof) {
+			return ErrInvalidProof
+		}
+		e, err := decodeEntry(proof.Value, v.Hasher.Size())
+		if err != nil {
+			return ErrInvalidProof
+		}
+		entries = append(entries, e)
+		positions = append(positions, proof.Position)
+	}
+
+	switch len(entries) {
+	case 1:
+		// 存在: 叶子的key就是用户的key
+		e := entries[0]
+		if !bytes.Equal(e.key, key) {
+			return ErrInvalidProof
+		}
+		if e.status == statusHidden {
+			if res.Status != StatusActive && res.Status != StatusRevoked {
+				return ErrInvalidProof
+			}
+			if !core.VerifyCommitment(v.Hasher, e.commitment, res.Opening, payload(res.Status, res.PubKey)) {
+				return ErrInvalidProof
+			}
+		} else if e.status == StatusAbsent || res.Status != e.status || !bytes.Equal(res.PubKey, e.pubKey) || res.Opening != nil {
+			return ErrInvalidProof
+		}
+	case 2:
+		// 不存在: 两个相邻的叶子夹住用户的key
+		if positions[1] != positions[0]+1 || bytes.Compare(entries[0].key, key) >= 0 || bytes.Compare(key, entries[1].key) >= 0 {
+			return ErrInvalidProof
+		}
+		if res.Status != StatusAbsent || res.PubKey != nil || res.Opening != nil {
+			return ErrInvalidProof
+		}
+	default:
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyMonitor 验证user在[from, to]中每个epoch的状态, results必须按epoch连续
+func (v *Verifier) VerifyMonitor(digest *core.Digest, user string, from uint32, to uint32, results []*LookupResult) error {
+	if from > to || uint64(len(results)) != uint64(to-from)+1 {
+		return ErrInvalidProof
+	}
+	for i, res := range results {
+		if res.Epoch != from+uint32(i) {
+			return ErrInvalidProof
+		}
+		if err := v.VerifyLookUp(digest, user, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package directory
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/storage"
+)
+
+func newTestDirectory(t *testing.T, store storage.Storage) *Directory {
+	l, err := ledger.Create(store, ledger.Config{
+		Depth:       8,
+		K:           3,
+		VerkleDepth: 3,
+		Hash:        "shake128",
+		HashSize:    32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(l, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDirectory(t *testing.T) {
+	d := newTestDirectory(t, storage.NewMemoryStorage())
+	v := d.Verifier(nil)
+
+	if _, err := d.LookUp("alice", 0); err != ErrNoEpoch {
+		t.Errorf("got %v", err)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if err := d.Register(user, []byte("pk-"+user)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Register("bob", []byte("x")); err != ErrUserExists {
+		t.Errorf("got %v", err)
+	}
+	if err := d.Update("dave", []byte("x")); err != ErrUserNotFound {
+		t.Errorf("got %v", err)
+	}
+	if _, err := d.Publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	// epoch 1: bob换了公钥, carol被撤销
+	d.Update("bob", []byte("pk-bob-2"))
+	d.Revoke("carol")
+	if err := d.Update("carol", []byte("x")); err != ErrRevoked {
+		t.Errorf("got %v", err)
+	}
+	if !d.Pending() {
+		t.Error("changes should be pending")
+	}
+	if _, err := d.Publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	digest := d.Digest()
+	tables := []struct {
+		user   string
+		epoch  uint32
+		status Status
+		pubKey string
+	}{
+		{"alice", 0, StatusActive, "pk-alice"},
+		{"bob", 0, StatusActive, "pk-bob"},
+		{"bob", 1, StatusActive, "pk-bob-2"},
+		{"carol", 1, StatusRevoked, ""},
+		{"dave", 0, StatusAbsent, ""},
+		{"dave", 1, StatusAbsent, ""},
+	}
+	for _, table := range tables {
+		res, err := d.LookUp(table.user, table.epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != table.status || string(res.PubKey) != table.pubKey {
+			t.Errorf("%s@%d: got %s %q", table.user, table.epoch, res.Status, res.PubKey)
+		}
+		if err := v.VerifyLookUp(digest, table.user, res); err != nil {
+			t.Errorf("%s@%d: %v", table.user, table.epoch, err)
+		}
+		// 换一个用户名验证必须失败
+		if err := v.VerifyLookUp(digest, table.user+"x", res); err == nil && table.status != StatusAbsent {
+			t.Errorf("%s@%d: proof accepted for another user", table.user, table.epoch)
+		}
+	}
+
+	history, err := d.Monitor("bob", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyMonitor(digest, "bob", 0, 1, history); err != nil {
+		t.Error(err)
+	}
+	if err := v.VerifyMonitor(digest, "bob", 0, 1, history[1:]); err == nil {
+		t.Error("missing epoch should be rejected")
+	}
+}
+
+func TestDirectoryRejectsForgedResults(t *testing.T) {
+	d := newTestDirectory(t, storage.NewMemoryStorage())
+	v := d.Verifier(nil)
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		d.Register(user, []byte("pk-"+user))
+	}
+	d.Publish()
+	digest := d.Digest()
+
+	// 声称alice不存在: 用alice前后的两个叶子夹住alice的key, 但它们不相邻
+	alice, _ := d.LookUp("alice", 0)
+	pos := alice.Proofs[0].Position
+	snap := d.log.Tree().Snapshot()
+	left, _ := snap.GenerateLookupProof(0, pos-1)
+	right, _ := snap.GenerateLookupProof(0, pos+1)
+	forged := &LookupResult{Epoch: 0, Status: StatusAbsent, Proofs: []*core.LookupProof{left, right}}
+	if err := v.VerifyLookUp(digest, "alice", forged); err != ErrInvalidProof {
+		t.Errorf("got %v", err)
+	}
+	// Position不在证明的范围内, 改了也没用
+	right.Position = pos
+	if err := v.VerifyLookUp(digest, "alice", forged); err != ErrInvalidProof {
+		t.Errorf("got %v", err)
+	}
+
+	res, _ := d.LookUp("erin", 0)
+	// 修改返回的公钥
+	alice.PubKey = []byte("evil")
+	if err := v.VerifyLookUp(digest, "alice", alice); err != ErrInvalidProof {
+		t.Errorf("got %v", err)
+	}
+
+	// 把存在的用户说成不存在
+	res.Status = StatusActive
+	if err := v.VerifyLookUp(digest, "erin", res); err != ErrInvalidProof {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestDirectoryReopen(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	d := newTestDirectory(t, store)
+	d.Register("alice", []byte("pk"))
+	d.Revoke("alice")
+	d.Register("bob", []byte("pk"))
+	d.Publish()
+
+	l, err := ledger.Open(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = New(l, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Register("bob", []byte("x")); err != ErrUserExists {
+		t.Errorf("got %v", err)
+	}
+	// 撤销后可以重新注册
+	if err := d.Register("alice", []byte("pk-2")); err != nil {
+		t.Error(err)
+	}
+	d.Publish()
+	res, err := d.LookUp("alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.PubKey, []byte("pk-2")) {
+		t.Errorf("got %q", res.PubKey)
+	}
+}
+
+func TestDirectoryFull(t *testing.T) {
+	d := newTestDirectory(t, storage.NewMemoryStorage())
+	// 3^3个叶子, 两个是哨兵
+	var err error
+	for i := 0; i < 26 && err == nil; i++ {
+		err = d.Register(string(rune('a'+i)), nil)
+	}
+	if !errors.Is(err, ErrFull) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := d.Publish(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试用的Indexer, 和VRF一样key需要证明, 但不需要pairing
+type secretIndexer struct {
+	h      crypto.Hasher
+	secret []byte
+}
+
+func (i secretIndexer) Index(user string) ([]byte, []byte, error) {
+	key := i.h.Hash(i.secret, []byte(user))
+	return key, append([]byte("proof:"), key...), nil
+}
+
+func (i secretIndexer) VerifyIndex(user string, proof []byte) ([]byte, bool) {
+	key, want, _ := i.Index(user)
+	return key, bytes.Equal(proof, want)
+}
+
+func TestDirectoryIndexer(t *testing.T) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{
+		Depth: 4, K: 4, VerkleDepth: 2, Hash: "sha256", HashSize: 32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := secretIndexer{l.Tree().Hasher(), []byte("secret")}
+	d, err := New(l, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Register("alice", []byte("pk"))
+	d.Publish()
+	v := d.Verifier(idx)
+	digest := d.Digest()
+
+	for _, user := range []string{"alice", "bob"} {
+		res, err := d.LookUp(user, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.VerifyLookUp(digest, user, res); err != nil {
+			t.Errorf("%s: %v", user, err)
+		}
+		// 没有index证明, 或者使用默认的hash验证都必须失败
+		proof := res.IndexProof
+		res.IndexProof = nil
+		if err := v.VerifyLookUp(digest, user, res); err != ErrInvalidProof {
+			t.Errorf("%s: got %v", user, err)
+		}
+		res.IndexProof = proof
+		if err := d.Verifier(nil).VerifyLookUp(digest, user, res); err != ErrInvalidProof {
+			t.Errorf("%s: got %v", user, err)
+		}
+	}
+}
+
+func TestHidingDirectory(t *testing.T) {
+	store, private := storage.NewMemoryStorage(), storage.NewMemoryStorage()
+	l, err := ledger.Create(store, ledger.Config{Depth: 4, K: 3, VerkleDepth: 2, Hash: "shake128", HashSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewHiding(l, nil, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		d.Register(user, []byte("pk-"+user))
+	}
+	d.Publish()
+	d.Revoke("carol")
+	d.Publish()
+	v := d.Verifier(nil)
+	digest := d.Digest()
+
+	// log中的叶子不包含公钥
+	for epoch := uint32(0); epoch < 2; epoch++ {
+		values, _ := l.Values(epoch)
+		for _, value := range values {
+			if bytes.Contains(value, []byte("pk-")) {
+				t.Fatalf("epoch %d leaks a public key: %q", epoch, value)
+			}
+		}
+	}
+
+	alice, err := d.LookUp("alice", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Status != StatusActive || string(alice.PubKey) != "pk-alice" || len(alice.Opening) != 32 {
+		t.Fatalf("got %+v", alice)
+	}
+	if err := v.VerifyLookUp(digest, "alice", alice); err != nil {
+		t.Error(err)
+	}
+	carol, _ := d.LookUp("carol", 1)
+	if carol.Status != StatusRevoked || v.VerifyLookUp(digest, "carol", carol) != nil {
+		t.Errorf("got %+v", carol)
+	}
+
+	// 修改公钥, 状态或opening都无法通过验证
+	alice.PubKey = []byte("evil")
+	if v.VerifyLookUp(digest, "alice", alice) != ErrInvalidProof {
+		t.Error("forged public key accepted")
+	}
+	alice.PubKey = []byte("pk-alice")
+	alice.Status = StatusRevoked
+	if v.VerifyLookUp(digest, "alice", alice) != ErrInvalidProof {
+		t.Error("forged status accepted")
+	}
+	alice.Status = StatusActive
+	alice.Opening = carol.Opening
+	if v.VerifyLookUp(digest, "alice", alice) != ErrInvalidProof {
+		t.Error("wrong opening accepted")
+	}
+
+	// 不存在证明中相邻的叶子只有commitment
+	dave, err := d.LookUp("dave", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyLookUp(digest, "dave", dave); err != nil {
+		t.Error(err)
+	}
+	for _, proof := range dave.Proofs {
+		if bytes.Contains(proof.Value, []byte("pk-")) {
+			t.Error("absence proof leaks a neighbour's public key")
+		}
+	}
+
+	// 每个epoch的opening不同, 不变的公钥的commitment也不同
+	a0, _ := d.LookUp("alice", 0)
+	if bytes.Equal(a0.Proofs[0].Value, alice.Proofs[0].Value) {
+		t.Error("unchanged key has the same commitment in two epochs")
+	}
+
+	// 重新打开需要私有记录
+	l, _ = ledger.Open(store)
+	if _, err := New(l, nil); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("got %v", err)
+	}
+	d, err = NewHiding(l, nil, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Update("carol", nil); err != ErrRevoked {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+// Package follower 是log的只读副本。
+// follower从primary下载新的epoch并追加到自己的MerklePT, 每一批之后检查自己计算的digest
+// 与primary签名的digest相同, 然后只在验证过的状态上提供proof。
+// 这样既分担了primary的proof请求, 也独立地重新计算了primary的状态。
+package follower
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"MerkleVerkle/client"
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+)
+
+var (
+	ErrMismatch    = errors.New("follower: recomputed digest does not match the primary's signed digest")
+	ErrRollback    = errors.New("follower: primary is smaller than the verified state")
+	ErrKeyMismatch = errors.New("follower: primary's public key is not the configured key")
+	ErrTreeID      = errors.New("follower: signed digest is for another tree")
+)
+
+// DefaultBatchSize 是Config.BatchSize为0时每批的epoch数
+const DefaultBatchSize = 64
+
+// 最后验证过的primary签名, 与log保存在同一个storage中
+var keySigned = []byte("follower/signed")
+
+// Config 是follower的参数
+type Config struct {
+	PrimaryURL string            // 多个log的primary为 .../logs/{name}
+	PublicKey  ed25519.PublicKey // primary的公钥, 必须通过带外的方式获得
+	BatchSize  uint32            // 每批最多下载的epoch数, 每批之后验证一次签名
+	Interval   time.Duration     // Run的轮询间隔
+}
+
+// MismatchError 是primary签名的digest与重新计算的digest不同的证据, errors.Is(err, ErrMismatch)为true
+type MismatchError struct {
+	Signed *core.SignedDigest
+	Local  *core.Digest
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%v at size %d", ErrMismatch, e.Signed.Digest.Size)
+}
+
+func (e *MismatchError) Unwrap() error { return ErrMismatch }
+
+// Follower 是一个primary的副本, 可以并发使用
+type Follower struct {
+	cfg    Config
+	client *client.Client
+	log    *ledger.Log
+	store  storage.Storage
+
+	syncMu sync.Mutex // 同时只有一个Sync
+	mu     sync.RWMutex
+	signed *core.SignedDigest // 最后验证过的状态, 没有时为nil
+	failed error              // 发现不一致之后停止同步
+}
+
+// New 打开store中的副本, store为空时从primary读取log的参数并创建
+func New(store storage.Storage, cfg Config) (*Follower, error) {
+	if len(cfg.PublicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("follower: missing primary public key")
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	c, err := client.New(cfg.PrimaryURL, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ledger.Open(store)
+	if errors.Is(err, ledger.ErrNotInitialized) {
+		info, err := c.Info()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(info.PublicKey, cfg.PublicKey) {
+			return nil, ErrKeyMismatch
+		}
+		if l, err = ledger.Create(store, info.Config); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	f := &Follower{cfg: cfg, client: c, log: l, store: store}
+	if f.signed, err = f.loadSigned(); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// 读取并重新检查保存的签名, 本地的log必须包含签名的状态
+func (f *Follower) loadSigned() (*core.SignedDigest, error) {
+	data, err := f.store.Get(keySigned)
+	if err == storage.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var signed core.SignedDigest
+	if err := signed.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if err := f.check(&signed); err != nil {
+		return nil, fmt.Errorf("follower: stored signature: %w", err)
+	}
+	return &signed, nil
+}
+
+// 检查签名属于这个log, 并且与本地计算的digest相同
+func (f *Follower) check(signed *core.SignedDigest) error {
+	if !core.VerifySignedDigest(f.cfg.PublicKey, signed) {
+		return client.ErrInvalidProof
+	}
+	if !bytes.Equal(signed.TreeID, f.log.TreeID()) {
+		return ErrTreeID
+	}
+	local, err := f.log.Tree().Snapshot().GetOldDigest(signed.Digest.Size)
+	if err != nil {
+		return err
+	}
+	a, _ := local.MarshalBinary()
+	b, _ := signed.Digest.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		return &MismatchError{Signed: signed, Local: local}
+	}
+	return nil
+}
+
+// Log 返回本地的log, 它可能包含还没有验证的epoch, 对外只应该提供Snapshot
+func (f *Follower) Log() *ledger.Log {
+	return f.log
+}
+
+// Snapshot 返回验证过的状态
+func (f *Follower) Snapshot() *core.Snapshot {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var size uint32
+	if f.signed != nil {
+		size = f.signed.Digest.Size
+	}
+	snap, _ := f.log.Tree().Snapshot().At(size)
+	return snap
+}
+
+// Signed 返回primary对验证过的状态的签名, 还没有同步时为nil
+func (f *Follower) Signed() *core.SignedDigest {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.signed
+}
+
+// PublicKey 返回primary的公钥
+func (f *Follower) PublicKey() ed25519.PublicKey {
+	return f.cfg.PublicKey
+}
+
+// Sync 同步到primary当前的大小, 返回验证过的大小。
+// 发现不一致时返回*MismatchError, 之后的Sync都返回这个错误, 已经验证过的状态不变
+func (f *Follower) Sync() (uint32, error) {
+	f.syncMu.Lock()
+	defer f.syncMu.Unlock()
+
+	f.mu.RLock()
+	verified, failed := f.signed, f.failed
+	f.mu.RUnlock()
+	if failed != nil {
+		return 0, failed
+	}
+	var size uint32
+	if verified != nil {
+		size = verified.Digest.Size
+	}
+
+	latest, err := f.client.SignedDigest(f.cfg.PublicKey)
+	if err != nil {
+		return size, err
+	}
+	// 本地可能有上次没有验证完的epoch
+	local := f.log.Tree().CurrentSize()
+	if latest.Digest.Size < size || latest.Digest.Size < local {
+		return size, fmt.Errorf("%w: primary size %d, verified %d", ErrRollback, latest.Digest.Size, size)
+	}
+
+	for size < latest.Digest.Size {
+		target := latest.Digest.Size
+		if target-size > f.cfg.BatchSize {
+			target = size + f.cfg.BatchSize
+		}
+		if target < local {
+			target = local
+		}
+		if err := f.fetch(target); err != nil {
+			return size, err
+		}
+		signed := latest
+		if target != latest.Digest.Size {
+			if signed, err = f.client.SignedDigestAt(target, f.cfg.PublicKey); err != nil {
+				return size, err
+			}
+		}
+		if err := f.accept(signed); err != nil {
+			return size, err
+		}
+		size = target
+	}
+	return size, nil
+}
+
+// 下载并追加epoch, 直到本地的大小为target
+func (f *Follower) fetch(target uint32) error {
+	for epoch := f.log.Tree().CurrentSize(); epoch < target; epoch++ {
+		values, err := f.client.Values(epoch)
+		if err != nil {
+			return err
+		}
+		// 链式log的header不能从值中恢复, 之后与签名的digest比较
+		var header core.LeafHeader
+		if f.log.Config().Chained {
+			h, err := f.client.LeafHeader(epoch, target)
+			if err != nil {
+				return err
+			}
+			header = *h
+		}
+		if _, err := f.log.AppendWithHeader(values, header.Timestamp, header.Metadata); err != nil {
+			return fmt.Errorf("follower: epoch %d: %w", epoch, err)
+		}
+	}
+	return nil
+}
+
+// 检查签名并保存为新的验证过的状态
+func (f *Follower) accept(signed *core.SignedDigest) error {
+	err := f.check(signed)
+	if errors.Is(err, ErrMismatch) || errors.Is(err, ErrTreeID) {
+		f.mu.Lock()
+		f.failed = err
+		f.mu.Unlock()
+		return err
+	} else if err != nil {
+		return err
+	}
+	data, err := signed.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := f.store.Put(keySigned, data); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.signed = signed
+	f.mu.Unlock()
+	return nil
+}
+
+// Run 每隔Interval同步一次, 直到ctx结束或者发现不一致。网络错误只记录日志
+func (f *Follower) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+	var last uint32
+	for {
+		size, err := f.Sync()
+		if errors.Is(err, ErrMismatch) || errors.Is(err, ErrTreeID) {
+			return err
+		} else if err != nil {
+			log.Printf("follower: sync failed: %v", err)
+		} else if size != last {
+			log.Printf("follower: verified size %d", size)
+			last = size
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close 关闭本地的log
+func (f *Follower) Close() error {
+	return f.log.Close()
+}
@@ -0,0 +1,186 @@
+package follower
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"MerkleVerkle/client"
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+	"MerkleVerkle/server"
+)
+
+func newPrimary(t *testing.T, h http.Handler) (*ledger.Log, *httptest.Server) {
+	r, err := ledger.OpenRegistry(storage.NewMemoryStorage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := r.Create("primary", ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h == nil {
+		h = server.New(l)
+	}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return l, ts
+}
+
+func appendN(t *testing.T, l *ledger.Log, n int) {
+	for i := 0; i < n; i++ {
+		size := l.Tree().CurrentSize()
+		if _, err := l.Append([][]byte{[]byte(fmt.Sprint("a", size)), []byte(fmt.Sprint("b", size))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFollower(t *testing.T) {
+	primary, ts := newPrimary(t, nil)
+	appendN(t, primary, 5)
+
+	path := filepath.Join(t.TempDir(), "replica")
+	store, err := storage.OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{PrimaryURL: ts.URL, PublicKey: primary.PublicKey(), BatchSize: 2}
+	f, err := New(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := f.Sync(); err != nil || size != 5 {
+		t.Fatalf("got %d, %v", size, err)
+	}
+	if f.Log().Config().TreeID != primary.Config().TreeID {
+		t.Error("replica should use the primary's tree id")
+	}
+	f.Close()
+
+	// 重新打开后从验证过的状态继续
+	appendN(t, primary, 4)
+	store, _ = storage.OpenFileStorage(path)
+	if f, err = New(store, cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Snapshot().Size != 5 {
+		t.Errorf("reopened replica has size %d", f.Snapshot().Size)
+	}
+	if size, err := f.Sync(); err != nil || size != 9 {
+		t.Fatalf("got %d, %v", size, err)
+	}
+
+	// 只读server提供proof, 客户端用primary的签名和hasher验证
+	replica := httptest.NewServer(server.NewReadOnly(f.Log(), f))
+	defer replica.Close()
+	c, err := client.New(replica.URL, "", primary.Tree().Hasher())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.UseVerkle(2, 2)
+	signed, err := c.SignedDigest(primary.PublicKey())
+	if err != nil || signed.Digest.Size != 9 {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if proof, err := c.LookUp(7, 1); err != nil || string(proof.Value) != "b7" {
+		t.Errorf("lookup: %v", err)
+	}
+	resp, err := http.Post(replica.URL+server.PathEpochs, server.ContentTypeJSON, strings.NewReader(`{"values":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("read-only server accepted an epoch")
+	}
+}
+
+// 链式log的header从primary的inclusion proof中获取
+func TestFollowerChained(t *testing.T) {
+	primary, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32, Chained: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.New(primary))
+	defer ts.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := primary.AppendWithHeader([][]byte{[]byte(fmt.Sprint("a", i))}, uint64(1000+i), []byte(fmt.Sprint("m", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := New(storage.NewMemoryStorage(), Config{PrimaryURL: ts.URL, PublicKey: primary.PublicKey(), BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if size, err := f.Sync(); err != nil || size != 3 {
+		t.Fatalf("got %d, %v", size, err)
+	}
+	proof, err := f.Log().Tree().GenerateInclusionProof(2, 3)
+	if err != nil || proof.Leaf.Header == nil || string(proof.Leaf.Header.Metadata) != "m2" {
+		t.Errorf("got %+v, %v", proof, err)
+	}
+}
+
+// 返回的epoch值与签名的digest不一致时, follower停止同步并保留验证过的状态
+func TestFollowerMismatch(t *testing.T) {
+	var honest http.Handler
+	var cheat atomic.Bool
+	primary, ts := newPrimary(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cheat.Load() && r.URL.Path == server.PathEpochs+"/3" {
+			data, _ := json.Marshal(server.EpochResponse{Epoch: 3, Values: [][]byte{[]byte("forged")}})
+			w.Write(data)
+			return
+		}
+		honest.ServeHTTP(w, r)
+	}))
+	honest = server.New(primary)
+	appendN(t, primary, 2)
+
+	f, err := New(storage.NewMemoryStorage(), Config{PrimaryURL: ts.URL, PublicKey: primary.PublicKey(), BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	cheat.Store(true)
+	appendN(t, primary, 3)
+	size, err := f.Sync()
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) || size != 2 {
+		t.Fatalf("got %d, %v", size, err)
+	}
+	if mismatch.Signed.Digest.Size != 4 || !core.VerifySignedDigest(primary.PublicKey(), mismatch.Signed) {
+		t.Error("mismatch should carry the primary's signed digest")
+	}
+	if f.Snapshot().Size != 2 || f.Signed().Digest.Size != 2 {
+		t.Error("verified state should not change")
+	}
+	cheat.Store(false)
+	if _, err := f.Sync(); !errors.Is(err, ErrMismatch) {
+		t.Errorf("sync after a mismatch: got %v", err)
+	}
+}
+
+func TestFollowerWrongKey(t *testing.T) {
+	_, ts := newPrimary(t, nil)
+	other, _ := newPrimary(t, nil)
+	if _, err := New(storage.NewMemoryStorage(), Config{PrimaryURL: ts.URL, PublicKey: other.PublicKey()}); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -4,7 +4,6 @@ go 1.22.0
 
 require (
 	github.com/Nik-U/pbc v0.0.0-20181205041846-3e516ca0c5d6
-	github.com/ucbrise/MerkleSquare v0.0.0-20210427034819-f6e13efccdd0
 	golang.org/x/crypto v0.22.0
 )
 
@@ -0,0 +1,485 @@
+// Package ledger 把MerklePT和它的epoch持久化到storage中, 打开时重放所有epoch重建MerklePT
+package ledger
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/note"
+	"MerkleVerkle/lib/params"
+	"MerkleVerkle/lib/storage"
+)
+
+var (
+	ErrExists         = errors.New("ledger: log already initialized")
+	ErrNotInitialized = errors.New("ledger: log not initialized")
+	ErrCorrupted      = errors.New("ledger: corrupted storage")
+)
+
+var (
+	keyConfig     = []byte("config")
+	keySize       = []byte("size")
+	keySigningKey = []byte("signing_key")
+)
+
+func epochKey(epoch uint32) []byte {
+	return []byte("epoch/" + strconv.FormatUint(uint64(epoch), 10))
+}
+
+// 链式log中叶子header的timestamp和metadata, Prev在重放时重新计算
+func headerKey(epoch uint32) []byte {
+	return []byte("header/" + strconv.FormatUint(uint64(epoch), 10))
+}
+
+// Config 是log创建时确定的参数
+type Config struct {
+	Depth       uint32 `json:"depth"`        // MerklePT的深度, 最多2^Depth个epoch
+	K           uint32 `json:"k"`            // verkle tree的分叉因子
+	VerkleDepth uint32 `json:"verkle_depth"` // verkle tree的深度
+	Hash        string `json:"hash"`
+	HashSize    int    `json:"hash_size"`
+	ParamFile   string `json:"param_file,omitempty"` // 创建时读取的pairing参数文件, 只用于显示
+	Params      string `json:"params,omitempty"`     // pairing参数的文本, 打开时检查, 不依赖ParamFile的路径
+	TreeID      string `json:"tree_id,omitempty"`    // 十六进制, 混入所有hash, 为空时与没有tree ID的log兼容
+	Origin      string `json:"origin,omitempty"`     // checkpoint的origin, 例如example.com/log, 为空时由tree ID生成
+	Chained     bool   `json:"chained,omitempty"`    // 每个叶子commit前一个digest的bagged root, 见core.NewChainedMerklePT
+}
+
+func (c Config) newTree(h crypto.Hasher) *core.MerklePT {
+	if c.Chained {
+		return core.NewChainedMerklePT(c.Depth, h)
+	}
+	return core.NewMerklePT(c.Depth, h)
+}
+
+// Hasher 返回Config指定的hash函数, 有TreeID时混入TreeID
+func (c Config) Hasher() (crypto.Hasher, error) {
+	id, err := crypto.ParseHashID(c.Hash)
+	if err != nil {
+		return nil, err
+	}
+	h, err := crypto.NewHasher(id, c.HashSize)
+	if err != nil {
+		return nil, err
+	}
+	treeID, err := c.treeID()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.WithTreeID(h, treeID)
+}
+
+func (c Config) treeID() ([]byte, error) {
+	id, err := hex.DecodeString(c.TreeID)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: invalid tree id %q", c.TreeID)
+	}
+	return id, nil
+}
+
+// NewTreeID 返回随机的tree ID
+func NewTreeID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func (c Config) validate() error {
+	if c.Depth == 0 || c.Depth > 31 {
+		return fmt.Errorf("ledger: invalid depth %d", c.Depth)
+	}
+	if c.K < 2 || c.VerkleDepth == 0 {
+		return fmt.Errorf("ledger: invalid verkle tree parameters k=%d depth=%d", c.K, c.VerkleDepth)
+	}
+	// origin是note的key name
+	if _, err := note.VerifierKey(c.Origin, note.AlgEd25519, nil); c.Origin != "" && err != nil {
+		return fmt.Errorf("ledger: invalid origin %q", c.Origin)
+	}
+	if c.Params != "" {
+		if _, err := c.LoadParams(); err != nil {
+			return fmt.Errorf("ledger: %w", err)
+		}
+	}
+	_, err := c.Hasher()
+	return err
+}
+
+// LoadParams 解析Config中的pairing参数, 没有参数时返回nil。
+// 只保存了ParamFile的旧log从文件读取
+func (c Config) LoadParams() (*params.Params, error) {
+	name := strings.TrimSuffix(filepath.Base(c.ParamFile), ".param")
+	switch {
+	case c.Params != "":
+		return params.Parse(name, c.Params)
+	case c.ParamFile != "":
+		return params.LoadFile(c.ParamFile)
+	}
+	return nil, nil
+}
+
+// Log 是持久化的MerklePT, Append会先写入storage再添加到MerklePT
+type Log struct {
+	mu    sync.Mutex // 保证epoch按顺序写入
+	cfg   Config
+	tree  *core.MerklePT
+	store storage.Storage
+	key   ed25519.PrivateKey // 对digest签名
+}
+
+// Create 在store中初始化一个新的log
+func Create(store storage.Storage, cfg Config) (*Log, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := store.Get(keyConfig); err == nil {
+		return nil, ErrExists
+	} else if err != storage.ErrNotFound {
+		return nil, err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(keyConfig, data); err != nil {
+		return nil, err
+	}
+	return Open(store)
+}
+
+// Open 打开store中的log并重放所有epoch
+func Open(store storage.Storage) (*Log, error) {
+	data, err := store.Get(keyConfig)
+	if err == storage.ErrNotFound {
+		return nil, ErrNotInitialized
+	} else if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	h, _ := cfg.Hasher()
+	l := &Log{
+		cfg:   cfg,
+		tree:  cfg.newTree(h),
+		store: store,
+	}
+	if l.key, err = loadSigningKey(store); err != nil {
+		return nil, err
+	}
+
+	size, err := l.storedSize()
+	if err != nil {
+		return nil, err
+	}
+	for epoch := uint32(0); epoch < size; epoch++ {
+		values, err := l.Values(epoch)
+		if err != nil {
+			return nil, fmt.Errorf("ledger: epoch %d: %w", epoch, err)
+		}
+		if err := l.replay(epoch, values); err != nil {
+			return nil, fmt.Errorf("ledger: replaying epoch %d: %w", epoch, err)
+		}
+	}
+	return l, nil
+}
+
+func (l *Log) replay(epoch uint32, values [][]byte) error {
+	tree, err := core.NewKaryTree(l.cfg.K, l.cfg.VerkleDepth, l.tree.Hasher())
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if !tree.AddValue(value) {
+			return core.ErrTooManyLeaves
+		}
+	}
+	if !l.cfg.Chained {
+		_, err := l.tree.AppendTree(tree)
+		return err
+	}
+	timestamp, metadata, err := l.header(epoch)
+	if err != nil {
+		return err
+	}
+	_, err = l.tree.AppendTreeWithHeader(tree, timestamp, metadata)
+	return err
+}
+
+// 读取签名密钥, 之前创建的log没有密钥, 第一次打开时生成
+func loadSigningKey(store storage.Storage) (ed25519.PrivateKey, error) {
+	seed, err := store.Get(keySigningKey)
+	if err == storage.ErrNotFound {
+		seed = make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+		if err := store.Put(keySigningKey, seed); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, ErrCorrupted
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func (l *Log) storedSize() (uint32, error) {
+	data, err := l.store.Get(keySize)
+	if err == storage.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(data) != 4 {
+		return 0, ErrCorrupted
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+// Config 返回log的参数
+func (l *Log) Config() Config {
+	return l.cfg
+}
+
+// TreeID 返回log的tree ID, 没有时为nil
+func (l *Log) TreeID() []byte {
+	id, _ := l.cfg.treeID()
+	return id
+}
+
+// PublicKey 返回验证digest签名的公钥
+func (l *Log) PublicKey() ed25519.PublicKey {
+	return l.key.Public().(ed25519.PublicKey)
+}
+
+// Sign 对digest签名
+func (l *Log) Sign(dg *core.Digest) *core.SignedDigest {
+	return core.SignDigest(l.key, l.TreeID(), dg)
+}
+
+// SignTreeHead 对RFC 6962的tree head签名, timestamp为毫秒
+func (l *Log) SignTreeHead(head *core.RFCTreeHead, timestamp uint64) *core.SignedTreeHead {
+	return core.SignTreeHead(l.key, head, timestamp)
+}
+
+// SignMessage 用log的私钥对digest以外的消息签名, 例如pool的promise。
+// 消息必须以自己的context开头, 不能与digest的签名混淆
+func (l *Log) SignMessage(msg []byte) []byte {
+	return ed25519.Sign(l.key, msg)
+}
+
+// Origin 返回checkpoint的origin, 也是note签名的key name
+func (l *Log) Origin() string {
+	if l.cfg.Origin != "" {
+		return l.cfg.Origin
+	}
+	if l.cfg.TreeID != "" {
+		return "MerkleVerkle/" + l.cfg.TreeID
+	}
+	return "MerkleVerkle"
+}
+
+// VerifierKey 返回验证checkpoint签名的note verifier key
+func (l *Log) VerifierKey() string {
+	vkey, _ := note.VerifierKey(l.Origin(), note.AlgEd25519, l.PublicKey())
+	return vkey
+}
+
+// Checkpoint 返回digest的checkpoint, 用log的私钥签名, extra是额外的签名, 例如BLS
+func (l *Log) Checkpoint(dg *core.Digest, extra ...note.Signer) ([]byte, error) {
+	text, err := core.NewCheckpoint(l.Origin(), l.tree.Hasher(), dg).Text()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := note.NewEd25519Signer(l.Origin(), l.key)
+	if err != nil {
+		return nil, err
+	}
+	return note.Sign(text, append([]note.Signer{signer}, extra...)...)
+}
+
+// Tree 返回内存中的MerklePT, 只用来读取, 添加epoch要通过Append
+func (l *Log) Tree() *core.MerklePT {
+	return l.tree
+}
+
+// Append 持久化一个epoch并添加到MerklePT, 返回新的epoch。链式log在叶子中commit当前时间
+func (l *Log) Append(values [][]byte) (uint32, error) {
+	return l.AppendMetadata(values, nil)
+}
+
+// AppendMetadata 与Append相同, 同时在链式log的叶子中commit metadata
+func (l *Log) AppendMetadata(values [][]byte, metadata []byte) (uint32, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.append(values, l.timestamp(), metadata)
+}
+
+// AppendWith 与Append相同, 但是先持有log的锁用新的epoch调用before, 再写入epoch。
+// 调用者用它保存与epoch关联的数据, before返回错误时不添加epoch
+func (l *Log) AppendWith(values [][]byte, before func(epoch uint32) error) (uint32, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := before(l.tree.CurrentSize()); err != nil {
+		return 0, err
+	}
+	return l.append(values, l.timestamp(), nil)
+}
+
+// AppendWithHeader 与Append相同, 但是使用给定的timestamp(毫秒, 0表示没有), 例如follower复制primary的epoch。
+// 链式log的timestamp必须晚于前一个epoch
+func (l *Log) AppendWithHeader(values [][]byte, timestamp uint64, metadata []byte) (uint32, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.append(values, timestamp, metadata)
+}
+
+// 下一个epoch的timestamp: 当前时间, 时钟回退时为前一个epoch之后1毫秒。不是链式log时为0
+func (l *Log) timestamp() uint64 {
+	if !l.cfg.Chained {
+		return 0
+	}
+	ts := uint64(time.Now().UnixMilli())
+	if last := l.tree.LastTimestamp(); ts <= last {
+		ts = last + 1
+	}
+	return ts
+}
+
+func (l *Log) append(values [][]byte, timestamp uint64, metadata []byte) (uint32, error) {
+	if !l.cfg.Chained && len(metadata) != 0 {
+		return 0, core.ErrNotChained
+	}
+	if err := l.tree.CheckTimestamp(timestamp); err != nil {
+		return 0, err
+	}
+	if len(metadata) > core.MaxMetadataSize {
+		return 0, core.ErrFieldTooLarge
+	}
+
+	// 先构造verkle tree检查参数, 失败时storage不变
+	tree, err := core.NewKaryTree(l.cfg.K, l.cfg.VerkleDepth, l.tree.Hasher())
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range values {
+		if !tree.AddValue(value) {
+			return 0, core.ErrTooManyLeaves
+		}
+	}
+	epoch := l.tree.CurrentSize()
+	if epoch == 1<<l.cfg.Depth {
+		return 0, core.ErrTreeFull
+	}
+
+	if err := l.store.Put(epochKey(epoch), EncodeValues(values)); err != nil {
+		return 0, err
+	}
+	if l.cfg.Chained && (timestamp != 0 || len(metadata) != 0) {
+		header := binary.BigEndian.AppendUint64(nil, timestamp)
+		if err := l.store.Put(headerKey(epoch), append(header, metadata...)); err != nil {
+			return 0, err
+		}
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, epoch+1)
+	if err := l.store.Put(keySize, size); err != nil {
+		return 0, err
+	}
+	if l.cfg.Chained {
+		return l.tree.AppendTreeWithHeader(tree, timestamp, metadata)
+	}
+	return l.tree.AppendTree(tree)
+}
+
+// 读取链式log中第epoch个叶子的timestamp和metadata, 没有保存时都为空
+func (l *Log) header(epoch uint32) (uint64, []byte, error) {
+	data, err := l.store.Get(headerKey(epoch))
+	if err == storage.ErrNotFound {
+		return 0, nil, nil
+	} else if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 8 {
+		return 0, nil, ErrCorrupted
+	}
+	var metadata []byte
+	if len(data) > 8 {
+		metadata = data[8:]
+	}
+	return binary.BigEndian.Uint64(data), metadata, nil
+}
+
+// Values 返回第epoch个epoch的所有值
+func (l *Log) Values(epoch uint32) ([][]byte, error) {
+	data, err := l.store.Get(epochKey(epoch))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeValues(data)
+}
+
+// Close 关闭storage
+func (l *Log) Close() error {
+	return l.store.Close()
+}
+
+// EncodeValues 编码值的列表: 个数(4) 之后每个值为 长度(4) | 值。
+// log用它保存epoch, pool和directory用它保存自己的记录
+func EncodeValues(values [][]byte) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(values)))
+	for _, v := range values {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(v)))
+		buf = append(buf, v...)
+	}
+	return buf
+}
+
+// DecodeValues 解码EncodeValues的结果, 返回的值引用data, 格式错误时返回ErrCorrupted
+func DecodeValues(data []byte) ([][]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrCorrupted
+	}
+	n := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	var values [][]byte
+	for i := uint32(0); i < n; i++ {
+		if len(data) < 4 {
+			return nil, ErrCorrupted
+		}
+		l := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint32(len(data)) < l {
+			return nil, ErrCorrupted
+		}
+		values = append(values, data[:l])
+		data = data[l:]
+	}
+	if len(data) != 0 {
+		return nil, ErrCorrupted
+	}
+	return values, nil
+}
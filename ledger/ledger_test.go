@@ -0,0 +1,292 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/note"
+	"MerkleVerkle/lib/params"
+	"MerkleVerkle/lib/storage"
+)
+
+var testConfig = Config{
+	Depth:       4,
+	K:           2,
+	VerkleDepth: 2,
+	Hash:        "sha256",
+	HashSize:    32,
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	store, err := storage.OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Create(store, testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if _, err := l.Append([][]byte{[]byte(v), []byte(v + v)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Append(make([][]byte, 5)); err != core.ErrTooManyLeaves {
+		t.Errorf("got %v", err)
+	}
+	digest := oldDigest(t, l, 3)
+	l.Close()
+
+	store, err = storage.OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(store, testConfig); err != ErrExists {
+		t.Errorf("got %v", err)
+	}
+	l, err = Open(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	reopened := oldDigest(t, l, l.Tree().CurrentSize())
+	if reopened.Size != 3 || !bytes.Equal(reopened.Roots[0], digest.Roots[0]) || !bytes.Equal(reopened.Roots[1], digest.Roots[1]) {
+		t.Error("replayed log should have the same digest")
+	}
+	values, err := l.Values(2)
+	if err != nil || string(values[1]) != "cc" {
+		t.Errorf("got %q, %v", values, err)
+	}
+}
+
+func TestOpenEmpty(t *testing.T) {
+	if _, err := Open(storage.NewMemoryStorage()); err != ErrNotInitialized {
+		t.Errorf("got %v", err)
+	}
+	bad := testConfig
+	bad.Hash = "md5"
+	if _, err := Create(storage.NewMemoryStorage(), bad); err == nil {
+		t.Error("unknown hash should be rejected")
+	}
+}
+
+// 参数的文本保存在config中, 打开时不需要参数文件
+func TestParams(t *testing.T) {
+	text, err := os.ReadFile(filepath.Join("..", params.DefaultDir, "a.param"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig
+	cfg.ParamFile = "param/a.param"
+	cfg.Params = string(text)
+	store := storage.NewMemoryStorage()
+	if _, err := Create(store, cfg); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := l.Config().LoadParams()
+	if err != nil || p.Name != "a" || p.Type != "a" {
+		t.Errorf("got %v, %v", p, err)
+	}
+
+	// 被改坏的参数在打开时被发现
+	cfg.Params = strings.Replace(cfg.Params, "type a", "type x", 1)
+	data, _ := json.Marshal(cfg)
+	store.Put(keyConfig, data)
+	if _, err := Open(store); !errors.Is(err, params.ErrUnknownType) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	store, err := storage.OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRegistry(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := r.Create("tenant-a", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig
+	cfg.Depth, cfg.K = 3, 3
+	b, err := r.Create("tenant-b", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create("tenant-a", testConfig); !errors.Is(err, ErrExists) {
+		t.Errorf("duplicate name: got %v", err)
+	}
+	for _, name := range []string{"", "A", "-a", "a/b", "../a"} {
+		if _, err := r.Create(name, testConfig); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%q: got %v", name, err)
+		}
+	}
+	rfc := testConfig
+	rfc.Hash = "rfc6962"
+	if _, err := r.Create("tenant-rfc", rfc); err == nil {
+		t.Error("rfc6962 log without tree id should be rejected")
+	}
+
+	// 同样的值在两个log中得到不同的digest
+	values := [][]byte{[]byte("x")}
+	a.Append(values)
+	b.Append(values)
+	da, db := a.Tree().Snapshot().Digest(), b.Tree().Snapshot().Digest()
+	if bytes.Equal(da.Roots[0], db.Roots[0]) {
+		t.Error("logs should have different tree ids")
+	}
+	if bytes.Equal(a.PublicKey(), b.PublicKey()) {
+		t.Error("logs should have different signing keys")
+	}
+	if !core.VerifySignedDigest(a.PublicKey(), a.Sign(da)) || core.VerifySignedDigest(b.PublicKey(), a.Sign(da)) {
+		t.Error("signed digest should only verify with the log's key")
+	}
+	// a的proof不能在b上验证
+	proof, _ := a.Tree().Snapshot().GenerateLookupProof(0, 0)
+	if core.VerifyLookupProof(b.Tree().Hasher(), testConfig.K, testConfig.VerkleDepth, da, proof) {
+		t.Error("proof verified with another log's hasher")
+	}
+	r.Close()
+
+	store, err = storage.OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = OpenRegistry(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if names := r.Names(); len(names) != 2 || names[0] != "tenant-a" || names[1] != "tenant-b" {
+		t.Fatalf("got %v", names)
+	}
+	a2, _ := r.Log("tenant-a")
+	b2, _ := r.Log("tenant-b")
+	if !bytes.Equal(a2.Tree().Snapshot().Digest().Roots[0], da.Roots[0]) || b2.Config().K != 3 {
+		t.Error("reopened logs differ")
+	}
+	if !bytes.Equal(a2.PublicKey(), a.PublicKey()) {
+		t.Error("signing key should be persisted")
+	}
+	if _, err := r.Log("tenant-c"); !errors.Is(err, ErrUnknownLog) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	cfg := testConfig
+	cfg.Origin = "example.com/log"
+	l, err := Create(storage.NewMemoryStorage(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := l.Append([][]byte{[]byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+	dg := oldDigest(t, l, 1)
+	msg, err := l.Checkpoint(dg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := note.NewVerifier(l.VerifierKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _, err := core.OpenCheckpoint(msg, v)
+	if err != nil || c.Origin != "example.com/log" || !c.Matches(l.Tree().Hasher(), dg) {
+		t.Errorf("got %+v, %v", c, err)
+	}
+
+	cfg.Origin = "bad origin"
+	if _, err := Create(storage.NewMemoryStorage(), cfg); err == nil {
+		t.Error("origin with spaces should be rejected")
+	}
+}
+
+func TestAppendWith(t *testing.T) {
+	l, err := Create(storage.NewMemoryStorage(), testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append([][]byte{[]byte("a")})
+	var got []uint32
+	before := func(epoch uint32) error {
+		got = append(got, epoch)
+		return nil
+	}
+	if epoch, err := l.AppendWith([][]byte{[]byte("b")}, before); err != nil || epoch != 1 || got[0] != 1 {
+		t.Errorf("got %d, %v, %v", epoch, got, err)
+	}
+	failed := errors.New("failed")
+	if _, err := l.AppendWith([][]byte{[]byte("c")}, func(uint32) error { return failed }); err != failed {
+		t.Errorf("got %v", err)
+	}
+	if l.Tree().CurrentSize() != 2 {
+		t.Errorf("size %d", l.Tree().CurrentSize())
+	}
+}
+
+func TestChained(t *testing.T) {
+	cfg := testConfig
+	cfg.Chained = true
+	store := storage.NewMemoryStorage()
+	l, err := Create(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.AppendWithHeader([][]byte{[]byte("a")}, 1000, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Append([][]byte{[]byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	digest := oldDigest(t, l, 2)
+
+	// 重放后header相同
+	l, err = Open(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(oldDigest(t, l, 2).BaggedRoot(l.Tree().Hasher()), digest.BaggedRoot(l.Tree().Hasher())) {
+		t.Error("replayed log should have the same digest")
+	}
+	proof, err := l.Tree().GenerateInclusionProof(0, 2)
+	if err != nil || proof.Leaf.Header == nil || proof.Leaf.Header.Timestamp != 1000 || string(proof.Leaf.Header.Metadata) != "first" {
+		t.Fatalf("got %+v, %v", proof, err)
+	}
+	proof, _ = l.Tree().GenerateInclusionProof(1, 2)
+	if !core.VerifyChainLink(l.Tree().Hasher(), oldDigest(t, l, 1), digest, proof) {
+		t.Error("chain link failed")
+	}
+
+	plain, _ := Create(storage.NewMemoryStorage(), testConfig)
+	if _, err := plain.AppendWithHeader([][]byte{[]byte("a")}, 0, []byte("meta")); !errors.Is(err, core.ErrNotChained) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func oldDigest(t *testing.T, l *Log, size uint32) *core.Digest {
+	t.Helper()
+	dg, err := l.Tree().GetOldDigest(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dg
+}
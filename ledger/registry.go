@@ -0,0 +1,148 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"MerkleVerkle/lib/storage"
+)
+
+var (
+	ErrUnknownLog  = errors.New("ledger: unknown log")
+	ErrInvalidName = errors.New("ledger: invalid log name")
+)
+
+// 命名log的列表, 每个log保存在 "logs/<name>/" 命名空间中
+var keyLogs = []byte("logs")
+
+const maxNameLen = 64
+
+func logPrefix(name string) string {
+	return "logs/" + name + "/"
+}
+
+// 名字只能包含小写字母, 数字, '-', '_'和'.', 并且以字母或数字开头, 可以直接用在URL中
+func validName(name string) bool {
+	if name == "" || len(name) > maxNameLen {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case i > 0 && (c == '-' || c == '_' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Registry 是同一个storage中的多个命名log, 每个log有自己的参数, 签名密钥和tree ID
+type Registry struct {
+	mu    sync.Mutex
+	store storage.Storage
+	logs  map[string]*Log
+}
+
+// OpenRegistry 打开store中所有的命名log。store中也可以同时有一个用Create创建的默认log
+func OpenRegistry(store storage.Storage) (*Registry, error) {
+	r := &Registry{store: store, logs: make(map[string]*Log)}
+	names, err := r.storedNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		l, err := Open(storage.Namespace(store, logPrefix(name)))
+		if err != nil {
+			return nil, fmt.Errorf("ledger: log %s: %w", name, err)
+		}
+		r.logs[name] = l
+	}
+	return r, nil
+}
+
+func (r *Registry) storedNames() ([]string, error) {
+	data, err := r.store.Get(keyLogs)
+	if err == storage.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, ErrCorrupted
+	}
+	return names, nil
+}
+
+// Create 创建一个命名log, cfg.TreeID为空时生成随机的tree ID。
+// 不同log的proof靠tree ID区分, 所以不能混入tree ID的hash(例如rfc6962)不能用于命名log
+func (r *Registry) Create(name string, cfg Config) (*Log, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.logs[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrExists, name)
+	}
+	if cfg.TreeID == "" {
+		id, err := NewTreeID()
+		if err != nil {
+			return nil, err
+		}
+		cfg.TreeID = id
+	}
+	// 先创建log再加入列表, 列表中的名字都能打开
+	l, err := Create(storage.Namespace(r.store, logPrefix(name)), cfg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(append(r.names(), name))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.store.Put(keyLogs, data); err != nil {
+		return nil, err
+	}
+	r.logs[name] = l
+	return l, nil
+}
+
+// Log 返回名字为name的log
+func (r *Registry) Log(name string) (*Log, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.logs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownLog, name)
+	}
+	return l, nil
+}
+
+// Names 返回所有log的名字, 按字母顺序
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.names()
+}
+
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.logs))
+	for name := range r.logs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close 关闭底层的storage, 之后所有的log都不能再使用
+func (r *Registry) Close() error {
+	return r.store.Close()
+}
@@ -0,0 +1,168 @@
+// Package bls 是基于pbc pairing的BLS签名。
+// 签名在G1上, 公钥在G2上: sig = H(m)^x, pk = g^x, 验证e(sig, g) == e(H(m), pk)。
+// BLS签名是唯一的, 所以也可以用来构造VRF。
+package bls
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/Nik-U/pbc"
+
+	"MerkleVerkle/lib/params"
+)
+
+var ErrInvalidKey = errors.New("bls: invalid key encoding")
+
+// 用于hash-to-group和生成元的domain
+const (
+	domainMessage   = "MerkleVerkle/bls/message"
+	domainGenerator = "MerkleVerkle/bls/generator"
+)
+
+// Scheme 是一条曲线上的BLS签名, 生成元g由参数决定, 所以同一个参数文件的所有Scheme是兼容的
+type Scheme struct {
+	params  *params.Params
+	pairing *pbc.Pairing
+	order   *big.Int
+	g       *pbc.Element
+}
+
+// NewScheme 在参数p的曲线上创建BLS签名
+func NewScheme(p *params.Params) (*Scheme, error) {
+	pairing, err := p.Pairing()
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheme{params: p, pairing: pairing, order: p.Order()}
+	h := sha256.Sum256([]byte(domainGenerator))
+	s.g = pairing.NewG2().SetFromHash(h[:])
+	return s, nil
+}
+
+// Params 返回曲线参数
+func (s *Scheme) Params() *params.Params {
+	return s.params
+}
+
+// Pairing 返回曲线的pairing
+func (s *Scheme) Pairing() *pbc.Pairing {
+	return s.pairing
+}
+
+// HashToG1 把domain和消息映射到G1
+func (s *Scheme) HashToG1(domain string, msg []byte) *pbc.Element {
+	h := sha256.New()
+	h.Write([]byte(domain))
+	h.Write([]byte{0})
+	h.Write(msg)
+	return s.pairing.NewG1().SetFromHash(h.Sum(nil))
+}
+
+// 解码一个群元素, 编码必须是规范的, 并且元素在阶为order的子群中且不是单位元
+func (s *Scheme) decodePoint(el *pbc.Element, data []byte) bool {
+	if len(data) != el.BytesLen() {
+		return false
+	}
+	el.SetBytes(data)
+	if string(el.Bytes()) != string(data) || el.Is0() {
+		return false
+	}
+	check := el.NewFieldElement().PowBig(el, s.order)
+	return check.Is0()
+}
+
+// PublicKey 是BLS公钥
+type PublicKey struct {
+	scheme *Scheme
+	v      *pbc.Element
+}
+
+// PrivateKey 是BLS私钥
+type PrivateKey struct {
+	PublicKey
+	x *pbc.Element
+}
+
+// GenerateKey 生成随机的私钥
+func (s *Scheme) GenerateKey() (*PrivateKey, error) {
+	x, err := rand.Int(rand.Reader, s.order)
+	if err != nil {
+		return nil, err
+	}
+	if x.Sign() == 0 {
+		x.SetInt64(1)
+	}
+	return s.newPrivateKey(x), nil
+}
+
+func (s *Scheme) newPrivateKey(x *big.Int) *PrivateKey {
+	k := &PrivateKey{x: s.pairing.NewZr().SetBig(x)}
+	k.scheme = s
+	k.v = s.pairing.NewG2().PowZn(s.g, k.x)
+	return k
+}
+
+// PrivateKeyFromBytes 解码PrivateKey.Bytes的结果
+func (s *Scheme) PrivateKeyFromBytes(data []byte) (*PrivateKey, error) {
+	x := new(big.Int).SetBytes(data)
+	if len(data) != int(s.pairing.ZrLength()) || x.Sign() == 0 || x.Cmp(s.order) >= 0 {
+		return nil, ErrInvalidKey
+	}
+	return s.newPrivateKey(x), nil
+}
+
+// PublicKeyFromBytes 解码PublicKey.Bytes的结果
+func (s *Scheme) PublicKeyFromBytes(data []byte) (*PublicKey, error) {
+	v := s.pairing.NewG2()
+	if !s.decodePoint(v, data) {
+		return nil, ErrInvalidKey
+	}
+	return &PublicKey{scheme: s, v: v}, nil
+}
+
+// Bytes 编码私钥
+func (k *PrivateKey) Bytes() []byte {
+	return k.x.Bytes()
+}
+
+// Public 返回私钥对应的公钥
+func (k *PrivateKey) Public() *PublicKey {
+	return &k.PublicKey
+}
+
+// Bytes 编码公钥
+func (k *PublicKey) Bytes() []byte {
+	return k.v.Bytes()
+}
+
+// Sign 对消息签名
+func (k *PrivateKey) Sign(msg []byte) []byte {
+	return k.SignDomain(domainMessage, msg)
+}
+
+// SignDomain 在指定的domain下签名, 不同用途的签名使用不同的domain
+func (k *PrivateKey) SignDomain(domain string, msg []byte) []byte {
+	h := k.scheme.HashToG1(domain, msg)
+	return k.scheme.pairing.NewG1().PowZn(h, k.x).Bytes()
+}
+
+// Verify 验证消息的签名
+func (k *PublicKey) Verify(msg []byte, sig []byte) bool {
+	return k.VerifyDomain(domainMessage, msg, sig)
+}
+
+// VerifyDomain 验证在domain下的签名
+func (k *PublicKey) VerifyDomain(domain string, msg []byte, sig []byte) bool {
+	s := k.scheme
+	sigma := s.pairing.NewG1()
+	if !s.decodePoint(sigma, sig) {
+		return false
+	}
+	h := s.HashToG1(domain, msg)
+	left := s.pairing.NewGT().Pair(sigma, s.g)
+	right := s.pairing.NewGT().Pair(h, k.v)
+	return left.Equals(right)
+}
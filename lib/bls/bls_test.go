@@ -0,0 +1,109 @@
+package bls
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"MerkleVerkle/lib/note"
+	"MerkleVerkle/lib/params"
+)
+
+func newTestScheme(t *testing.T) *Scheme {
+	p, err := params.Load(filepath.Join("..", "..", params.DefaultDir), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewScheme(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSignVerify(t *testing.T) {
+	s := newTestScheme(t)
+	k, err := s.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := k.Sign([]byte("hello"))
+	if !k.Public().Verify([]byte("hello"), sig) {
+		t.Error("valid signature rejected")
+	}
+	if k.Public().Verify([]byte("hellO"), sig) {
+		t.Error("signature accepted for another message")
+	}
+	if k.Public().VerifyDomain("other", []byte("hello"), sig) {
+		t.Error("signature accepted in another domain")
+	}
+	// 签名是唯一的
+	if !bytes.Equal(sig, k.Sign([]byte("hello"))) {
+		t.Error("signature is not deterministic")
+	}
+
+	other, _ := s.GenerateKey()
+	if other.Public().Verify([]byte("hello"), sig) {
+		t.Error("signature accepted for another key")
+	}
+
+	for _, bad := range [][]byte{nil, sig[1:], make([]byte, len(sig))} {
+		if k.Public().Verify([]byte("hello"), bad) {
+			t.Errorf("malformed signature %x accepted", bad)
+		}
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	s := newTestScheme(t)
+	k, _ := s.GenerateKey()
+
+	k2, err := s.PrivateKeyFromBytes(k.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k2.Public().Bytes(), k.Public().Bytes()) {
+		t.Error("private key round trip changed the public key")
+	}
+	pub, err := s.PublicKeyFromBytes(k.Public().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Verify([]byte("m"), k.Sign([]byte("m"))) {
+		t.Error("decoded public key rejects a valid signature")
+	}
+
+	if _, err := s.PrivateKeyFromBytes(make([]byte, len(k.Bytes()))); err != ErrInvalidKey {
+		t.Errorf("got %v", err)
+	}
+	if _, err := s.PublicKeyFromBytes(k.Public().Bytes()[1:]); err != ErrInvalidKey {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestNoteSigner(t *testing.T) {
+	s := newTestScheme(t)
+	k, err := s.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NoteSigner("example.com/log", k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := note.Sign("hello\n", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vkey, err := NoteVerifierKey("example.com/log", k.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.NoteVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := note.Open(msg, v); err != nil {
+		t.Error(err)
+	}
+}
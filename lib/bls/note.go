@@ -0,0 +1,65 @@
+package bls
+
+import (
+	"fmt"
+
+	"MerkleVerkle/lib/note"
+)
+
+// note签名使用单独的domain
+const domainNote = "MerkleVerkle/bls/note"
+
+type noteSigner struct {
+	name string
+	hash uint32
+	key  *PrivateKey
+}
+
+// NoteSigner 返回签名算法为note.AlgBLS的note.Signer, 可以作为checkpoint的额外签名
+func NoteSigner(name string, key *PrivateKey) (note.Signer, error) {
+	// 检查名字
+	if _, err := NoteVerifierKey(name, key.Public()); err != nil {
+		return nil, err
+	}
+	hash := note.KeyHash(name, note.AlgBLS, key.Public().Bytes())
+	return &noteSigner{name: name, hash: hash, key: key}, nil
+}
+
+func (s *noteSigner) Name() string    { return s.name }
+func (s *noteSigner) KeyHash() uint32 { return s.hash }
+func (s *noteSigner) Sign(msg []byte) ([]byte, error) {
+	return s.key.SignDomain(domainNote, msg), nil
+}
+
+// NoteVerifierKey 返回BLS公钥的verifier key
+func NoteVerifierKey(name string, key *PublicKey) (string, error) {
+	return note.VerifierKey(name, note.AlgBLS, key.Bytes())
+}
+
+type noteVerifier struct {
+	name string
+	hash uint32
+	key  *PublicKey
+}
+
+// NoteVerifier 由NoteVerifierKey的结果创建note.Verifier, 公钥必须在s的曲线上
+func (s *Scheme) NoteVerifier(vkey string) (note.Verifier, error) {
+	name, alg, data, err := note.ParseVerifierKey(vkey)
+	if err != nil {
+		return nil, err
+	}
+	if alg != note.AlgBLS {
+		return nil, fmt.Errorf("%w: not a BLS key", note.ErrInvalidKey)
+	}
+	key, err := s.PublicKeyFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return &noteVerifier{name: name, hash: note.KeyHash(name, alg, data), key: key}, nil
+}
+
+func (v *noteVerifier) Name() string    { return v.name }
+func (v *noteVerifier) KeyHash() uint32 { return v.hash }
+func (v *noteVerifier) Verify(msg, sig []byte) bool {
+	return v.key.VerifyDomain(domainNote, msg, sig)
+}
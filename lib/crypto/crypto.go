@@ -1,18 +1,169 @@
 package crypto
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+
 	"golang.org/x/crypto/sha3"
 )
 
 const hashSize = 32
 
+// HashID 标识所使用的hash函数，会被记录在digest中
+type HashID uint8
+
+const (
+	SHAKE128 HashID = iota + 1
+	SHA3_256
+	SHA256
+	RFC6962 // SHA-256, 叶子和内部节点带有RFC 6962的0x00/0x01前缀
+)
+
+func (id HashID) String() string {
+	switch id {
+	case SHAKE128:
+		return "shake128"
+	case SHA3_256:
+		return "sha3-256"
+	case SHA256:
+		return "sha256"
+	case RFC6962:
+		return "rfc6962"
+	}
+	return fmt.Sprintf("hash(%d)", uint8(id))
+}
+
+// ParseHashID 是String的逆操作
+func ParseHashID(name string) (HashID, error) {
+	for _, id := range []HashID{SHAKE128, SHA3_256, SHA256, RFC6962} {
+		if id.String() == name {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("crypto: unknown hash function %q", name)
+}
+
+// Hasher 对若干段输入计算定长的hash
+type Hasher interface {
+	Hash(ms ...[]byte) []byte
+	Size() int
+	ID() HashID
+}
+
+// TreeHasher 是区分叶子和内部节点的Hasher, MerklePT的叶子和内部节点用它计算, 其他的hash仍然用Hash
+type TreeHasher interface {
+	Hasher
+	HashLeaf(ms ...[]byte) []byte
+	HashChildren(left, right []byte) []byte
+}
+
+// Default 是不指定hasher时使用的hash函数, 与原来的Hash保持一致
+var Default Hasher = shakeHasher{size: hashSize}
+
+// NewHasher 按照id和输出长度构造Hasher。
+// SHAKE128 可以输出任意长度，SHA3-256 和 SHA-256 的输出最多32字节，小于32时截断。
+func NewHasher(id HashID, size int) (Hasher, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("crypto: invalid hash size %d", size)
+	}
+	switch id {
+	case SHAKE128:
+		return shakeHasher{size: size}, nil
+	case SHA3_256:
+		if size > 32 {
+			return nil, fmt.Errorf("crypto: %s output is at most 32 bytes, got %d", id, size)
+		}
+		return fixedHasher{id: id, size: size, new: sha3.New256}, nil
+	case SHA256:
+		if size > sha256.Size {
+			return nil, fmt.Errorf("crypto: %s output is at most 32 bytes, got %d", id, size)
+		}
+		return fixedHasher{id: id, size: size, new: sha256.New}, nil
+	case RFC6962:
+		if size != sha256.Size {
+			return nil, fmt.Errorf("crypto: %s output is 32 bytes, got %d", id, size)
+		}
+		return rfcHasher{fixedHasher{id: id, size: size, new: sha256.New}}, nil
+	}
+	return nil, fmt.Errorf("crypto: unknown hash function %s", id)
+}
+
+// Hash 使用默认的hasher(SHAKE128, 32字节)
 func Hash(ms ...[]byte) []byte {
+	return Default.Hash(ms...)
+}
+
+type shakeHasher struct {
+	size int
+}
+
+func (s shakeHasher) Hash(ms ...[]byte) []byte {
 	h := sha3.NewShake128()
 	for _, m := range ms {
 		h.Write(m)
 	}
-	ret := make([]byte, hashSize)
+	ret := make([]byte, s.size)
 	h.Read(ret)
 
 	return ret
 }
+
+func (s shakeHasher) Size() int  { return s.size }
+func (s shakeHasher) ID() HashID { return SHAKE128 }
+
+// 定长输出的hash函数，输出按size截断
+type fixedHasher struct {
+	id   HashID
+	size int
+	new  func() hash.Hash
+}
+
+func (f fixedHasher) Hash(ms ...[]byte) []byte {
+	h := f.new()
+	for _, m := range ms {
+		h.Write(m)
+	}
+	return h.Sum(nil)[:f.size]
+}
+
+func (f fixedHasher) Size() int  { return f.size }
+func (f fixedHasher) ID() HashID { return f.id }
+
+// RFC 6962的hash: 叶子为SHA-256(0x00 || data), 内部节点为SHA-256(0x01 || left || right)
+type rfcHasher struct {
+	fixedHasher
+}
+
+func (r rfcHasher) HashLeaf(ms ...[]byte) []byte {
+	return r.Hash(append([][]byte{{0x00}}, ms...)...)
+}
+
+func (r rfcHasher) HashChildren(left, right []byte) []byte {
+	return r.Hash([]byte{0x01}, left, right)
+}
+
+// 带有tree ID的hasher, 每次hash前加上 len(id) | id, 不同的树计算出的hash互不相同
+type treeHasher struct {
+	Hasher
+	prefix []byte
+}
+
+// WithTreeID 返回把id混入每次hash的Hasher, 一个log的proof不能在另一个log上验证通过。
+// id为空时返回h, id最长255字节。TreeHasher的格式是固定的(例如RFC 6962), 不能混入tree ID
+func WithTreeID(h Hasher, id []byte) (Hasher, error) {
+	if len(id) == 0 {
+		return h, nil
+	}
+	if _, ok := h.(TreeHasher); ok {
+		return nil, fmt.Errorf("crypto: %s hashes cannot include a tree id", h.ID())
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("crypto: tree id is %d bytes, at most 255", len(id))
+	}
+	return treeHasher{Hasher: h, prefix: append([]byte{byte(len(id))}, id...)}, nil
+}
+
+func (t treeHasher) Hash(ms ...[]byte) []byte {
+	return t.Hasher.Hash(append([][]byte{t.prefix}, ms...)...)
+}
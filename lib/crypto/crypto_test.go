@@ -1,6 +1,10 @@
 package crypto
 
-import "testing"
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
 
 func TestHash(t *testing.T) {
 	m := Hash([]byte("1"), []byte("0"))
@@ -9,3 +13,70 @@ func TestHash(t *testing.T) {
 	}
 
 }
+
+func TestNewHasher(t *testing.T) {
+	tables := []struct {
+		id   HashID
+		size int
+		want string
+	}{
+		{SHA256, 32, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{SHA3_256, 32, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+		{SHA256, 16, "ba7816bf8f01cfea414140de5dae2223"},
+		{RFC6962, 32, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, table := range tables {
+		h, err := NewHasher(table.id, table.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 分段输入和整段输入的结果相同
+		got := h.Hash([]byte("a"), []byte("bc"))
+		if hex.EncodeToString(got) != table.want || h.ID() != table.id || h.Size() != table.size {
+			t.Errorf("%s/%d: got %x", table.id, table.size, got)
+		}
+		if id, err := ParseHashID(table.id.String()); err != nil || id != table.id {
+			t.Errorf("ParseHashID(%s) = %v, %v", table.id, id, err)
+		}
+	}
+
+	shake, err := NewHasher(SHAKE128, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(shake.Hash([]byte("1"))[:hashSize], Hash([]byte("1"))) {
+		t.Error("SHAKE128 outputs should be prefixes of each other")
+	}
+
+	if _, err := NewHasher(SHA256, 33); err == nil {
+		t.Error("expected error for oversized SHA-256 output")
+	}
+	if _, err := NewHasher(HashID(0), 32); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+}
+
+func TestWithTreeID(t *testing.T) {
+	a, err := WithTreeID(Default, []byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := WithTreeID(Default, []byte("b"))
+	m := []byte("m")
+	if bytes.Equal(a.Hash(m), b.Hash(m)) || bytes.Equal(a.Hash(m), Default.Hash(m)) {
+		t.Error("tree ids should separate the hashes")
+	}
+	if !bytes.Equal(a.Hash(m), Default.Hash([]byte{1, 'a'}, m)) {
+		t.Error("tree id should be a length prefixed prefix")
+	}
+	if a.ID() != Default.ID() || a.Size() != Default.Size() {
+		t.Error("tree id should not change the hash function")
+	}
+	if h, _ := WithTreeID(Default, nil); h != Default {
+		t.Error("empty tree id should return the hasher")
+	}
+	if _, err := WithTreeID(Default, make([]byte, 256)); err == nil {
+		t.Error("tree id longer than 255 bytes should be rejected")
+	}
+}
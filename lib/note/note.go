@@ -0,0 +1,281 @@
+// Package note 实现C2SP signed-note格式: 一段以换行结尾的文本, 一个空行, 然后是若干签名行
+//
+//	— <key name> <base64(key hash | signature)>
+//
+// key hash是SHA-256(key name | "\n" | 算法 | 公钥)的前4字节, 大端序。
+// 验证时忽略不认识的签名, 所以同一个note可以被witness追加cosignature。
+package note
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrMalformed    = errors.New("note: malformed note")
+	ErrInvalidKey   = errors.New("note: invalid key")
+	ErrNoSignature  = errors.New("note: no verifiable signature")
+	ErrBadSignature = errors.New("note: invalid signature")
+	ErrDuplicateKey = errors.New("note: duplicate verifier key")
+)
+
+// 签名的算法, 写在key hash的输入和verifier key中
+const (
+	AlgEd25519 byte = 0x01
+	// AlgBLS 是lib/bls的BLS签名, 不是C2SP登记的算法, 只有本项目的verifier认识
+	AlgBLS byte = 0xfe
+)
+
+const (
+	sigPrefix = "— "
+	maxSigs   = 100
+)
+
+// Signer 对note的文本签名
+type Signer interface {
+	Name() string
+	KeyHash() uint32
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier 验证一个key的签名
+type Verifier interface {
+	Name() string
+	KeyHash() uint32
+	Verify(msg, sig []byte) bool
+}
+
+// Signature 是note上的一个签名
+type Signature struct {
+	Name string
+	Hash uint32
+	Sig  []byte // 不包括key hash
+}
+
+// Note 是打开的note
+type Note struct {
+	Text      string      // 被签名的文本, 以换行结尾
+	Sigs      []Signature // 验证通过的签名
+	UnverSigs []Signature // 没有对应verifier的签名
+}
+
+// KeyHash 计算key hash, key是算法之后的公钥
+func KeyHash(name string, alg byte, key []byte) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte("\n"))
+	h.Write([]byte{alg})
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// 名字不能为空, 不能包含空白和 '+'
+func validName(name string) bool {
+	if name == "" || !utf8.ValidString(name) {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || r == '+' {
+			return false
+		}
+	}
+	return true
+}
+
+// 文本必须是以换行结尾的UTF-8, 不能包含空行之外的控制字符
+func validText(text string) bool {
+	if !strings.HasSuffix(text, "\n") || !utf8.ValidString(text) {
+		return false
+	}
+	for _, r := range text {
+		if r != '\n' && unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sign 用signers对text签名, 返回note
+func Sign(text string, signers ...Signer) ([]byte, error) {
+	if !validText(text) || strings.Contains(text, "\n\n") {
+		return nil, fmt.Errorf("%w: invalid text", ErrMalformed)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(text)
+	buf.WriteString("\n")
+	for _, s := range signers {
+		if !validName(s.Name()) {
+			return nil, fmt.Errorf("%w: name %q", ErrInvalidKey, s.Name())
+		}
+		sig, err := s.Sign([]byte(text))
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(sigLine(s.Name(), s.KeyHash(), sig))
+	}
+	return buf.Bytes(), nil
+}
+
+func sigLine(name string, hash uint32, sig []byte) string {
+	data := binary.BigEndian.AppendUint32(nil, hash)
+	return sigPrefix + name + " " + base64.StdEncoding.EncodeToString(append(data, sig...)) + "\n"
+}
+
+// Open 解析note并用verifiers验证签名。没有对应verifier的签名放在UnverSigs中,
+// 有verifier但是验证失败的签名返回ErrBadSignature, 没有任何验证通过的签名时返回ErrNoSignature
+func Open(msg []byte, verifiers ...Verifier) (*Note, error) {
+	known := make(map[string]Verifier)
+	for _, v := range verifiers {
+		id := fmt.Sprintf("%s+%08x", v.Name(), v.KeyHash())
+		if _, ok := known[id]; ok {
+			return nil, fmt.Errorf("%w %s", ErrDuplicateKey, id)
+		}
+		known[id] = v
+	}
+
+	s := string(msg)
+	split := strings.LastIndex(s, "\n\n")
+	if split < 0 || !strings.HasSuffix(s, "\n") {
+		return nil, ErrMalformed
+	}
+	text, sigs := s[:split+1], s[split+2:]
+	if !validText(text) {
+		return nil, ErrMalformed
+	}
+	n := &Note{Text: text}
+	seen := make(map[string]bool)
+	lines := strings.SplitAfter(sigs, "\n")
+	lines = lines[:len(lines)-1] // 最后一个是空串
+	if len(lines) == 0 || len(lines) > maxSigs {
+		return nil, ErrMalformed
+	}
+	for _, line := range lines {
+		sig, err := parseSigLine(line)
+		if err != nil {
+			return nil, err
+		}
+		id := fmt.Sprintf("%s+%08x", sig.Name, sig.Hash)
+		v, ok := known[id]
+		if !ok {
+			n.UnverSigs = append(n.UnverSigs, sig)
+			continue
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("%w: repeated signature from %s", ErrMalformed, sig.Name)
+		}
+		seen[id] = true
+		if !v.Verify([]byte(text), sig.Sig) {
+			return nil, fmt.Errorf("%w from %s", ErrBadSignature, sig.Name)
+		}
+		n.Sigs = append(n.Sigs, sig)
+	}
+	if len(n.Sigs) == 0 {
+		return n, ErrNoSignature
+	}
+	return n, nil
+}
+
+func parseSigLine(line string) (Signature, error) {
+	if !strings.HasPrefix(line, sigPrefix) {
+		return Signature{}, fmt.Errorf("%w: bad signature line", ErrMalformed)
+	}
+	f := strings.Split(strings.TrimSuffix(strings.TrimPrefix(line, sigPrefix), "\n"), " ")
+	if len(f) != 2 || !validName(f[0]) {
+		return Signature{}, fmt.Errorf("%w: bad signature line", ErrMalformed)
+	}
+	data, err := base64.StdEncoding.DecodeString(f[1])
+	if err != nil || len(data) < 5 {
+		return Signature{}, fmt.Errorf("%w: bad signature line", ErrMalformed)
+	}
+	return Signature{Name: f[0], Hash: binary.BigEndian.Uint32(data), Sig: data[4:]}, nil
+}
+
+// VerifierKey 编码verifier key: <name>+<key hash>+<base64(算法 | 公钥)>, 与Go sumdb的格式相同
+func VerifierKey(name string, alg byte, key []byte) (string, error) {
+	if !validName(name) {
+		return "", fmt.Errorf("%w: name %q", ErrInvalidKey, name)
+	}
+	return fmt.Sprintf("%s+%08x+%s", name, KeyHash(name, alg, key), base64.StdEncoding.EncodeToString(append([]byte{alg}, key...))), nil
+}
+
+// ParseVerifierKey 解码VerifierKey的结果, 返回名字, 算法和公钥, 检查key hash
+func ParseVerifierKey(vkey string) (string, byte, []byte, error) {
+	invalid := fmt.Errorf("%w %q", ErrInvalidKey, vkey)
+	name, rest, ok := strings.Cut(vkey, "+")
+	if !ok || !validName(name) {
+		return "", 0, nil, invalid
+	}
+	hashHex, keyB64, ok := strings.Cut(rest, "+")
+	if !ok || len(hashHex) != 8 {
+		return "", 0, nil, invalid
+	}
+	key, err := base64.StdEncoding.DecodeString(keyB64)
+	if err != nil || len(key) < 2 {
+		return "", 0, nil, invalid
+	}
+	if fmt.Sprintf("%08x", KeyHash(name, key[0], key[1:])) != hashHex {
+		return "", 0, nil, invalid
+	}
+	return name, key[0], key[1:], nil
+}
+
+type ed25519Signer struct {
+	name string
+	hash uint32
+	key  ed25519.PrivateKey
+}
+
+// NewEd25519Signer 返回Ed25519的Signer
+func NewEd25519Signer(name string, key ed25519.PrivateKey) (Signer, error) {
+	if !validName(name) || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: ed25519 signer %q", ErrInvalidKey, name)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	return &ed25519Signer{name: name, hash: KeyHash(name, AlgEd25519, pub), key: key}, nil
+}
+
+func (s *ed25519Signer) Name() string    { return s.name }
+func (s *ed25519Signer) KeyHash() uint32 { return s.hash }
+func (s *ed25519Signer) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, msg), nil
+}
+
+type ed25519Verifier struct {
+	name string
+	hash uint32
+	key  ed25519.PublicKey
+}
+
+// NewEd25519Verifier 返回Ed25519的Verifier
+func NewEd25519Verifier(name string, key ed25519.PublicKey) (Verifier, error) {
+	if !validName(name) || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: ed25519 verifier %q", ErrInvalidKey, name)
+	}
+	return &ed25519Verifier{name: name, hash: KeyHash(name, AlgEd25519, key), key: key}, nil
+}
+
+// NewVerifier 由Ed25519的verifier key创建Verifier, 其他算法的key由对应的包解析
+func NewVerifier(vkey string) (Verifier, error) {
+	name, alg, key, err := ParseVerifierKey(vkey)
+	if err != nil {
+		return nil, err
+	}
+	if alg != AlgEd25519 {
+		return nil, fmt.Errorf("%w: unsupported algorithm %#x", ErrInvalidKey, alg)
+	}
+	return NewEd25519Verifier(name, key)
+}
+
+func (v *ed25519Verifier) Name() string    { return v.name }
+func (v *ed25519Verifier) KeyHash() uint32 { return v.hash }
+func (v *ed25519Verifier) Verify(msg, sig []byte) bool {
+	return ed25519.Verify(v.key, msg, sig)
+}
@@ -0,0 +1,80 @@
+package note
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// golang.org/x/mod/sumdb/note文档中的例子
+func TestGoNote(t *testing.T) {
+	v, err := NewVerifier("PeterNeumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := "If you think cryptography is the answer to your problem,\n" +
+		"then you don't know what your problem is.\n" +
+		"\n" +
+		"— PeterNeumann x08go/ZJkuBS9UG/SffcvIAQxVBtiFupLLr8pAcElZInNIuGUgYN1FFYC2pZSNXgKvqfqdngotpRZb6KE6RyyBwJnAM=\n"
+	n, err := Open([]byte(msg), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(n.Text, "If you think") || len(n.Sigs) != 1 {
+		t.Errorf("got %+v", n)
+	}
+}
+
+func TestSignOpen(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	signer, err := NewEd25519Signer("example.com/log", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, _ := ed25519.GenerateKey(nil)
+	witness, _ := NewEd25519Signer("witness", other)
+
+	msg, err := Sign("example.com/log\n5\nroot\n", signer, witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vkey, err := VerifierKey("example.com/log", AlgEd25519, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不认识的签名被忽略
+	n, err := Open(msg, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Text != "example.com/log\n5\nroot\n" || len(n.Sigs) != 1 || len(n.UnverSigs) != 1 || n.UnverSigs[0].Name != "witness" {
+		t.Errorf("got %+v", n)
+	}
+
+	// 修改文本
+	bad := strings.Replace(string(msg), "\n5\n", "\n6\n", 1)
+	if _, err := Open([]byte(bad), v); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("got %v", err)
+	}
+	// 只有不认识的签名
+	wv, _ := NewEd25519Verifier("other", other.Public().(ed25519.PublicKey))
+	if _, err := Open(msg, wv); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("got %v", err)
+	}
+	for _, m := range []string{"no signatures\n", "text\n\n", "text\n\nsig line\n", "text\n\n— name !!!\n"} {
+		if _, err := Open([]byte(m), v); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%q: got %v", m, err)
+		}
+	}
+	if _, err := Sign("two\n\nparagraphs\n", signer); err == nil {
+		t.Error("text with a blank line should be rejected")
+	}
+	if _, err := NewVerifier(strings.Replace(vkey, "+", "+0", 1)); err == nil {
+		t.Error("bad key hash should be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package params
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Nik-U/pbc"
+)
+
+// GenerateOptions 是生成新参数的选项, 不同类型使用不同的字段
+type GenerateOptions struct {
+	Type     string // a, d, f 或 g
+	RBits    uint32 // a, d, g: 群的阶的比特数
+	QBits    uint32 // a, d, g: 基域的比特数
+	D        uint32 // d, g: CM方法的判别式
+	BitLimit uint32 // d, g: 搜索时阶的最大比特数, 0表示不限制
+	Bits     uint32 // f: 群的阶和基域的比特数
+}
+
+func (o GenerateOptions) validate() error {
+	switch o.Type {
+	case "a":
+		if o.RBits < 2 || o.QBits <= o.RBits {
+			return errors.New("params: type a needs rbits >= 2 and qbits > rbits")
+		}
+	case "d", "g":
+		// D必须是正的并且D ≡ 0或3 (mod 4)
+		if o.D == 0 || (o.D%4 != 0 && o.D%4 != 3) {
+			return fmt.Errorf("params: %d is not a valid discriminant", o.D)
+		}
+		if o.RBits < 2 || o.QBits < o.RBits {
+			return fmt.Errorf("params: type %s needs rbits >= 2 and qbits >= rbits", o.Type)
+		}
+	case "f":
+		if o.Bits < 32 {
+			return errors.New("params: type f needs bits >= 32")
+		}
+	default:
+		return fmt.Errorf("%w %q, can generate a, d, f and g", ErrUnknownType, o.Type)
+	}
+	return nil
+}
+
+// Generate 生成新的参数, name是参数的名字。d和g可能找不到满足条件的曲线, 这时返回错误
+func Generate(name string, o GenerateOptions) (*Params, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	var p *pbc.Params
+	var err error
+	switch o.Type {
+	case "a":
+		p = pbc.GenerateA(o.RBits, o.QBits)
+	case "d":
+		p, err = pbc.GenerateD(o.D, o.RBits, o.QBits, o.BitLimit)
+	case "f":
+		p = pbc.GenerateF(o.Bits)
+	case "g":
+		p, err = pbc.GenerateG(o.D, o.RBits, o.QBits, o.BitLimit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 重新解析一次, 输出的文本和param/目录中的格式一样
+	return Parse(name, p.String())
+}
+
+// DefaultName 返回生成的参数的文件名, d和g和param/目录中一样是判别式-基域比特数-阶的比特数, 例如d277699-175-167
+func (o GenerateOptions) DefaultName() string {
+	u := func(v uint32) string { return strconv.FormatUint(uint64(v), 10) }
+	switch o.Type {
+	case "a":
+		return "a" + u(o.RBits) + "-" + u(o.QBits)
+	case "d", "g":
+		return o.Type + u(o.D) + "-" + u(o.QBits) + "-" + u(o.RBits)
+	case "f":
+		return "f" + u(o.Bits)
+	}
+	return o.Type
+}
+
+// Cost 是一条曲线上各种操作的平均耗时
+type Cost struct {
+	Pairing    time.Duration // e(g1, g2)
+	G1Exp      time.Duration // G1上的指数运算
+	G2Exp      time.Duration
+	GTExp      time.Duration
+	HashToG1   time.Duration // 把SHA-256的输出映射到G1
+	G1Bytes    int           // G1元素压缩后的长度, 也就是签名的长度
+	G2Bytes    int
+	GTBytes    int
+	Symmetric  bool
+	Iterations int
+}
+
+// Benchmark 测量每种操作n次的平均耗时
+func (p *Params) Benchmark(n int) (*Cost, error) {
+	if n < 1 {
+		return nil, errors.New("params: benchmark needs at least one iteration")
+	}
+	pairing, err := p.Pairing()
+	if err != nil {
+		return nil, err
+	}
+	g1 := pairing.NewG1().Rand()
+	g2 := pairing.NewG2().Rand()
+	gt := pairing.NewGT().Pair(g1, g2)
+	x := pairing.NewZr().Rand()
+	cost := &Cost{
+		G1Bytes:    pairing.NewG1().CompressedBytesLen(),
+		G2Bytes:    pairing.NewG2().CompressedBytesLen(),
+		GTBytes:    gt.BytesLen(),
+		Symmetric:  pairing.IsSymmetric(),
+		Iterations: n,
+	}
+
+	measure := func(f func()) time.Duration {
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			f()
+		}
+		return time.Since(start) / time.Duration(n)
+	}
+	out1, out2, outT := pairing.NewG1(), pairing.NewG2(), pairing.NewGT()
+	cost.Pairing = measure(func() { outT.Pair(g1, g2) })
+	cost.G1Exp = measure(func() { out1.PowZn(g1, x) })
+	cost.G2Exp = measure(func() { out2.PowZn(g2, x) })
+	cost.GTExp = measure(func() { outT.PowZn(gt, x) })
+	var i int
+	cost.HashToG1 = measure(func() {
+		h := sha256.Sum256([]byte(strconv.Itoa(i)))
+		out1.SetFromHash(h[:])
+		i++
+	})
+	return cost, nil
+}
@@ -0,0 +1,255 @@
+// Package params 读取param/目录中的pairing参数文件。
+// 参数文件是PBC的文本格式, 第一行是"type X", 之后每行一个"key value"。
+// 同一个文件只会创建一次*pbc.Pairing, 所以多次运行和多个模块使用的是同一条曲线。
+package params
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Nik-U/pbc"
+)
+
+// DefaultDir 是仓库中参数文件所在的目录
+const DefaultDir = "param"
+
+// 参数文件的后缀
+const fileExt = ".param"
+
+var (
+	ErrUnknownType = errors.New("params: unknown pairing type")
+	ErrInvalid     = errors.New("params: invalid parameter file")
+)
+
+// 每种类型必须有的字段
+var requiredFields = map[string][]string{
+	"a":  {"q", "h", "r", "exp2", "exp1", "sign1", "sign0"},
+	"a1": {"p", "n", "l"},
+	"d":  {"q", "n", "h", "r", "a", "b", "k", "nk", "hk", "coeff0", "coeff1", "coeff2", "nqr"},
+	"e":  {"q", "r", "h", "a", "b", "exp2", "exp1", "sign1", "sign0"},
+	"f":  {"q", "r", "b", "beta", "alpha0", "alpha1"},
+	"g":  {"q", "n", "h", "r", "a", "b", "k", "nk", "hk", "coeff0", "coeff1", "coeff2", "coeff3", "coeff4", "nqr"},
+	"i":  {"m", "t", "n", "n2"},
+}
+
+// 每种类型的embedding degree, d和g在文件中给出
+var embeddingDegree = map[string]int{"a": 2, "a1": 2, "e": 1, "f": 12, "i": 6}
+
+// Params 是一个解析过的参数文件
+type Params struct {
+	Name   string // 文件名去掉后缀, 例如"a", "d159"
+	Type   string // pairing的类型: a, a1, d, e, f, g, i
+	Text   string // 原始文本, 用来创建pbc.Pairing
+	fields map[string]*big.Int
+
+	once    sync.Once
+	pairing *pbc.Pairing
+	err     error
+}
+
+// Parse 解析并检查参数文本
+func Parse(name string, text string) (*Params, error) {
+	p := &Params{Name: name, Text: text, fields: make(map[string]*big.Int)}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		kv := strings.Fields(line)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w: %s: malformed line %q", ErrInvalid, name, line)
+		}
+		if kv[0] == "type" {
+			if p.Type != "" {
+				return nil, fmt.Errorf("%w: %s: duplicate type", ErrInvalid, name)
+			}
+			p.Type = kv[1]
+			continue
+		}
+		if _, ok := p.fields[kv[0]]; ok {
+			return nil, fmt.Errorf("%w: %s: duplicate field %q", ErrInvalid, name, kv[0])
+		}
+		v, ok := new(big.Int).SetString(kv[1], 10)
+		if !ok || v.Sign() < 0 {
+			return nil, fmt.Errorf("%w: %s: field %q is not a number", ErrInvalid, name, kv[0])
+		}
+		p.fields[kv[0]] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Params) validate() error {
+	required, ok := requiredFields[p.Type]
+	if !ok {
+		return fmt.Errorf("%w %q in %s", ErrUnknownType, p.Type, p.Name)
+	}
+	for _, field := range required {
+		if _, ok := p.fields[field]; !ok {
+			return fmt.Errorf("%w: %s: missing field %q", ErrInvalid, p.Name, field)
+		}
+	}
+	for field := range p.fields {
+		if !contains(required, field) {
+			return fmt.Errorf("%w: %s: unexpected field %q for type %s", ErrInvalid, p.Name, field, p.Type)
+		}
+	}
+	// 群的阶必须是素数, a1是合数阶的群, i的阶是n=n2*r
+	switch p.Type {
+	case "a", "d", "e", "f", "g":
+		if !p.fields["r"].ProbablyPrime(20) {
+			return fmt.Errorf("%w: %s: group order r is not prime", ErrInvalid, p.Name)
+		}
+	}
+	if p.EmbeddingDegree() == 0 {
+		return fmt.Errorf("%w: %s: invalid embedding degree", ErrInvalid, p.Name)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// EmbeddingDegree 返回曲线的embedding degree k, GT是F_q^k的子群
+func (p *Params) EmbeddingDegree() int {
+	if k, ok := embeddingDegree[p.Type]; ok {
+		return k
+	}
+	k := p.fields["k"]
+	if !k.IsInt64() || k.Int64() < 1 || k.Int64() > 64 {
+		return 0
+	}
+	return int(k.Int64())
+}
+
+// OrderBits 返回群的阶的比特数
+func (p *Params) OrderBits() int {
+	return p.Order().BitLen()
+}
+
+// Order 返回群的阶, a1和i是合数阶n
+func (p *Params) Order() *big.Int {
+	switch p.Type {
+	case "a1", "i":
+		return new(big.Int).Set(p.fields["n"])
+	}
+	return new(big.Int).Set(p.fields["r"])
+}
+
+// FieldBits 返回基域的比特数。i类型的基域是F_{3^m}
+func (p *Params) FieldBits() int {
+	switch p.Type {
+	case "a1":
+		return p.fields["p"].BitLen()
+	case "i":
+		return int(math.Ceil(float64(p.fields["m"].Int64()) * math.Log2(3)))
+	}
+	return p.fields["q"].BitLen()
+}
+
+// SecurityBits 估计安全强度, 取群上离散对数(Pollard rho)和GT所在的有限域上离散对数(NFS)中较小的一个。
+// a1的阶是合数, 还要考虑分解n的难度。这只是粗略的估计, 用于比较不同的参数。
+func (p *Params) SecurityBits() int {
+	bits := p.OrderBits() / 2
+	if s := nfsSecurity(p.EmbeddingDegree() * p.FieldBits()); s < bits {
+		bits = s
+	}
+	if p.Type == "a1" {
+		if s := nfsSecurity(p.OrderBits()); s < bits {
+			bits = s
+		}
+	}
+	return bits
+}
+
+// 数域筛法的复杂度 L_n[1/3, (64/9)^(1/3)] 换算成比特, 与NIST SP 800-57的表大致相符(1024比特约80)
+func nfsSecurity(bits int) int {
+	x := float64(bits) * math.Ln2
+	lx := math.Log(x)
+	return int(1.923*math.Cbrt(x)*math.Cbrt(lx*lx)/math.Ln2 - 4.69)
+}
+
+// Pairing 返回这组参数的pairing, 第一次调用时创建, 之后返回同一个对象
+func (p *Params) Pairing() (*pbc.Pairing, error) {
+	p.once.Do(func() {
+		p.pairing, p.err = pbc.NewPairingFromString(p.Text)
+	})
+	return p.pairing, p.err
+}
+
+// String 返回参数的摘要
+func (p *Params) String() string {
+	return fmt.Sprintf("%s: type %s, %d-bit order, %d-bit field, k=%d, ~%d-bit security",
+		p.Name, p.Type, p.OrderBits(), p.FieldBits(), p.EmbeddingDegree(), p.SecurityBits())
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*Params)
+)
+
+// LoadFile 读取参数文件, 同一个路径只读取一次
+func LoadFile(path string) (*Params, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if p, ok := cache[abs]; ok {
+		return p, nil
+	}
+	text, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(strings.TrimSuffix(filepath.Base(abs), fileExt), string(text))
+	if err != nil {
+		return nil, err
+	}
+	cache[abs] = p
+	return p, nil
+}
+
+// Load 按名字读取dir中的参数文件, 例如Load("param", "d159")读取param/d159.param
+func Load(dir string, name string) (*Params, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("params: invalid name %q", name)
+	}
+	return LoadFile(filepath.Join(dir, name+fileExt))
+}
+
+// Names 返回dir中所有参数文件的名字
+func Names(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = strings.TrimSuffix(filepath.Base(f), fileExt)
+	}
+	sort.Strings(names)
+	return names, nil
+}
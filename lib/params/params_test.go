@@ -0,0 +1,121 @@
+package params
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var paramDir = filepath.Join("..", "..", DefaultDir)
+
+func TestLoadRepoParams(t *testing.T) {
+	names, err := Names(paramDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 12 {
+		t.Errorf("got %d param files", len(names))
+	}
+	for _, name := range names {
+		p, err := Load(paramDir, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !strings.HasPrefix(name, p.Type) {
+			t.Errorf("%s has type %s", name, p.Type)
+		}
+		if s := p.SecurityBits(); s < 60 || s > 128 {
+			t.Errorf("%s: security %d", p, s)
+		}
+	}
+
+	tables := []struct {
+		name     string
+		typ      string
+		order    int
+		k        int
+		security int
+	}{
+		{"a", "a", 160, 2, 80},
+		{"d159", "d", 158, 6, 79},
+		{"f", "f", 158, 12, 79},
+		{"g149", "g", 149, 10, 74},
+	}
+	for _, table := range tables {
+		p, err := Load(paramDir, table.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Type != table.typ || p.OrderBits() != table.order || p.EmbeddingDegree() != table.k || p.SecurityBits() != table.security {
+			t.Errorf("got %s", p)
+		}
+	}
+}
+
+func TestLoadCached(t *testing.T) {
+	p1, err := Load(paramDir, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := LoadFile(filepath.Join(paramDir, "a.param"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1 != p2 {
+		t.Error("the same file should be loaded once")
+	}
+	if _, err := Load(paramDir, "../a"); err == nil {
+		t.Error("names with a path should be rejected")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tables := []struct {
+		text string
+		err  error
+	}{
+		{"type z\nq 1\n", ErrUnknownType},
+		{"q 1\n", ErrUnknownType},
+		{"type a1\np 11\nn 7\n", ErrInvalid},
+		{"type a1\np 11\nn 7\nl 2\nq 5\n", ErrInvalid},
+		{"type a1\np 11\nn 7\nl x\n", ErrInvalid},
+		{"type a1\np 11\nn 7\nn 7\nl 2\n", ErrInvalid},
+		{"type a1\np 11 12\nn 7\nl 2\n", ErrInvalid},
+		{"type f\nq 7\nr 8\nb 1\nbeta 1\nalpha0 1\nalpha1 1\n", ErrInvalid},
+		{"type d\nq 7\nn 7\nh 1\nr 7\na 1\nb 1\nk 0\nnk 1\nhk 1\ncoeff0 1\ncoeff1 1\ncoeff2 1\nnqr 1\n", ErrInvalid},
+	}
+	for _, table := range tables {
+		if _, err := Parse("test", table.text); !errors.Is(err, table.err) {
+			t.Errorf("%q: got %v", table.text, err)
+		}
+	}
+	if _, err := Parse("test", "type a1\np 11\nn 7\nl 2\n"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	tables := []struct {
+		o    GenerateOptions
+		name string
+		ok   bool
+	}{
+		{GenerateOptions{Type: "a", RBits: 160, QBits: 512}, "a160-512", true},
+		{GenerateOptions{Type: "a", RBits: 160, QBits: 160}, "", false},
+		{GenerateOptions{Type: "d", D: 277699, RBits: 167, QBits: 175}, "d277699-175-167", true},
+		{GenerateOptions{Type: "g", D: 5, RBits: 149, QBits: 149}, "", false},
+		{GenerateOptions{Type: "f", Bits: 160}, "f160", true},
+		{GenerateOptions{Type: "e"}, "", false},
+	}
+	for _, table := range tables {
+		err := table.o.validate()
+		if (err == nil) != table.ok {
+			t.Errorf("%+v: got %v", table.o, err)
+		}
+		if table.ok && table.o.DefaultName() != table.name {
+			t.Errorf("got %s", table.o.DefaultName())
+		}
+	}
+}
@@ -1 +1,178 @@
+// Package storage 是一个简单的key-value存储, 用来持久化log
 package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrNotFound = errors.New("storage: key not found")
+
+// Storage 是key-value存储的接口
+type Storage interface {
+	Get(key []byte) ([]byte, error)
+	Put(key []byte, value []byte) error
+	Close() error
+}
+
+// 内存中的存储, 用于测试
+type memoryStorage struct {
+	mu sync.RWMutex
+	m  map[string][]byte
+}
+
+// NewMemoryStorage 创建内存中的Storage
+func NewMemoryStorage() Storage {
+	return &memoryStorage{m: make(map[string][]byte)}
+}
+
+func (s *memoryStorage) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.m[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (s *memoryStorage) Put(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *memoryStorage) Close() error { return nil }
+
+// 文件存储: 只追加的记录文件, 打开时读入内存。
+// 每条记录为 header crc32(4) | body crc32(4) | len(key)(4) | len(value)(4) | key | value, 同一个key以最后一条为准。
+// header crc32覆盖header的其余12字节, 所以损坏的长度不会被当作写了一半的记录
+type fileStorage struct {
+	memoryStorage
+	f *os.File
+}
+
+const recordHeaderSize = 16
+
+// OpenFileStorage 打开(或创建)path处的文件存储。
+// 文件末尾不完整的记录(写入时崩溃)会被截断, 其他位置的损坏返回错误。
+func OpenFileStorage(path string) (Storage, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &fileStorage{
+		memoryStorage: memoryStorage{m: make(map[string][]byte)},
+		f:             f,
+	}
+	valid, err := s.load()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 读取所有记录, 返回最后一条完整记录的结束位置。
+// 只有文件的最后一条记录可以不完整: header不完整, 或者header有效但是内容超出了文件末尾
+func (s *fileStorage) load() (int64, error) {
+	info, err := s.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(s.f)
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return offset, nil
+		}
+		if crc32.ChecksumIEEE(header[4:]) != binary.BigEndian.Uint32(header[0:4]) {
+			return 0, fmt.Errorf("storage: corrupted record header at offset %d", offset)
+		}
+		sum := binary.BigEndian.Uint32(header[4:8])
+		keyLen := binary.BigEndian.Uint32(header[8:12])
+		valueLen := binary.BigEndian.Uint32(header[12:16])
+		if offset+recordHeaderSize+int64(keyLen)+int64(valueLen) > info.Size() {
+			return offset, nil
+		}
+
+		body := make([]byte, int64(keyLen)+int64(valueLen))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return 0, err
+		}
+		if crc32.ChecksumIEEE(body) != sum {
+			return 0, fmt.Errorf("storage: corrupted record at offset %d", offset)
+		}
+		s.m[string(body[:keyLen])] = body[keyLen:]
+		offset += recordHeaderSize + int64(len(body))
+	}
+}
+
+func (s *fileStorage) Put(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body := append(append([]byte{}, key...), value...)
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(body))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(body))
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[12:16], uint32(len(value)))
+	binary.BigEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(record[4:16]))
+	record = append(record, body...)
+
+	if _, err := s.f.Write(record); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.m[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *fileStorage) Close() error {
+	return s.f.Close()
+}
+
+// 命名空间: 所有key加上前缀, 多个log可以共享同一个底层存储
+type namespace struct {
+	s      Storage
+	prefix []byte
+}
+
+// Namespace 返回s中以prefix为前缀的部分。Close不会关闭s, 底层存储由创建者关闭
+func Namespace(s Storage, prefix string) Storage {
+	return &namespace{s: s, prefix: []byte(prefix)}
+}
+
+func (n *namespace) key(key []byte) []byte {
+	return append(append([]byte{}, n.prefix...), key...)
+}
+
+func (n *namespace) Get(key []byte) ([]byte, error) {
+	return n.s.Get(n.key(key))
+}
+
+func (n *namespace) Put(key []byte, value []byte) error {
+	return n.s.Put(n.key(key), value)
+}
+
+func (n *namespace) Close() error { return nil }
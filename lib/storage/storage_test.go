@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	s, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put([]byte("a"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put([]byte("b"), nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// 模拟写入一半时崩溃
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0})
+	f.Close()
+
+	s, err = OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, err := s.Get([]byte("a")); err != nil || string(v) != "2" {
+		t.Errorf("got %q, %v", v, err)
+	}
+	if v, err := s.Get([]byte("b")); err != nil || len(v) != 0 {
+		t.Errorf("got %q, %v", v, err)
+	}
+	if _, err := s.Get([]byte("c")); err != ErrNotFound {
+		t.Errorf("got %v", err)
+	}
+	if err := s.Put([]byte("c"), []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.Get([]byte("c")); string(v) != "3" {
+		t.Errorf("got %q", v)
+	}
+}
+
+func TestFileStorageCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	s, err := OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Put([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := len(data) / 3
+
+	// 最后一条记录的header完整但内容没有写完, 被截断
+	torn := append(append([]byte{}, data...), data[record:2*record-1]...)
+	os.WriteFile(path, torn, 0o644)
+	s, err = OpenFileStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Errorf("torn record was not truncated: %d bytes", len(got))
+	}
+
+	// 中间记录的长度或内容损坏时不能截断后面的记录
+	for _, offset := range []int{record + 11, record + 17, 2*record + 4} {
+		corrupted := append([]byte{}, data...)
+		corrupted[offset] ^= 0xff
+		os.WriteFile(path, corrupted, 0o644)
+		if _, err := OpenFileStorage(path); err == nil {
+			t.Errorf("offset %d: corruption was not detected", offset)
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, corrupted) {
+			t.Errorf("offset %d: corrupted file was truncated", offset)
+		}
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	s := NewMemoryStorage()
+	a, b := Namespace(s, "logs/a/"), Namespace(s, "logs/b/")
+	if err := a.Put([]byte("size"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Get([]byte("size")); err != ErrNotFound {
+		t.Errorf("namespaces should be separate, got %v", err)
+	}
+	if v, err := s.Get([]byte("logs/a/size")); err != nil || string(v) != "1" {
+		t.Errorf("got %q, %v", v, err)
+	}
+	// 关闭命名空间不影响底层存储
+	a.Close()
+	if err := b.Put([]byte("size"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package vrf 是基于BLS唯一签名的verifiable random function。
+// 输入x的证明是BLS签名π = H(x)^sk, 输出是hash(π)。签名唯一, 所以输出由x和公钥唯一确定,
+// 没有私钥的人无法计算也无法区分输出。
+//
+// 目录用VRF的输出作为用户的key, 这样证明中只出现伪随机的key, 不会泄露其他用户名。
+package vrf
+
+import (
+	"MerkleVerkle/lib/bls"
+	"MerkleVerkle/lib/crypto"
+)
+
+const domain = "MerkleVerkle/vrf"
+
+// PrivateKey 计算VRF的输出和证明
+type PrivateKey struct {
+	key    *bls.PrivateKey
+	hasher crypto.Hasher
+}
+
+// PublicKey 验证VRF的证明
+type PublicKey struct {
+	key    *bls.PublicKey
+	hasher crypto.Hasher
+}
+
+// New 用BLS私钥创建VRF, 输出的长度是h.Size(), h为nil时使用crypto.Default
+func New(key *bls.PrivateKey, h crypto.Hasher) *PrivateKey {
+	if h == nil {
+		h = crypto.Default
+	}
+	return &PrivateKey{key: key, hasher: h}
+}
+
+// NewPublicKey 用BLS公钥创建VRF的验证方
+func NewPublicKey(key *bls.PublicKey, h crypto.Hasher) *PublicKey {
+	if h == nil {
+		h = crypto.Default
+	}
+	return &PublicKey{key: key, hasher: h}
+}
+
+// Public 返回对应的公钥
+func (k *PrivateKey) Public() *PublicKey {
+	return NewPublicKey(k.key.Public(), k.hasher)
+}
+
+// Evaluate 返回input的输出和证明
+func (k *PrivateKey) Evaluate(input []byte) ([]byte, []byte) {
+	proof := k.key.SignDomain(domain, input)
+	return output(k.hasher, proof), proof
+}
+
+// Verify 验证证明并返回输出, 验证失败时返回false
+func (k *PublicKey) Verify(input []byte, proof []byte) ([]byte, bool) {
+	if !k.key.VerifyDomain(domain, input, proof) {
+		return nil, false
+	}
+	return output(k.hasher, proof), true
+}
+
+// 证明已经通过了规范编码的检查, 所以同一个签名只有一种编码
+func output(h crypto.Hasher, proof []byte) []byte {
+	return h.Hash([]byte(domain), proof)
+}
+
+// Index 计算用户在目录中的key和证明
+func (k *PrivateKey) Index(user string) ([]byte, []byte, error) {
+	index, proof := k.Evaluate([]byte(user))
+	return index, proof, nil
+}
+
+// VerifyIndex 验证用户在目录中的key
+func (k *PublicKey) VerifyIndex(user string, proof []byte) ([]byte, bool) {
+	return k.Verify([]byte(user), proof)
+}
@@ -0,0 +1,65 @@
+package vrf
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"MerkleVerkle/lib/bls"
+	"MerkleVerkle/lib/params"
+)
+
+func newTestKey(t *testing.T) *PrivateKey {
+	p, err := params.Load(filepath.Join("..", "..", params.DefaultDir), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := bls.NewScheme(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := s.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(k, nil)
+}
+
+func TestVRF(t *testing.T) {
+	k := newTestKey(t)
+	pub := k.Public()
+
+	out, proof := k.Evaluate([]byte("alice"))
+	if len(out) != 32 {
+		t.Errorf("output length %d", len(out))
+	}
+	got, ok := pub.Verify([]byte("alice"), proof)
+	if !ok || !bytes.Equal(got, out) {
+		t.Error("valid proof rejected")
+	}
+	if _, ok := pub.Verify([]byte("bob"), proof); ok {
+		t.Error("proof accepted for another input")
+	}
+	out2, _ := k.Evaluate([]byte("bob"))
+	if bytes.Equal(out, out2) {
+		t.Error("different inputs have the same output")
+	}
+
+	// 另一个私钥的输出不同, 也不能通过验证
+	other := newTestKey(t)
+	out3, proof3 := other.Evaluate([]byte("alice"))
+	if bytes.Equal(out, out3) {
+		t.Error("different keys have the same output")
+	}
+	if _, ok := pub.Verify([]byte("alice"), proof3); ok {
+		t.Error("proof accepted for another key")
+	}
+
+	index, indexProof, err := k.Index("alice")
+	if err != nil || !bytes.Equal(index, out) {
+		t.Error("Index should match Evaluate")
+	}
+	if key, ok := pub.VerifyIndex("alice", indexProof); !ok || !bytes.Equal(key, index) {
+		t.Error("VerifyIndex rejected a valid proof")
+	}
+}
@@ -0,0 +1,875 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"MerkleVerkle/auditor"
+	"MerkleVerkle/bench"
+	"MerkleVerkle/core"
+	"MerkleVerkle/follower"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/params"
+	"MerkleVerkle/lib/storage"
+	"MerkleVerkle/pool"
+	"MerkleVerkle/server"
+	"MerkleVerkle/tile"
+)
+
+// 操作log的子命令都带有-store和-log
+type logFlags struct {
+	store string
+	name  string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *logFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	lf := &logFlags{}
+	fs.StringVar(&lf.store, "store", "cpat.db", "log storage file")
+	fs.StringVar(&lf.name, "log", "", "name of a log in the store, default the store's unnamed log")
+	return fs, lf
+}
+
+// 打开-log指定的log, 返回的函数关闭storage
+func openLog(lf *logFlags) (*ledger.Log, func() error, error) {
+	store, err := storage.OpenFileStorage(lf.store)
+	if err != nil {
+		return nil, nil, err
+	}
+	var l *ledger.Log
+	if lf.name == "" {
+		l, err = ledger.Open(store)
+	} else {
+		var r *ledger.Registry
+		if r, err = ledger.OpenRegistry(store); err == nil {
+			l, err = r.Log(lf.name)
+		}
+	}
+	if err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+	return l, store.Close, nil
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// -size必须是-1(当前大小)或者uint32范围内的大小
+func checkSize(size int64) error {
+	if size < -1 || size > math.MaxUint32 {
+		return fmt.Errorf("-size %d is out of range", size)
+	}
+	return nil
+}
+
+func parseUint32(s string) (uint32, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err
+}
+
+// 逗号分隔的整数列表, 例如"2,3,4"
+func parseUint32List(s string) ([]uint32, error) {
+	var res []uint32
+	for _, field := range strings.Split(s, ",") {
+		n, err := parseUint32(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func parseHasher(name string, size int) (crypto.Hasher, error) {
+	id, err := crypto.ParseHashID(name)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewHasher(id, size)
+}
+
+func parsePublicKey(s string) (ed25519.PublicKey, error) {
+	pub, err := hex.DecodeString(s)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("-public-key must be a hex Ed25519 public key")
+	}
+	return pub, nil
+}
+
+// 命名log的hash中混入了十六进制的tree ID
+func withTreeID(h crypto.Hasher, treeID string) (crypto.Hasher, error) {
+	id, err := hex.DecodeString(treeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tree id %q", treeID)
+	}
+	return crypto.WithTreeID(h, id)
+}
+
+func cmdInit(args []string) error {
+	fs, lf := newFlagSet("init")
+	depth := fs.Uint("depth", 16, "depth of the Merkle prefix tree, the log holds 2^depth epochs")
+	k := fs.Uint("k", 3, "branching factor of each epoch's verkle tree")
+	verkleDepth := fs.Uint("verkle-depth", 3, "depth of each epoch's verkle tree")
+	param := fs.String("param", "", "pairing parameter name in -param-dir (e.g. a, d159) or a parameter file")
+	paramDir := fs.String("param-dir", params.DefaultDir, "directory of the pairing parameter files")
+	hash := fs.String("hash", crypto.SHAKE128.String(), "hash function: shake128, sha3-256, sha256 or rfc6962")
+	hashSize := fs.Int("hash-size", 32, "hash output length in bytes")
+	origin := fs.String("origin", "", "checkpoint origin, e.g. example.com/log (default derived from the tree id)")
+	chained := fs.Bool("chained", false, "commit the previous digest's bagged root in every epoch leaf")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *param == "" {
+		return errors.New("missing -param")
+	}
+	paramPath := *param
+	if !strings.ContainsAny(paramPath, `/\`) && !strings.HasSuffix(paramPath, ".param") {
+		paramPath = filepath.Join(*paramDir, paramPath+".param")
+	}
+	p, err := params.LoadFile(paramPath)
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.OpenFileStorage(lf.store)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	cfg := ledger.Config{
+		Depth:       uint32(*depth),
+		K:           uint32(*k),
+		VerkleDepth: uint32(*verkleDepth),
+		Hash:        *hash,
+		HashSize:    *hashSize,
+		ParamFile:   paramPath,
+		Params:      p.Text,
+		Origin:      *origin,
+		Chained:     *chained,
+	}
+	if lf.name == "" {
+		_, err = ledger.Create(store, cfg)
+		return err
+	}
+	r, err := ledger.OpenRegistry(store)
+	if err != nil {
+		return err
+	}
+	l, err := r.Create(lf.name, cfg)
+	if err != nil {
+		return err
+	}
+	if id := l.Config().TreeID; id != "" {
+		fmt.Printf("tree id: %s\n", id)
+	}
+	return nil
+}
+
+func cmdAppend(args []string) error {
+	fs, lf := newFlagSet("append")
+	metadata := fs.String("metadata", "", "metadata committed in the epoch leaf (chained logs only)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var values [][]byte
+	if fs.NArg() == 1 && fs.Arg(0) == "-" {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			values = append(values, []byte(scanner.Text()))
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	} else {
+		for _, arg := range fs.Args() {
+			values = append(values, []byte(arg))
+		}
+	}
+
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	epoch, err := l.AppendMetadata(values, []byte(*metadata))
+	if err != nil {
+		return err
+	}
+	digest, err := l.Tree().GetOldDigest(epoch + 1)
+	if err != nil {
+		return err
+	}
+	return printJSON(digest)
+}
+
+func cmdDigest(args []string) error {
+	fs, lf := newFlagSet("digest")
+	size := fs.Int64("size", -1, "size of the digest, default the current size")
+	at := fs.String("time", "", "print the digest as of this RFC 3339 time instead (chained logs only)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkSize(*size); err != nil {
+		return err
+	}
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	snap := l.Tree().Snapshot()
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return err
+		}
+		if t.UnixMilli() < 0 {
+			return core.ErrBeforeLogTime
+		}
+		epoch, err := snap.EpochAt(uint64(t.UnixMilli()))
+		if err != nil {
+			return err
+		}
+		*size = int64(epoch) + 1
+	}
+	if *size < 0 {
+		return printJSON(snap.Digest())
+	}
+	digest, err := snap.GetOldDigest(uint32(*size))
+	if err != nil {
+		return err
+	}
+	return printJSON(digest)
+}
+
+func cmdProveConsistency(args []string) error {
+	fs, lf := newFlagSet("prove-consistency")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: prove-consistency OLD NEW")
+	}
+	oldSize, err := parseUint32(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newSize, err := parseUint32(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	proof, err := l.Tree().Snapshot().GenerateConsistencyProof(oldSize, newSize)
+	if err != nil {
+		return err
+	}
+	return printJSON(proof)
+}
+
+func cmdProveInclusion(args []string) error {
+	fs, lf := newFlagSet("prove-inclusion")
+	size := fs.Int64("size", -1, "size of the digest, default the current size")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: prove-inclusion [-size N] EPOCH")
+	}
+	if err := checkSize(*size); err != nil {
+		return err
+	}
+	epoch, err := parseUint32(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	snap := l.Tree().Snapshot()
+	if *size >= 0 {
+		if snap, err = snap.At(uint32(*size)); err != nil {
+			return err
+		}
+	}
+	proof, err := snap.GenerateInclusionProof(epoch)
+	if err != nil {
+		return err
+	}
+	return printJSON(proof)
+}
+
+func readDigest(path string) (*core.Digest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var digest core.Digest
+	if err := json.Unmarshal(data, &digest); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &digest, nil
+}
+
+// 根据JSON的字段判断proof的类型, JSON的解析是严格的, 只有一种类型能解析成功
+func cmdVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	digestPath := fs.String("digest", "", "digest file the proof is checked against")
+	oldPath := fs.String("old", "", "old digest file, for consistency proofs")
+	treeID := fs.String("tree-id", "", "hex tree id of the log, for named logs")
+	k := fs.Uint("k", 0, "branching factor of the log's verkle trees, for lookup proofs")
+	verkleDepth := fs.Uint("verkle-depth", 0, "depth of the log's verkle trees, for lookup proofs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *digestPath == "" {
+		return errors.New("usage: verify -digest FILE [-old FILE] PROOF")
+	}
+	digest, err := readDigest(*digestPath)
+	if err != nil {
+		return err
+	}
+	h, err := crypto.NewHasher(digest.HashID, int(digest.HashSize))
+	if err != nil {
+		return err
+	}
+	if h, err = withTreeID(h, *treeID); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	var lookup core.LookupProof
+	var inclusion core.MerkleInclusionProof
+	var consistency core.MerkleConsistencyProof
+	switch {
+	case json.Unmarshal(data, &lookup) == nil:
+		if *k == 0 || *verkleDepth == 0 {
+			return errors.New("lookup proofs need -k and -verkle-depth")
+		}
+		ok = core.VerifyLookupProof(h, uint32(*k), uint32(*verkleDepth), digest, &lookup)
+	case json.Unmarshal(data, &inclusion) == nil:
+		ok = core.VerifyInclusionProof(h, digest, &inclusion)
+	case json.Unmarshal(data, &consistency) == nil:
+		if *oldPath == "" {
+			return errors.New("consistency proofs need -old")
+		}
+		oldDigest, err := readDigest(*oldPath)
+		if err != nil {
+			return err
+		}
+		ok = core.VerifyExtensionProof(h, oldDigest, digest, &consistency)
+	default:
+		return fmt.Errorf("%s is not a proof", fs.Arg(0))
+	}
+
+	if !ok {
+		return errors.New("verification failed")
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func cmdInspect(args []string) error {
+	fs, lf := newFlagSet("inspect")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	if fs.NArg() == 1 {
+		epoch, err := parseUint32(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		values, err := l.Values(epoch)
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			fmt.Printf("%d\t%q\n", i, v)
+		}
+		return nil
+	}
+
+	cfg := l.Config()
+	digest := l.Tree().Snapshot().Digest()
+	fmt.Printf("depth:        %d\n", cfg.Depth)
+	fmt.Printf("k:            %d\n", cfg.K)
+	fmt.Printf("verkle depth: %d\n", cfg.VerkleDepth)
+	fmt.Printf("hash:         %s/%d\n", cfg.Hash, cfg.HashSize)
+	fmt.Printf("param file:   %s\n", cfg.ParamFile)
+	if cfg.TreeID != "" {
+		fmt.Printf("tree id:      %s\n", cfg.TreeID)
+	}
+	if cfg.Chained {
+		fmt.Printf("chained:      true\n")
+	}
+	fmt.Printf("public key:   %s\n", hex.EncodeToString(l.PublicKey()))
+	fmt.Printf("note key:     %s\n", l.VerifierKey())
+	p, err := cfg.LoadParams()
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		fmt.Printf("curve:        %s\n", p)
+	}
+	fmt.Printf("size:         %d\n", digest.Size)
+	fmt.Printf("bagged root:  %s\n", hex.EncodeToString(digest.BaggedRoot(l.Tree().Hasher())))
+	for i, root := range digest.Roots {
+		fmt.Printf("root %d:       %s\n", i, hex.EncodeToString(root))
+	}
+	return nil
+}
+
+func cmdServe(args []string) error {
+	fs, lf := newFlagSet("serve")
+	addr := fs.String("addr", "localhost:8080", "listen address")
+	poolPath := fs.String("pool", "", "storage file of the submission pool; accept single-key updates on /submit")
+	batch := fs.Int("batch", 0, "with -pool, seal an epoch once it has this many entries (default the verkle tree capacity)")
+	delay := fs.Duration("batch-delay", 0, "with -pool, seal an epoch at most this long after its first entry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *poolPath != "" {
+		return servePool(lf, *addr, *poolPath, pool.Config{MaxEntries: *batch, MaxDelay: *delay})
+	}
+	if lf.name != "" {
+		l, closeLog, err := openLog(lf)
+		if err != nil {
+			return err
+		}
+		defer closeLog()
+		fmt.Fprintf(os.Stderr, "serving %s/%s on %s\n", lf.store, lf.name, *addr)
+		return http.ListenAndServe(*addr, server.New(l))
+	}
+
+	// 没有-log时提供store中的所有log: 命名log在/logs/{name}下, 未命名的log(如果有)在根路径下
+	store, err := storage.OpenFileStorage(lf.store)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	r, err := ledger.OpenRegistry(store)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	multi := server.NewMulti(r)
+	mux.Handle(server.PathLogs, multi)
+	mux.Handle(server.PathLogs+"/", multi)
+	if l, err := ledger.Open(store); err == nil {
+		mux.Handle("/", server.New(l))
+	} else if !errors.Is(err, ledger.ErrNotInitialized) {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "serving %s (%d named logs) on %s\n", lf.store, len(r.Names()), *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+// 通过pool提供-log指定的log, pool是log唯一的写入者
+func servePool(lf *logFlags, addr string, path string, cfg pool.Config) error {
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	store, err := storage.OpenFileStorage(path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	p, err := pool.New(l, store, cfg)
+	if err != nil {
+		return err
+	}
+	go p.Run(context.Background())
+	fmt.Fprintf(os.Stderr, "serving %s with pool %s (%d pending) on %s\n", lf.store, path, p.Pending(), addr)
+	return http.ListenAndServe(addr, server.NewWithPool(l, p))
+}
+
+func cmdTiles(args []string) error {
+	fs, lf := newFlagSet("tiles")
+	dir := fs.String("dir", "tiles", "output directory, served by any static web server")
+	height := fs.Int("height", tile.DefaultHeight, "tile height, each tile holds 2^height hashes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	l, closeLog, err := openLog(lf)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	snap := l.Tree().Snapshot()
+	n, err := tile.Export(*dir, *height, snap, l.Sign(snap.Digest()))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d new tiles to %s for size %d\n", n, *dir, snap.Size)
+	return nil
+}
+
+func cmdFollow(args []string) error {
+	fs := flag.NewFlagSet("follow", flag.ContinueOnError)
+	storePath := fs.String("store", "replica.db", "replica storage file")
+	primary := fs.String("primary", "http://localhost:8080", "primary log URL, .../logs/NAME for a named log")
+	publicKey := fs.String("public-key", "", "hex public key of the primary, as printed by inspect")
+	addr := fs.String("addr", "localhost:8081", "listen address of the read-only server")
+	interval := fs.Duration("interval", 10*time.Second, "polling interval")
+	batch := fs.Uint("batch", follower.DefaultBatchSize, "epochs fetched between signature checks")
+	once := fs.Bool("once", false, "sync once, print the verified signed digest and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pub, err := parsePublicKey(*publicKey)
+	if err != nil {
+		return err
+	}
+	store, err := storage.OpenFileStorage(*storePath)
+	if err != nil {
+		return err
+	}
+	f, err := follower.New(store, follower.Config{
+		PrimaryURL: *primary,
+		PublicKey:  pub,
+		BatchSize:  uint32(*batch),
+		Interval:   *interval,
+	})
+	if err != nil {
+		store.Close()
+		return err
+	}
+	defer f.Close()
+
+	if *once {
+		if _, err := f.Sync(); err != nil {
+			return err
+		}
+		return printJSON(f.Signed())
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- f.Run(context.Background()) }()
+	go func() { errc <- http.ListenAndServe(*addr, server.NewReadOnly(f.Log(), f)) }()
+	fmt.Fprintf(os.Stderr, "following %s, serving on %s\n", *primary, *addr)
+	return <-errc
+}
+
+func cmdAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	serverURL := fs.String("server", "http://localhost:8080", "log server URL")
+	state := fs.String("state", "audit-state.json", "file holding the last verified digest")
+	alerts := fs.String("alerts", "alerts.jsonl", "file the alerts are appended to")
+	interval := fs.Duration("interval", time.Minute, "polling interval")
+	once := fs.Bool("once", false, "poll once and exit")
+	recompute := fs.Bool("recompute", false, "download new epochs and recompute their commitments")
+	k := fs.Uint("k", 3, "branching factor of the log's verkle trees, for -recompute")
+	verkleDepth := fs.Uint("verkle-depth", 3, "depth of the log's verkle trees, for -recompute")
+	hash := fs.String("hash", crypto.SHAKE128.String(), "hash function of the log")
+	hashSize := fs.Int("hash-size", 32, "hash output length in bytes")
+	treeID := fs.String("tree-id", "", "hex tree id of the log, for named logs")
+	tiles := fs.Int("tiles", 0, "if > 0, -server is a directory exported by tiles with this tile height")
+	publicKey := fs.String("public-key", "", "hex public key of the log; alerts then carry signed evidence for the evidence command")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	h, err := parseHasher(*hash, *hashSize)
+	if err != nil {
+		return err
+	}
+	if h, err = withTreeID(h, *treeID); err != nil {
+		return err
+	}
+	var pub ed25519.PublicKey
+	if *publicKey != "" {
+		if pub, err = parsePublicKey(*publicKey); err != nil {
+			return err
+		}
+	}
+	a, err := auditor.New(auditor.Config{
+		ServerURL:   *serverURL,
+		StatePath:   *state,
+		AlertPath:   *alerts,
+		Interval:    *interval,
+		Hasher:      h,
+		Tiles:       *tiles,
+		PublicKey:   pub,
+		Recompute:   *recompute,
+		K:           uint32(*k),
+		VerkleDepth: uint32(*verkleDepth),
+	})
+	if err != nil {
+		return err
+	}
+
+	if *once {
+		alert, err := a.Poll()
+		if err != nil {
+			return err
+		}
+		if alert != nil {
+			return fmt.Errorf("%s: %s", alert.Kind, alert.Message)
+		}
+		return printJSON(a.Trusted())
+	}
+	return a.Run(context.Background())
+}
+
+func cmdEvidence(args []string) error {
+	fs := flag.NewFlagSet("evidence", flag.ContinueOnError)
+	publicKey := fs.String("public-key", "", "hex public key of the log")
+	k := fs.Uint("k", 0, "branching factor of the log's verkle trees, for broken promises")
+	verkleDepth := fs.Uint("verkle-depth", 0, "depth of the log's verkle trees, for broken promises")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: evidence -public-key HEX [-k K -verkle-depth V] FILE")
+	}
+	pub, err := parsePublicKey(*publicKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// 可以是证据本身, 也可以是auditor的告警, 带有promise时是没有兑现promise的证据
+	var probe struct {
+		Promise json.RawMessage `json:"promise"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	var m *core.Misbehaviour
+	if probe.Promise != nil {
+		var ev pool.PromiseEvidence
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return err
+		}
+		if *k == 0 || *verkleDepth == 0 {
+			return errors.New("broken promises need -k and -verkle-depth")
+		}
+		if m, err = pool.VerifyBrokenPromise(pub, uint32(*k), uint32(*verkleDepth), &ev); err != nil {
+			return err
+		}
+	} else {
+		var alert auditor.Alert
+		if err := json.Unmarshal(data, &alert); err != nil {
+			return err
+		}
+		ev := alert.Evidence
+		if ev == nil {
+			ev = &core.MisbehaviourEvidence{}
+			if err := json.Unmarshal(data, ev); err != nil {
+				return err
+			}
+		}
+		if m, err = core.VerifyMisbehaviour(pub, ev); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("misbehaviour: %s\n", m.Kind)
+	fmt.Printf("conclusive:   %v\n", m.Conclusive)
+	return nil
+}
+
+func cmdBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	depths := fs.String("depth", "8,12,16", "comma separated depths of the Merkle prefix tree")
+	ks := fs.String("k", "3", "comma separated branching factors of the verkle trees")
+	verkleDepths := fs.String("verkle-depth", "3", "comma separated depths of the verkle trees")
+	leaves := fs.String("leaves", "27", "comma separated numbers of leaves per epoch")
+	epochs := fs.Uint("epochs", 256, "epochs appended per run, 0 fills the tree")
+	hash := fs.String("hash", crypto.SHAKE128.String(), "hash function: shake128, sha3-256, sha256 or rfc6962")
+	hashSize := fs.Int("hash-size", 32, "hash output length in bytes")
+	out := fs.String("o", "", "CSV output file, default stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	h, err := parseHasher(*hash, *hashSize)
+	if err != nil {
+		return err
+	}
+	lists := make([][]uint32, 4)
+	for i, s := range []string{*depths, *ks, *verkleDepths, *leaves} {
+		if lists[i], err = parseUint32List(s); err != nil {
+			return err
+		}
+	}
+
+	var results []*bench.Result
+	for _, p := range bench.Sweep(lists[0], lists[1], lists[2], lists[3], uint32(*epochs)) {
+		// 填不满的组合直接截断epoch数
+		if p.Epochs > 1<<p.Depth {
+			p.Epochs = 1 << p.Depth
+		}
+		fmt.Fprintf(os.Stderr, "depth=%d k=%d verkle-depth=%d leaves=%d epochs=%d\n", p.Depth, p.K, p.VerkleDepth, p.LeavesPerEpoch, p.Epochs)
+		r, err := bench.Run(p, h)
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return bench.WriteCSV(w, results)
+}
+
+// params有自己的子命令: list, generate, bench
+func cmdParams(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: params list|generate|bench [flags]")
+	}
+	switch args[0] {
+	case "list":
+		return cmdParamsList(args[1:])
+	case "generate":
+		return cmdParamsGenerate(args[1:])
+	case "bench":
+		return cmdParamsBench(args[1:])
+	}
+	return fmt.Errorf("unknown params command %q", args[0])
+}
+
+func cmdParamsList(args []string) error {
+	fs := flag.NewFlagSet("params list", flag.ContinueOnError)
+	dir := fs.String("dir", params.DefaultDir, "directory of the pairing parameter files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	names, err := params.Names(*dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		p, err := params.Load(*dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(p)
+	}
+	return nil
+}
+
+func cmdParamsGenerate(args []string) error {
+	fs := flag.NewFlagSet("params generate", flag.ContinueOnError)
+	dir := fs.String("dir", params.DefaultDir, "directory the parameter file is written to")
+	name := fs.String("name", "", "name of the parameter file, default derived from the options")
+	var o params.GenerateOptions
+	fs.StringVar(&o.Type, "type", "a", "pairing type: a, d, f or g")
+	rbits := fs.Uint("rbits", 160, "bits of the group order, for a, d and g")
+	qbits := fs.Uint("qbits", 512, "bits of the base field, for a, d and g")
+	d := fs.Uint("d", 0, "discriminant, for d and g")
+	bitlimit := fs.Uint("bitlimit", 0, "largest order to search, for d and g (0: no limit)")
+	bits := fs.Uint("bits", 160, "bits of the group order and base field, for f")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	o.RBits, o.QBits, o.D, o.BitLimit, o.Bits = uint32(*rbits), uint32(*qbits), uint32(*d), uint32(*bitlimit), uint32(*bits)
+	if *name == "" {
+		*name = o.DefaultName()
+	}
+	p, err := params.Generate(*name, o)
+	if err != nil {
+		return err
+	}
+	// 不覆盖已有的参数文件, 已经用它创建的log会失效
+	path := filepath.Join(*dir, *name+".param")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(p.Text); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+	fmt.Println(p)
+	return nil
+}
+
+func cmdParamsBench(args []string) error {
+	fs := flag.NewFlagSet("params bench", flag.ContinueOnError)
+	dir := fs.String("dir", params.DefaultDir, "directory of the pairing parameter files")
+	n := fs.Int("n", 100, "iterations of each operation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	names := fs.Args()
+	if len(names) == 0 {
+		var err error
+		if names, err = params.Names(*dir); err != nil {
+			return err
+		}
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"name", "type", "order_bits", "field_bits", "k", "security_bits", "symmetric",
+		"pairing_ns", "g1_exp_ns", "g2_exp_ns", "gt_exp_ns", "hash_to_g1_ns", "g1_bytes", "g2_bytes", "gt_bytes"})
+	for _, name := range names {
+		p, err := params.Load(*dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", p)
+		c, err := p.Benchmark(*n)
+		if err != nil {
+			return err
+		}
+		i, d := strconv.Itoa, func(v time.Duration) string { return strconv.FormatInt(v.Nanoseconds(), 10) }
+		w.Write([]string{p.Name, p.Type, i(p.OrderBits()), i(p.FieldBits()), i(p.EmbeddingDegree()), i(p.SecurityBits()),
+			strconv.FormatBool(c.Symmetric), d(c.Pairing), d(c.G1Exp), d(c.G2Exp), d(c.GTExp), d(c.HashToG1),
+			i(c.G1Bytes), i(c.G2Bytes), i(c.GTBytes)})
+		w.Flush()
+	}
+	return w.Error()
+}
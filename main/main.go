@@ -1,27 +1,90 @@
+// cpat 是操作MerklePT log的命令行工具
 package main
 
 import (
 	"fmt"
-
-	"github.com/Nik-U/pbc"
+	"os"
 )
 
-func main() {
-	// In a real application, generate this once and publish it
-	params := pbc.GenerateA(160, 512)
+const usage = `usage: cpat <command> [flags] [args]
+
+commands:
+  init               create a log: -depth D -k K -verkle-depth V -param NAME|FILE [-hash NAME -hash-size N -origin O -chained]
+  append VALUE...    append one epoch with the given leaf values ("-" reads values from stdin, one per line);
+                     on a chained log -metadata STR is committed in the epoch leaf
+  digest             print the current digest, the digest of an older size with -size N, or
+                     the digest as of an RFC 3339 time with -time T (chained logs only)
+  prove-consistency OLD NEW
+                     print the consistency proof from size OLD to size NEW
+  prove-inclusion EPOCH
+                     print the inclusion proof of EPOCH in the current digest, or in -size N
+  verify PROOF       verify a proof file against -digest FILE (and -old FILE for consistency proofs,
+                     -k K -verkle-depth V for lookup proofs)
+  inspect [EPOCH]    print the log configuration and roots, or the values of EPOCH
+  serve              serve the log over HTTP on -addr; without -log, every named log is served
+                     under /logs/NAME and the unnamed log (if any) under /; with -pool FILE, serve
+                     one log whose epochs are batches of single-key updates POSTed to /submit,
+                     sealed after -batch N entries or -batch-delay D
+  tiles              export the log's node hashes as immutable tlog-style tiles into -dir; run it
+                     after each append, clients (audit -tiles H) compute proofs from the tiles
+  follow             replicate the log on -primary (checked against its -public-key) into -store,
+                     verify the primary's signed digest after each batch and serve read-only
+                     proofs on -addr
+  bench              measure append, proof and verification costs over a parameter sweep and print CSV
+  params list|generate|bench
+                     list the pairing parameters in -dir, generate a new parameter file
+                     (-type a|d|f|g with -rbits, -qbits, -d, -bits), or print a CSV of pairing,
+                     exponentiation and hash-to-group costs for each curve
+  audit              poll a log server on -server, verify every new digest and append alerts to -alerts;
+                     with -public-key, inconsistency alerts carry the log's signed digests as evidence
+  evidence FILE      check misbehaviour evidence (or an alert carrying it) against -public-key and
+                     print whether the log signed contradictory digests or broke an inclusion promise
+                     (promises also need -k K -verkle-depth V)
 
-	pairing := params.NewPairing()
+all commands except verify, audit, evidence, follow, bench and params take -store FILE (default cpat.db)
+and -log NAME to use a named log in the store. each named log has its own parameters,
+signing key and tree id; init -log NAME creates one and prints its tree id, which
+verify and audit take as -tree-id HEX.
+digests and proofs are read and written as JSON. serve also publishes the digest as a C2SP
+signed-note checkpoint at /checkpoint, verifiable with the note key printed by inspect.
+epochs of a chained log (init -chained) commit the previous digest and their append time;
+serve answers /time?t=MS with a proof of the epoch that was current at that time.
+a pool submission returns a promise signed by the log: a ticket (key and epoch) and the
+value hash; once the epoch is sealed, /tickets?epoch=E&key=K resolves it to a lookup proof
+of the key's value; evidence also checks proof that the log broke such a promise.
+`
 
-	// Initialize group elements. pbc automatically handles garbage collection.
-	g := pairing.NewG1()
-	h := pairing.NewG2()
-	x := pairing.NewGT()
+type command func(args []string) error
 
-	// Generate random group elements and pair them
-	g.Rand()
-	h.Rand()
-	fmt.Printf("g = %s\n", g)
-	fmt.Printf("h = %s\n", h)
-	x.Pair(g, h)
-	fmt.Printf("e(g,h) = %s\n", x)
+var commands = map[string]command{
+	"init":              cmdInit,
+	"append":            cmdAppend,
+	"digest":            cmdDigest,
+	"prove-consistency": cmdProveConsistency,
+	"prove-inclusion":   cmdProveInclusion,
+	"verify":            cmdVerify,
+	"inspect":           cmdInspect,
+	"serve":             cmdServe,
+	"audit":             cmdAudit,
+	"evidence":          cmdEvidence,
+	"follow":            cmdFollow,
+	"tiles":             cmdTiles,
+	"bench":             cmdBench,
+	"params":            cmdParams,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "cpat: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "cpat %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
 }
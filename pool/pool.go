@@ -0,0 +1,317 @@
+// Package pool 收集单个key的更新, 达到个数或者等待时间的阈值时把它们作为一个epoch添加到log。
+// 每个epoch的叶子是按key排序的EncodeEntry(key, value), 一个key在一个epoch中最多出现一次:
+// 相同的key和值重复提交时返回原来的ticket, 同一个key的不同的值在前一个提交seal之前被拒绝。
+// ticket只包含key和预定的epoch, seal之后可以从log中解析为lookup proof, 不需要pool保存状态。
+//
+// pool必须是log唯一的写入者, 否则ticket中的epoch会被其他的Append占用。
+package pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/crypto"
+	"MerkleVerkle/lib/storage"
+)
+
+var (
+	ErrKeyPending   = errors.New("pool: key already has a different pending value")
+	ErrPending      = errors.New("pool: ticket is not sealed yet")
+	ErrNotIncluded  = errors.New("pool: entry is not in the ticket's epoch")
+	ErrInvalidEntry = errors.New("pool: invalid entry")
+	ErrEpochTaken   = errors.New("pool: log was appended outside the pool")
+	ErrCorrupted    = errors.New("pool: corrupted pending batch")
+	ErrFull         = errors.New("pool: batch is full and could not be sealed")
+)
+
+// MaxKeySize 是key的最大长度
+const MaxKeySize = 1024
+
+// 还没有seal的提交, 保存在Pool的storage中, 重启后继续
+var keyPending = []byte("pool/pending")
+
+// Config 是seal的阈值
+type Config struct {
+	MaxEntries int           // 达到这个个数时立即seal, 0或者超过verkle tree的容量时为容量
+	MaxDelay   time.Duration // 第一个提交之后最多等待的时间, 由Run检查, 0表示只按个数seal
+}
+
+// Ticket 是提交的凭证, seal之后用Resolve得到lookup proof, Submit返回的Promise中带有log对它的签名
+type Ticket struct {
+	Key   []byte `json:"key"`
+	Epoch uint32 `json:"epoch"` // 包含这个提交的epoch
+}
+
+// Pool 可以被多个goroutine并发使用
+type Pool struct {
+	cfg   Config
+	log   *ledger.Log
+	store storage.Storage
+
+	mu      sync.Mutex
+	epoch   uint32            // 当前batch将要seal为的epoch
+	pending map[string][]byte // key -> value
+	first   time.Time         // 当前batch第一个提交的时间
+}
+
+// New 创建log的Pool, store保存还没有seal的提交, 可以是单独的storage
+func New(l *ledger.Log, store storage.Storage, cfg Config) (*Pool, error) {
+	if capacity := capacity(l.Config()); cfg.MaxEntries <= 0 || uint64(cfg.MaxEntries) > capacity {
+		cfg.MaxEntries = int(capacity)
+	}
+	p := &Pool{cfg: cfg, log: l, store: store, pending: make(map[string][]byte)}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// 一个epoch的verkle tree最多的叶子数
+func capacity(cfg ledger.Config) uint64 {
+	n := uint64(1)
+	for i := uint32(0); i < cfg.VerkleDepth && n < 1<<31; i++ {
+		n *= uint64(cfg.K)
+	}
+	if n > 1<<31 {
+		n = 1 << 31
+	}
+	return n
+}
+
+// 读取保存的batch, 已经seal的batch(seal之后没有来得及清空)被丢弃
+func (p *Pool) load() error {
+	p.epoch = p.log.Tree().CurrentSize()
+	data, err := p.store.Get(keyPending)
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(data) < 4 {
+		return ErrCorrupted
+	}
+	if binary.BigEndian.Uint32(data) != p.epoch {
+		return nil
+	}
+	entries, err := ledger.DecodeValues(data[4:])
+	if err != nil {
+		return ErrCorrupted
+	}
+	for _, e := range entries {
+		key, value, err := DecodeEntry(e)
+		if err != nil {
+			return ErrCorrupted
+		}
+		p.pending[string(key)] = value
+	}
+	if len(p.pending) > 0 {
+		p.first = time.Now()
+	}
+	return nil
+}
+
+// EncodeEntry 编码叶子的值: len(key)(4) | key | value
+func EncodeEntry(key []byte, value []byte) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(key)))
+	buf = append(buf, key...)
+	return append(buf, value...)
+}
+
+// DecodeEntry 解码EncodeEntry的结果
+func DecodeEntry(leaf []byte) ([]byte, []byte, error) {
+	if len(leaf) < 4 {
+		return nil, nil, ErrInvalidEntry
+	}
+	n := binary.BigEndian.Uint32(leaf)
+	if n == 0 || n > MaxKeySize || uint64(len(leaf)-4) < uint64(n) {
+		return nil, nil, ErrInvalidEntry
+	}
+	return leaf[4 : 4+n], leaf[4+n:], nil
+}
+
+// Submit 把key的新值加入当前batch, 返回log签名的包含ticket的promise。batch达到MaxEntries时立即seal,
+// seal失败时batch保持已满, 之后的新key先重试seal, 仍然失败时返回ErrFull。
+// 提交在保存到storage之后才返回promise
+func (p *Pool) Submit(key []byte, value []byte) (*Promise, error) {
+	if len(key) == 0 || len(key) > MaxKeySize {
+		return nil, fmt.Errorf("%w: key length %d", ErrInvalidEntry, len(key))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ticket := &Ticket{Key: bytes.Clone(key), Epoch: p.epoch}
+	if old, ok := p.pending[string(key)]; ok {
+		if !bytes.Equal(old, value) {
+			return nil, ErrKeyPending
+		}
+		return p.promise(ticket, value), nil
+	}
+	if len(p.pending) >= p.cfg.MaxEntries {
+		if _, err := p.seal(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFull, err)
+		}
+		ticket.Epoch = p.epoch
+	}
+	p.pending[string(key)] = bytes.Clone(value)
+	if err := p.save(); err != nil {
+		delete(p.pending, string(key))
+		return nil, err
+	}
+	if len(p.pending) == 1 {
+		p.first = time.Now()
+	}
+	// seal失败时提交仍然在batch中, 由SealIfDue重试
+	if len(p.pending) >= p.cfg.MaxEntries {
+		if _, err := p.seal(); err != nil {
+			log.Printf("pool: seal failed: %v", err)
+		}
+	}
+	return p.promise(ticket, value), nil
+}
+
+func (p *Pool) save() error {
+	data := binary.BigEndian.AppendUint32(nil, p.epoch)
+	return p.store.Put(keyPending, append(data, ledger.EncodeValues(p.values())...))
+}
+
+// 当前batch的叶子, 按key排序
+func (p *Pool) values() [][]byte {
+	keys := make([]string, 0, len(p.pending))
+	for key := range p.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = EncodeEntry([]byte(key), p.pending[key])
+	}
+	return values
+}
+
+// Pending 返回当前batch中的提交个数
+func (p *Pool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.pending)
+}
+
+// Seal 立即把当前batch添加到log, 返回新的epoch。batch为空时什么也不做, 返回false
+func (p *Pool) Seal() (uint32, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.pending) == 0 {
+		return 0, false, nil
+	}
+	epoch, err := p.seal()
+	return epoch, err == nil, err
+}
+
+func (p *Pool) seal() (uint32, error) {
+	if size := p.log.Tree().CurrentSize(); size != p.epoch {
+		return 0, fmt.Errorf("%w: expected epoch %d, log has %d", ErrEpochTaken, p.epoch, size)
+	}
+	epoch, err := p.log.Append(p.values())
+	if err != nil {
+		return 0, err
+	}
+	p.epoch = epoch + 1
+	p.pending = make(map[string][]byte)
+	// 保存失败时重启后按epoch丢弃已经seal的batch
+	if err := p.save(); err != nil {
+		log.Printf("pool: clearing sealed batch: %v", err)
+	}
+	return epoch, nil
+}
+
+// SealIfDue 在当前batch的第一个提交已经等待了MaxDelay时seal
+func (p *Pool) SealIfDue() (uint32, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.pending) == 0 || p.cfg.MaxDelay <= 0 || time.Since(p.first) < p.cfg.MaxDelay {
+		return 0, false, nil
+	}
+	epoch, err := p.seal()
+	return epoch, err == nil, err
+}
+
+// Run 定期检查MaxDelay, 直到ctx结束。seal失败只记录日志
+func (p *Pool) Run(ctx context.Context) error {
+	interval := p.cfg.MaxDelay / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if epoch, ok, err := p.SealIfDue(); err != nil {
+			log.Printf("pool: seal failed: %v", err)
+		} else if ok {
+			log.Printf("pool: sealed epoch %d", epoch)
+		}
+	}
+}
+
+// Locate 返回ticket的提交在它的epoch中的位置, 还没有seal时返回ErrPending
+func (p *Pool) Locate(ticket *Ticket) (uint32, error) {
+	p.mu.Lock()
+	if ticket.Epoch >= p.epoch {
+		_, ok := p.pending[string(ticket.Key)]
+		p.mu.Unlock()
+		if ok && ticket.Epoch == p.epoch {
+			return 0, ErrPending
+		}
+		return 0, ErrNotIncluded
+	}
+	p.mu.Unlock()
+
+	values, err := p.log.Values(ticket.Epoch)
+	if err != nil {
+		return 0, err
+	}
+	pos := sort.Search(len(values), func(i int) bool {
+		key, _, err := DecodeEntry(values[i])
+		return err != nil || bytes.Compare(key, ticket.Key) >= 0
+	})
+	if pos == len(values) {
+		return 0, ErrNotIncluded
+	}
+	if key, _, err := DecodeEntry(values[pos]); err != nil || !bytes.Equal(key, ticket.Key) {
+		return 0, ErrNotIncluded
+	}
+	return uint32(pos), nil
+}
+
+// Resolve 返回ticket的提交对当前digest的lookup proof
+func (p *Pool) Resolve(ticket *Ticket) (*core.LookupProof, error) {
+	pos, err := p.Locate(ticket)
+	if err != nil {
+		return nil, err
+	}
+	return p.log.Tree().Snapshot().GenerateLookupProof(ticket.Epoch, pos)
+}
+
+// VerifyTicket 验证proof证明ticket的key在它的epoch中的值为value, k和depth是log的verkle tree的参数
+func VerifyTicket(h crypto.Hasher, k uint32, depth uint32, digest *core.Digest, ticket *Ticket, value []byte, proof *core.LookupProof) bool {
+	if proof.Epoch != ticket.Epoch || !core.VerifyLookupProof(h, k, depth, digest, proof) {
+		return false
+	}
+	key, v, err := DecodeEntry(proof.Value)
+	return err == nil && bytes.Equal(key, ticket.Key) && bytes.Equal(v, value)
+}
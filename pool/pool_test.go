@@ -0,0 +1,182 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+)
+
+func newTestLog(t *testing.T) *ledger.Log {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{Depth: 8, K: 2, VerkleDepth: 2, Hash: "shake128", HashSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestPool(t *testing.T) {
+	l := newTestLog(t)
+	p, err := New(l, storage.NewMemoryStorage(), Config{MaxEntries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tickets []*Ticket
+	for _, key := range []string{"c", "a", "b"} {
+		if key == "b" {
+			// 相同的值重复提交返回相同的ticket, 不同的值被拒绝
+			again, err := p.Submit([]byte("a"), []byte("value-a"))
+			if err != nil || again.Epoch != 0 {
+				t.Errorf("got %+v, %v", again, err)
+			}
+			if _, err := p.Submit([]byte("a"), []byte("other")); !errors.Is(err, ErrKeyPending) {
+				t.Errorf("got %v", err)
+			}
+			if _, err := p.Resolve(tickets[0]); !errors.Is(err, ErrPending) {
+				t.Errorf("got %v", err)
+			}
+		}
+		ticket, err := p.Submit([]byte(key), []byte("value-"+key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tickets = append(tickets, &ticket.Ticket)
+	}
+	// 第三个提交seal了epoch 0
+	if l.Tree().CurrentSize() != 1 || p.Pending() != 0 {
+		t.Fatalf("size %d, pending %d", l.Tree().CurrentSize(), p.Pending())
+	}
+
+	digest, err := l.Tree().GetOldDigest(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"c", "a", "b"} {
+		proof, err := p.Resolve(tickets[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyTicket(l.Tree().Hasher(), 2, 2, digest, tickets[i], []byte("value-"+key), proof) {
+			t.Errorf("%s: ticket failed", key)
+		}
+		if VerifyTicket(l.Tree().Hasher(), 2, 2, digest, tickets[i], []byte("other"), proof) {
+			t.Errorf("%s: ticket with another value should fail", key)
+		}
+	}
+	if pos, _ := p.Locate(tickets[1]); pos != 0 {
+		t.Errorf("entries should be sorted by key, got %d", pos)
+	}
+
+	// seal之后同一个key可以提交新的值
+	ticket, err := p.Submit([]byte("a"), []byte("other"))
+	if err != nil || ticket.Epoch != 1 {
+		t.Errorf("got %+v, %v", ticket, err)
+	}
+	if _, err := p.Resolve(&Ticket{Key: []byte("d"), Epoch: 0}); !errors.Is(err, ErrNotIncluded) {
+		t.Errorf("got %v", err)
+	}
+
+	if _, err := p.Submit(nil, []byte("v")); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestPoolDelay(t *testing.T) {
+	l := newTestLog(t)
+	p, err := New(l, storage.NewMemoryStorage(), Config{MaxDelay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := p.SealIfDue(); ok || err != nil {
+		t.Errorf("empty batch should not be sealed: %v", err)
+	}
+	if _, err := p.Submit([]byte("k"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := p.SealIfDue(); ok {
+		t.Error("batch sealed before MaxDelay")
+	}
+	time.Sleep(25 * time.Millisecond)
+	epoch, ok, err := p.SealIfDue()
+	if err != nil || !ok || epoch != 0 {
+		t.Errorf("got %d, %v, %v", epoch, ok, err)
+	}
+}
+
+func TestPoolReload(t *testing.T) {
+	l := newTestLog(t)
+	store := storage.NewMemoryStorage()
+	p, _ := New(l, store, Config{})
+	for i := 0; i < 3; i++ {
+		if _, err := p.Submit([]byte(fmt.Sprint("k", i)), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重启后继续没有seal的batch
+	p, err := New(l, store, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Pending() != 3 {
+		t.Fatalf("pending %d", p.Pending())
+	}
+
+	// log被其他的写入者添加后不能seal
+	if _, err := l.Append([][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Seal(); !errors.Is(err, ErrEpochTaken) {
+		t.Errorf("got %v", err)
+	}
+
+	// 已经不属于下一个epoch的batch被丢弃
+	p, _ = New(l, store, Config{})
+	if p.Pending() != 0 {
+		t.Errorf("stale batch was loaded")
+	}
+}
+
+func TestPoolSealFails(t *testing.T) {
+	l := newTestLog(t)
+	p, err := New(l, storage.NewMemoryStorage(), Config{MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit([]byte("a"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	// 其他写入者占用了epoch 0, 第二个提交的seal失败
+	if _, err := l.Append([][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit([]byte("b"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit([]byte("c"), []byte("v")); !errors.Is(err, ErrFull) {
+		t.Errorf("got %v", err)
+	}
+	if p.Pending() != 2 {
+		t.Errorf("pending %d", p.Pending())
+	}
+	// 已经在batch中的提交仍然返回promise
+	if _, err := p.Submit([]byte("a"), []byte("v")); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestEntry(t *testing.T) {
+	key, value, err := DecodeEntry(EncodeEntry([]byte("key"), []byte("value")))
+	if err != nil || string(key) != "key" || string(value) != "value" {
+		t.Errorf("got %q, %q, %v", key, value, err)
+	}
+	for _, leaf := range [][]byte{nil, {0, 0, 0, 0}, {0, 0, 0, 5, 'a'}} {
+		if _, _, err := DecodeEntry(leaf); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("%x: got %v", leaf, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package pool
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/crypto"
+)
+
+// 签名消息的前缀, 与digest的签名区分
+const promiseContext = "MerkleVerkle/inclusion-promise"
+
+// BrokenPromise 是log签名了promise, 但是promise的epoch中没有这个提交
+const BrokenPromise core.MisbehaviourKind = "broken_promise"
+
+// Promise 是Submit返回的log签名的承诺, 类似CT的SCT:
+// 大小超过Epoch的digest中, 第Epoch个epoch必须包含Key, 它的值的hash为ValueHash。
+// 提交seal之前客户端可以用它追究log的责任
+type Promise struct {
+	Ticket
+	TreeID    []byte `json:"tree_id"`
+	ValueHash []byte `json:"value_hash"` // log的hash函数计算的值的hash
+	Signature []byte `json:"signature"`
+}
+
+// 被签名的消息: context | tree ID | key | value hash, 每个带长度, 之后是epoch(4)
+func promiseMessage(pr *Promise) []byte {
+	msg := ledger.EncodeValues([][]byte{[]byte(promiseContext), pr.TreeID, pr.Key, pr.ValueHash})
+	return binary.BigEndian.AppendUint32(msg, pr.Epoch)
+}
+
+// 对ticket和值签名
+func (p *Pool) promise(ticket *Ticket, value []byte) *Promise {
+	pr := &Promise{
+		Ticket:    *ticket,
+		TreeID:    p.log.TreeID(),
+		ValueHash: p.log.Tree().Hasher().Hash(value),
+	}
+	pr.Signature = p.log.SignMessage(promiseMessage(pr))
+	return pr
+}
+
+// VerifyPromise 验证promise的签名, 以及它覆盖的值是value。h是log的hash函数
+func VerifyPromise(pub ed25519.PublicKey, h crypto.Hasher, pr *Promise, value []byte) bool {
+	return verifyPromiseSignature(pub, pr) && bytes.Equal(pr.ValueHash, h.Hash(value))
+}
+
+func verifyPromiseSignature(pub ed25519.PublicKey, pr *Promise) bool {
+	if len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(pub, promiseMessage(pr), pr.Signature)
+}
+
+// PromiseEvidence 是log没有兑现promise的证据, 可以交给第三方用VerifyBrokenPromise复核。
+// Digest是log签名的大小超过promise.Epoch的digest, Lookup和Next是promise的epoch中对Digest的lookup proof:
+// Lookup是promise的key所在的位置, 或者key不存在时key应该在的位置之前的叶子, Next是Lookup之后的叶子
+type PromiseEvidence struct {
+	Promise *Promise           `json:"promise"`
+	Digest  *core.SignedDigest `json:"digest"`
+	Lookup  *core.LookupProof  `json:"lookup,omitempty"`
+	Next    *core.LookupProof  `json:"next,omitempty"`
+}
+
+// VerifyBrokenPromise 用log的公钥和verkle tree的参数k, depth复核证据, 不需要访问log。
+// 叶子按key排序, 所以Lookup的值不同, 或者Lookup和Next之间(Lookup为第0个叶子时之前, 为最后一个叶子时之后)应该有key时是确定的,
+// 叶子的位置从proof的路径和k计算, 不使用没有认证的Position字段;
+// 没有lookup proof时只说明log没有给出proof, 第三方可以向log重新请求。
+// 签名无效或者proof验证失败时返回core.ErrInvalidEvidence, promise已经兑现时返回core.ErrNoMisbehaviour
+func VerifyBrokenPromise(pub ed25519.PublicKey, k uint32, depth uint32, ev *PromiseEvidence) (*core.Misbehaviour, error) {
+	if ev.Promise == nil || ev.Digest == nil {
+		return nil, fmt.Errorf("%w: missing promise or signed digest", core.ErrInvalidEvidence)
+	}
+	pr := ev.Promise
+	if !verifyPromiseSignature(pub, pr) || !core.VerifySignedDigest(pub, ev.Digest) {
+		return nil, fmt.Errorf("%w: bad signature", core.ErrInvalidEvidence)
+	}
+	if !bytes.Equal(pr.TreeID, ev.Digest.TreeID) {
+		return nil, fmt.Errorf("%w: promise and digest of different trees", core.ErrInvalidEvidence)
+	}
+	if ev.Digest.Digest.Size <= pr.Epoch {
+		return nil, fmt.Errorf("%w: digest does not contain the promised epoch", core.ErrInvalidEvidence)
+	}
+	h, err := (&core.MisbehaviourEvidence{First: ev.Digest}).Hasher()
+	if err != nil {
+		return nil, err
+	}
+	broken := &core.Misbehaviour{Kind: BrokenPromise}
+	if ev.Lookup == nil {
+		if ev.Next != nil {
+			return nil, fmt.Errorf("%w: next lookup without lookup", core.ErrInvalidEvidence)
+		}
+		return broken, nil
+	}
+
+	pos, key, value, err := verifyEntry(h, k, depth, ev.Digest.Digest, pr.Epoch, ev.Lookup)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(key, pr.Key) {
+		if bytes.Equal(h.Hash(value), pr.ValueHash) {
+			return nil, core.ErrNoMisbehaviour
+		}
+		broken.Conclusive = true
+		return broken, nil
+	}
+	if ev.Next == nil {
+		// 第0个叶子已经在key之后, 或者最后一个叶子仍然在key之前
+		broken.Conclusive = pos == 0 && bytes.Compare(pr.Key, key) < 0 ||
+			lastLeaf(ev.Lookup.Path) && bytes.Compare(key, pr.Key) < 0
+		return broken, nil
+	}
+	nextPos, next, _, err := verifyEntry(h, k, depth, ev.Digest.Digest, pr.Epoch, ev.Next)
+	if err != nil {
+		return nil, err
+	}
+	if nextPos != pos+1 {
+		return nil, fmt.Errorf("%w: lookups are not adjacent", core.ErrInvalidEvidence)
+	}
+	broken.Conclusive = bytes.Compare(key, pr.Key) < 0 && bytes.Compare(pr.Key, next) < 0
+	return broken, nil
+}
+
+// 路径的每一层都是最右边的子节点时是verkle tree的最后一个叶子, 子节点由lookup proof认证
+func lastLeaf(path []core.LookupLevel) bool {
+	for _, level := range path {
+		if level.Index != uint32(len(level.Children))-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// 验证lookup proof, 返回从路径计算的叶子位置和解码的叶子
+func verifyEntry(h crypto.Hasher, k uint32, depth uint32, digest *core.Digest, epoch uint32, proof *core.LookupProof) (uint32, []byte, []byte, error) {
+	if proof.Epoch != epoch || !core.VerifyLookupProof(h, k, depth, digest, proof) {
+		return 0, nil, nil, fmt.Errorf("%w: lookup proof failed", core.ErrInvalidEvidence)
+	}
+	pos, ok := core.LookupPosition(k, depth, proof.Path)
+	if !ok {
+		return 0, nil, nil, fmt.Errorf("%w: lookup path failed", core.ErrInvalidEvidence)
+	}
+	key, value, err := DecodeEntry(proof.Value)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %v", core.ErrInvalidEvidence, err)
+	}
+	return pos, key, value, nil
+}
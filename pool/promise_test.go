@@ -0,0 +1,162 @@
+package pool
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+)
+
+// 提交key "b"之后由other代替pool添加epoch 0, 返回promise和epoch 0对当前digest的证据
+func brokenPromise(t *testing.T, other ...string) (*ledger.Log, *PromiseEvidence) {
+	l := newTestLog(t)
+	p, _ := New(l, storage.NewMemoryStorage(), Config{})
+	promise, err := p.Submit([]byte("b"), []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == nil {
+		if _, _, err := p.Seal(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		var values [][]byte
+		for _, key := range other {
+			values = append(values, EncodeEntry([]byte(key), []byte("other")))
+		}
+		if _, err := l.Append(values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := l.Tree().Snapshot()
+	return l, &PromiseEvidence{Promise: promise, Digest: l.Sign(snap.Digest())}
+}
+
+func lookup(t *testing.T, l *ledger.Log, pos uint32) *core.LookupProof {
+	proof, err := l.Tree().Snapshot().GenerateLookupProof(0, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestPromise(t *testing.T) {
+	l, ev := brokenPromise(t)
+	h := l.Tree().Hasher()
+	if !VerifyPromise(l.PublicKey(), h, ev.Promise, []byte("value")) {
+		t.Error("promise failed")
+	}
+	if VerifyPromise(l.PublicKey(), h, ev.Promise, []byte("other")) {
+		t.Error("promise for another value should fail")
+	}
+	changed := *ev.Promise
+	changed.Epoch = 1
+	if VerifyPromise(l.PublicKey(), h, &changed, []byte("value")) {
+		t.Error("promise for another epoch should fail")
+	}
+
+	// 兑现的promise
+	ev.Lookup = lookup(t, l, 0)
+	if _, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, ev); !errors.Is(err, core.ErrNoMisbehaviour) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestBrokenPromise(t *testing.T) {
+	tables := []struct {
+		name       string
+		other      []string
+		lookup     []uint32
+		conclusive bool
+	}{
+		{"other value", []string{"a", "b"}, []uint32{1}, true},
+		{"missing", []string{"a", "c"}, []uint32{0, 1}, true},
+		{"before first", []string{"c"}, []uint32{0}, true},
+		{"after last", []string{"a"}, []uint32{0}, true},
+		{"after last of two", []string{"a", "a1"}, []uint32{1}, true},
+		{"not last", []string{"a", "a1"}, []uint32{0}, false},
+		{"no proof", []string{"a"}, nil, false},
+	}
+	for _, table := range tables {
+		l, ev := brokenPromise(t, table.other...)
+		if len(table.lookup) > 0 {
+			ev.Lookup = lookup(t, l, table.lookup[0])
+		}
+		if len(table.lookup) > 1 {
+			ev.Next = lookup(t, l, table.lookup[1])
+		}
+		m, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, ev)
+		if err != nil || m.Kind != BrokenPromise || m.Conclusive != table.conclusive {
+			t.Errorf("%s: got %+v, %v", table.name, m, err)
+		}
+
+		// 证据可以用JSON交给第三方
+		data, err := json.Marshal(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded PromiseEvidence
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if m, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, &decoded); err != nil || m.Conclusive != table.conclusive {
+			t.Errorf("%s: decoded evidence got %+v, %v", table.name, m, err)
+		}
+	}
+}
+
+func TestInvalidPromiseEvidence(t *testing.T) {
+	l, ev := brokenPromise(t, "a", "c")
+	ev.Lookup, ev.Next = lookup(t, l, 0), lookup(t, l, 1)
+
+	// epoch还没有截止
+	early := *ev
+	empty, err := l.Tree().GetOldDigest(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	early.Digest = l.Sign(empty)
+	if _, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, &early); !errors.Is(err, core.ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+
+	// promise的签名无效
+	forged := *ev
+	promise := *ev.Promise
+	promise.Key = []byte("d")
+	forged.Promise = &promise
+	if _, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, &forged); !errors.Is(err, core.ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+
+	// lookup不相邻
+	skipped := *ev
+	skipped.Next = lookup(t, l, 0)
+	if _, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, &skipped); !errors.Is(err, core.ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+
+	// 改了Position的lookup: 第1个叶子"c"冒充第0个叶子, 或者冒充与第0个叶子相邻
+	relabelled := *ev
+	first := *lookup(t, l, 1)
+	first.Position = 0
+	relabelled.Lookup, relabelled.Next = &first, nil
+	if _, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, &relabelled); !errors.Is(err, core.ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+	relabelled = *ev
+	next := *lookup(t, l, 0)
+	next.Position = 1
+	relabelled.Next = &next
+	if _, err := VerifyBrokenPromise(l.PublicKey(), 2, 2, &relabelled); !errors.Is(err, core.ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+
+	other := newTestLog(t)
+	if _, err := VerifyBrokenPromise(other.PublicKey(), 2, 2, ev); !errors.Is(err, core.ErrInvalidEvidence) {
+		t.Errorf("got %v", err)
+	}
+}
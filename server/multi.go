@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"MerkleVerkle/ledger"
+)
+
+// Multi 在 /logs/{name}/... 下提供Registry中的所有log, 每个log的路由和单个log的Server相同。
+// 每个log有自己的tree ID, 所以一个log的proof在另一个log上不能验证通过
+type Multi struct {
+	registry *ledger.Registry
+	mux      *http.ServeMux
+
+	mu      sync.Mutex
+	servers map[string]*Server
+}
+
+// NewMulti 创建Multi, 之后通过registry创建的log也会被提供
+func NewMulti(r *ledger.Registry) *Multi {
+	m := &Multi{
+		registry: r,
+		mux:      http.NewServeMux(),
+		servers:  make(map[string]*Server),
+	}
+	m.mux.HandleFunc("GET "+PathLogs, m.handleList)
+	m.mux.HandleFunc(PathLogs+"/{name}/", m.handleLog)
+	return m
+}
+
+func (m *Multi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+// GET /logs: 所有log的名字
+func (m *Multi) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, m.registry.Names())
+}
+
+// /logs/{name}/...: 去掉前缀后交给这个log的Server
+func (m *Multi) handleLog(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s, err := m.server(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.StripPrefix(PathLogs+"/"+name, s).ServeHTTP(w, r)
+}
+
+func (m *Multi) server(name string) (*Server, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.servers[name]; ok {
+		return s, nil
+	}
+	l, err := m.registry.Log(name)
+	if err != nil {
+		return nil, err
+	}
+	s := New(l)
+	m.servers[name] = s
+	return s, nil
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+)
+
+func TestMulti(t *testing.T) {
+	r, err := ledger.OpenRegistry(storage.NewMemoryStorage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := r.Create(name, ledger.Config{Depth: 8, K: 3, VerkleDepth: 2, Hash: "shake128", HashSize: 32}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := httptest.NewServer(NewMulti(r))
+	defer ts.Close()
+
+	body, _ := get(t, ts.URL+PathLogs, "")
+	var names []string
+	if err := json.Unmarshal(body, &names); err != nil || len(names) != 2 {
+		t.Fatalf("got %s, %v", body, err)
+	}
+
+	// 两个log写入同样的值
+	infos := make(map[string]InfoResponse)
+	digests := make(map[string]*core.Digest)
+	for _, name := range names {
+		url := ts.URL + PathLogs + "/" + name
+		if status := postEpoch(t, url, "x", "y"); status != http.StatusOK {
+			t.Fatalf("%s: append: status %d", name, status)
+		}
+		body, _ := get(t, url+PathInfo, "")
+		var info InfoResponse
+		if err := json.Unmarshal(body, &info); err != nil {
+			t.Fatal(err)
+		}
+		body, _ = get(t, url+PathSigned, "")
+		var signed core.SignedDigest
+		if err := signed.UnmarshalBinary(body); err != nil {
+			t.Fatal(err)
+		}
+		if !core.VerifySignedDigest(info.PublicKey, &signed) || signed.Digest.Size != 1 {
+			t.Errorf("%s: invalid signed digest", name)
+		}
+		infos[name], digests[name] = info, signed.Digest
+	}
+	if infos["a"].Config.TreeID == infos["b"].Config.TreeID {
+		t.Fatal("logs should have different tree ids")
+	}
+
+	body, _ = get(t, ts.URL+PathLogs+"/a"+PathLookup+"?epoch=0&pos=0", "")
+	var lookup core.LookupProof
+	if err := lookup.UnmarshalBinary(body); err != nil {
+		t.Fatal(err)
+	}
+	ha, _ := infos["a"].Config.Hasher()
+	hb, _ := infos["b"].Config.Hasher()
+	if !core.VerifyLookupProof(ha, 3, 2, digests["a"], &lookup) {
+		t.Error("lookup proof failed")
+	}
+	// 即使把digest也换成b的, a的proof也不能验证
+	if core.VerifyLookupProof(hb, 3, 2, digests["b"], &lookup) || core.VerifyLookupProof(hb, 3, 2, digests["a"], &lookup) {
+		t.Error("proof from log a verified against log b")
+	}
+
+	for _, path := range []string{PathLogs + "/c" + PathDigest, PathLogs + "/a/unknown"} {
+		if _, status := get(t, ts.URL+path, ""); status != http.StatusNotFound {
+			t.Errorf("%s: got status %d", path, status)
+		}
+	}
+}
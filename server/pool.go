@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/pool"
+)
+
+// pool的路由, 只有NewWithPool创建的Server提供
+const (
+	PathSubmit  = "/submit"
+	PathTickets = "/tickets"
+)
+
+// SubmitRequest 是 POST /submit 的请求体, key和value使用base64编码
+type SubmitRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// NewWithPool 创建通过p添加epoch的Server: POST /submit提交单个key的更新, 返回签名的promise,
+// GET /tickets用ticket获取lookup proof。pool必须是log唯一的写入者, 所以没有 POST /epochs
+func NewWithPool(l *ledger.Log, p *pool.Pool) *Server {
+	s := newServer(l, nil)
+	s.pool = p
+	s.mux.HandleFunc("POST "+PathSubmit, s.handleSubmit)
+	s.mux.HandleFunc("GET "+PathTickets, s.handleTicket)
+	return s
+}
+
+// POST /submit: 返回JSON编码的pool.Promise
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req SubmitRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid submit request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		http.Error(w, "invalid submit request: trailing data", http.StatusBadRequest)
+		return
+	}
+	promise, err := s.pool.Submit(req.Key, req.Value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, promise)
+}
+
+// GET /tickets?epoch=E&key=K&size=N: ticket的提交的lookup proof, key使用URL安全的base64。
+// 还没有seal时返回202
+func (s *Server) handleTicket(w http.ResponseWriter, r *http.Request) {
+	epoch, err := queryUint32(r, "epoch")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	key, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("key"))
+	if err != nil {
+		writeError(w, &badRequest{"invalid parameter \"key\": " + err.Error()})
+		return
+	}
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pos, err := s.pool.Locate(&pool.Ticket{Key: key, Epoch: epoch})
+	if errors.Is(err, pool.ErrPending) {
+		http.Error(w, err.Error(), http.StatusAccepted)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := snap.GenerateLookupProof(epoch, pos)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, proof)
+}
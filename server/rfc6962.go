@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+)
+
+// RFC 6962 4.3-4.5的路由, 只有hash为rfc6962的log提供, 响应与CT log的JSON格式相同。
+// get-sth用log的Ed25519私钥签名, 只读时没有私钥, 所以没有get-sth
+const (
+	PathCTSTH         = "/ct/v1/get-sth"
+	PathCTConsistency = "/ct/v1/get-sth-consistency"
+	PathCTProof       = "/ct/v1/get-proof-by-hash"
+)
+
+// CTConsistencyResponse 是 GET /ct/v1/get-sth-consistency 的响应
+type CTConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+// CTProofResponse 是 GET /ct/v1/get-proof-by-hash 的响应
+type CTProofResponse struct {
+	LeafIndex uint64   `json:"leaf_index"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+func (s *Server) handleRFC6962() {
+	if s.tree.Hasher().ID() != crypto.RFC6962 {
+		return
+	}
+	s.mux.HandleFunc("GET "+PathCTSTH, s.handleCTSTH)
+	s.mux.HandleFunc("GET "+PathCTConsistency, s.handleCTConsistency)
+	s.mux.HandleFunc("GET "+PathCTProof, s.handleCTProof)
+}
+
+// GET /ct/v1/get-sth: 当前tree head, timestamp为签名的时间
+func (s *Server) handleCTSTH(w http.ResponseWriter, r *http.Request) {
+	if s.replica != nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, s.log.SignTreeHead(s.current().RFCTreeHead(), uint64(time.Now().UnixMilli())))
+}
+
+// GET /ct/v1/get-sth-consistency?first=M&second=N
+func (s *Server) handleCTConsistency(w http.ResponseWriter, r *http.Request) {
+	first, err := queryUint32(r, "first")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	second, err := queryUint32(r, "second")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := s.current().RFCConsistencyProof(first, second)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, CTConsistencyResponse{Consistency: proof})
+}
+
+// GET /ct/v1/get-proof-by-hash?hash=H&tree_size=N, hash是base64编码的leaf hash。
+// 没有leaf hash的索引, 按顺序查找第一个相同的叶子
+func (s *Server) handleCTProof(w http.ResponseWriter, r *http.Request) {
+	hash, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("hash"))
+	if err != nil || len(hash) != s.tree.Hasher().Size() {
+		writeError(w, &badRequest{"invalid parameter \"hash\""})
+		return
+	}
+	size, err := queryUint32(r, "tree_size")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap, err := s.current().At(size)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for epoch := uint32(0); epoch < snap.Size; epoch++ {
+		leaf, err := snap.RFCLeafHash(epoch)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !bytes.Equal(leaf, hash) {
+			continue
+		}
+		path, err := snap.RFCInclusionProof(epoch)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, CTProofResponse{LeafIndex: uint64(epoch), AuditPath: path})
+		return
+	}
+	writeError(w, fmt.Errorf("%w: no leaf with hash %x in tree of size %d", core.ErrLeafNotFound, hash, size))
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+)
+
+func TestServerRFC6962(t *testing.T) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{
+		Depth:       8,
+		K:           3,
+		VerkleDepth: 2,
+		Hash:        "rfc6962",
+		HashSize:    32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(New(l))
+	defer ts.Close()
+	for i := 0; i < 6; i++ {
+		if status := postEpoch(t, ts.URL, fmt.Sprint("a", i)); status != http.StatusOK {
+			t.Fatalf("append: status %d", status)
+		}
+	}
+	h := l.Tree().Hasher()
+
+	body, _ := get(t, ts.URL+PathCTSTH, "")
+	var sth core.SignedTreeHead
+	if err := json.Unmarshal(body, &sth); err != nil {
+		t.Fatal(err)
+	}
+	if sth.Timestamp == 0 || !core.VerifySignedTreeHead(l.PublicKey(), &sth) {
+		t.Errorf("invalid signed tree head %+v", sth)
+	}
+	oldDigest, err := l.Tree().GetOldDigest(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := oldDigest.RFCTreeHead(h)
+	if sth.TreeSize != 6 {
+		t.Fatalf("tree size %d", sth.TreeSize)
+	}
+
+	body, _ = get(t, ts.URL+PathCTConsistency+"?first=3&second=6", "")
+	var consistency CTConsistencyResponse
+	if err := json.Unmarshal(body, &consistency); err != nil {
+		t.Fatal(err)
+	}
+	if !core.VerifyRFCConsistency(h, 3, 6, old.RootHash, sth.RootHash, consistency.Consistency) {
+		t.Error("consistency proof failed")
+	}
+
+	leaf, _ := l.Tree().Snapshot().RFCLeafHash(4)
+	query := "?tree_size=6&hash=" + url.QueryEscape(base64.StdEncoding.EncodeToString(leaf))
+	body, _ = get(t, ts.URL+PathCTProof+query, "")
+	var proof CTProofResponse
+	if err := json.Unmarshal(body, &proof); err != nil {
+		t.Fatal(err)
+	}
+	if proof.LeafIndex != 4 || !core.VerifyRFCInclusion(h, 4, 6, leaf, proof.AuditPath, sth.RootHash) {
+		t.Errorf("audit path failed: %+v", proof)
+	}
+	// 叶子在更小的树中不存在
+	if _, status := get(t, ts.URL+PathCTProof+"?tree_size=4&hash="+url.QueryEscape(base64.StdEncoding.EncodeToString(leaf)), ""); status != http.StatusNotFound {
+		t.Errorf("status %d", status)
+	}
+
+	// 其他hash的log没有这些路由
+	other, _ := newTestServer(t)
+	if _, status := get(t, other.URL+PathCTSTH, ""); status != http.StatusNotFound {
+		t.Errorf("status %d", status)
+	}
+}
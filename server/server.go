@@ -0,0 +1,419 @@
+// Package server 通过HTTP提供MerklePT的append, digest和proof接口
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/pool"
+)
+
+// 路由
+const (
+	PathEpochs      = "/epochs"
+	PathDigest      = "/digest"
+	PathConsistency = "/consistency"
+	PathInclusion   = "/inclusion"
+	PathLookup      = "/lookup"
+	PathInfo        = "/info"
+	PathSigned      = "/signed-digest"
+	PathCheckpoint  = "/checkpoint"
+	PathTime        = "/time"
+	PathLogs        = "/logs" // 多个log时每个log的路由在 /logs/{name} 下
+)
+
+const (
+	ContentTypeBinary = "application/octet-stream"
+	ContentTypeJSON   = "application/json"
+	ContentTypeText   = "text/plain; charset=utf-8"
+)
+
+const maxRequestBody = 32 << 20
+
+// EpochRequest 是 POST /epochs 的请求体, values使用base64编码
+type EpochRequest struct {
+	Values [][]byte `json:"values"`
+}
+
+// EpochResponse 是 GET /epochs/{epoch} 的响应, 总是JSON
+type EpochResponse struct {
+	Epoch  uint32   `json:"epoch"`
+	Values [][]byte `json:"values"`
+}
+
+// InfoResponse 是 GET /info 的响应: log的参数和验证签名的公钥, 总是JSON
+type InfoResponse struct {
+	Config    ledger.Config `json:"config"`
+	PublicKey []byte        `json:"public_key"`
+}
+
+// Server 包装一个log, 可以被多个goroutine并发访问
+type Server struct {
+	log     *ledger.Log
+	tree    *core.MerklePT
+	mux     *http.ServeMux
+	replica Replica    // 只读时不为nil
+	pool    *pool.Pool // NewWithPool时不为nil
+}
+
+// Replica 是只读Server的状态, 例如follower: 只提供已经验证过的前缀, 签名来自primary
+type Replica interface {
+	// Snapshot 返回已经验证过的状态
+	Snapshot() *core.Snapshot
+	// Signed 返回primary对这个状态的签名
+	Signed() *core.SignedDigest
+	// PublicKey 返回primary的公钥
+	PublicKey() ed25519.PublicKey
+}
+
+// 响应使用core中的规范编码, 默认为二进制, Accept为application/json时返回JSON
+type object interface {
+	encoding.BinaryMarshaler
+	json.Marshaler
+}
+
+// New 创建Server
+func New(l *ledger.Log) *Server {
+	s := newServer(l, nil)
+	s.mux.HandleFunc("POST "+PathEpochs, s.handleAppend)
+	return s
+}
+
+// NewReadOnly 创建只提供proof的Server, 状态和签名由r决定, 不能添加epoch
+func NewReadOnly(l *ledger.Log, r Replica) *Server {
+	return newServer(l, r)
+}
+
+func newServer(l *ledger.Log, r Replica) *Server {
+	s := &Server{
+		log:     l,
+		tree:    l.Tree(),
+		mux:     http.NewServeMux(),
+		replica: r,
+	}
+	s.mux.HandleFunc("GET "+PathEpochs+"/{epoch}", s.handleEpoch)
+	s.mux.HandleFunc("GET "+PathDigest, s.handleDigest)
+	s.mux.HandleFunc("GET "+PathConsistency, s.handleConsistency)
+	s.mux.HandleFunc("GET "+PathInclusion, s.handleInclusion)
+	s.mux.HandleFunc("GET "+PathLookup, s.handleLookup)
+	s.mux.HandleFunc("GET "+PathInfo, s.handleInfo)
+	s.mux.HandleFunc("GET "+PathSigned, s.handleSigned)
+	s.mux.HandleFunc("GET "+PathCheckpoint, s.handleCheckpoint)
+	s.mux.HandleFunc("GET "+PathTime, s.handleTime)
+	s.handleRFC6962()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// POST /epochs: 添加一个epoch, 返回包含它的digest
+func (s *Server) handleAppend(w http.ResponseWriter, r *http.Request) {
+	var req EpochRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid epoch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		http.Error(w, "invalid epoch request: trailing data", http.StatusBadRequest)
+		return
+	}
+
+	epoch, err := s.log.Append(req.Values)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	digest, err := s.tree.Snapshot().GetOldDigest(epoch + 1)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, digest)
+}
+
+// GET /epochs/{epoch}: 返回epoch的所有值, 用于审计时重新计算
+func (s *Server) handleEpoch(w http.ResponseWriter, r *http.Request) {
+	epoch, err := strconv.ParseUint(r.PathValue("epoch"), 10, 32)
+	if err != nil {
+		writeError(w, &badRequest{fmt.Sprintf("invalid epoch: %v", err)})
+		return
+	}
+	if uint32(epoch) >= s.current().Size {
+		writeError(w, core.ErrInvalidSize)
+		return
+	}
+	values, err := s.tree.GetValues(uint32(epoch))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if values == nil {
+		values = [][]byte{}
+	}
+	writeJSON(w, EpochResponse{Epoch: uint32(epoch), Values: values})
+}
+
+// GET /digest?size=N: size为空时返回当前的digest
+func (s *Server) handleDigest(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, snap.Digest())
+}
+
+// GET /signed-digest?size=N: 带有log签名的digest
+func (s *Server) handleSigned(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if s.replica == nil {
+		writeObject(w, r, s.log.Sign(snap.Digest()))
+		return
+	}
+	// 只读时只有primary签过名的大小
+	signed := s.replica.Signed()
+	if signed == nil || signed.Digest.Size != snap.Size {
+		writeError(w, fmt.Errorf("%w: no signature for size %d", core.ErrInvalidSize, snap.Size))
+		return
+	}
+	writeObject(w, r, signed)
+}
+
+// GET /checkpoint?size=N: C2SP格式的signed note。
+// 只读时没有log的私钥, primary的签名只覆盖digest, 所以没有checkpoint
+func (s *Server) handleCheckpoint(w http.ResponseWriter, r *http.Request) {
+	if s.replica != nil {
+		http.NotFound(w, r)
+		return
+	}
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	msg, err := s.log.Checkpoint(snap.Digest())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeText)
+	_, _ = w.Write(msg)
+}
+
+// GET /info
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	pub := s.log.PublicKey()
+	if s.replica != nil {
+		pub = s.replica.PublicKey()
+	}
+	writeJSON(w, InfoResponse{Config: s.log.Config(), PublicKey: pub})
+}
+
+// GET /consistency?old=M&new=N: new为空时使用当前大小
+func (s *Server) handleConsistency(w http.ResponseWriter, r *http.Request) {
+	oldSize, err := queryUint32(r, "old")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap := s.current()
+	newSize, err := optionalUint32(r, "new", snap.Size)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := snap.GenerateConsistencyProof(oldSize, newSize)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, proof)
+}
+
+// GET /inclusion?epoch=E&size=N
+func (s *Server) handleInclusion(w http.ResponseWriter, r *http.Request) {
+	epoch, err := queryUint32(r, "epoch")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := snap.GenerateInclusionProof(epoch)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, proof)
+}
+
+// GET /lookup?epoch=E&pos=P&size=N
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	epoch, err := queryUint32(r, "epoch")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pos, err := queryUint32(r, "pos")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := snap.GenerateLookupProof(epoch, pos)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, proof)
+}
+
+// GET /time?t=MS&size=N: 时刻t(毫秒)时最新的epoch的TimeProof, 只有链式log的epoch有timestamp
+func (s *Server) handleTime(w http.ResponseWriter, r *http.Request) {
+	t, err := queryUint64(r, "t")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	snap, err := s.snapshot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	proof, err := snap.GenerateTimeProof(t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeObject(w, r, proof)
+}
+
+// 根据size参数返回snapshot, 没有size时为当前大小
+func (s *Server) snapshot(r *http.Request) (*core.Snapshot, error) {
+	snap := s.current()
+	size, err := optionalUint32(r, "size", snap.Size)
+	if err != nil {
+		return nil, err
+	}
+	return snap.At(size)
+}
+
+// 当前提供的状态, 只读时是replica验证过的状态
+func (s *Server) current() *core.Snapshot {
+	if s.replica != nil {
+		return s.replica.Snapshot()
+	}
+	return s.tree.Snapshot()
+}
+
+type badRequest struct {
+	msg string
+}
+
+func (e *badRequest) Error() string { return e.msg }
+
+// 可选参数, 不存在时返回def
+func optionalUint32(r *http.Request, name string, def uint32) (uint32, error) {
+	if r.URL.Query().Get(name) == "" {
+		return def, nil
+	}
+	return queryUint32(r, name)
+}
+
+func queryUint32(r *http.Request, name string) (uint32, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, &badRequest{fmt.Sprintf("missing parameter %q", name)}
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, &badRequest{fmt.Sprintf("invalid parameter %q: %v", name, err)}
+	}
+	return uint32(n), nil
+}
+
+func queryUint64(r *http.Request, name string) (uint64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, &badRequest{fmt.Sprintf("missing parameter %q", name)}
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, &badRequest{fmt.Sprintf("invalid parameter %q: %v", name, err)}
+	}
+	return n, nil
+}
+
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ContentTypeJSON)
+}
+
+func writeObject(w http.ResponseWriter, r *http.Request, obj object) {
+	var data []byte
+	var err error
+	contentType := ContentTypeBinary
+	if wantsJSON(r) {
+		contentType = ContentTypeJSON
+		data, err = obj.MarshalJSON()
+	} else {
+		data, err = obj.MarshalBinary()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	_, _ = w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var bad *badRequest
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &bad), errors.Is(err, core.ErrTooManyLeaves), errors.Is(err, pool.ErrInvalidEntry):
+		status = http.StatusBadRequest
+	case errors.Is(err, core.ErrInvalidSize), errors.Is(err, core.ErrLeafNotFound), errors.Is(err, ledger.ErrUnknownLog),
+		errors.Is(err, core.ErrNoTimestamp), errors.Is(err, core.ErrBeforeLogTime), errors.Is(err, pool.ErrNotIncluded):
+		status = http.StatusNotFound
+	case errors.Is(err, core.ErrTreeFull), errors.Is(err, core.ErrTimestamp), errors.Is(err, pool.ErrKeyPending):
+		status = http.StatusConflict
+	case errors.Is(err, pool.ErrFull):
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
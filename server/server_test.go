@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/ledger"
+	"MerkleVerkle/lib/storage"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *core.MerklePT) {
+	l, err := ledger.Create(storage.NewMemoryStorage(), ledger.Config{
+		Depth:       8,
+		K:           3,
+		VerkleDepth: 2,
+		Hash:        "shake128",
+		HashSize:    32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(New(l))
+	t.Cleanup(ts.Close)
+	return ts, l.Tree()
+}
+
+func get(t *testing.T, url string, accept string) ([]byte, int) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return body, resp.StatusCode
+}
+
+func postEpoch(t *testing.T, url string, values ...string) int {
+	req := EpochRequest{}
+	for _, v := range values {
+		req.Values = append(req.Values, []byte(v))
+	}
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(url+PathEpochs, ContentTypeJSON, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	ts, tree := newTestServer(t)
+
+	for i := 0; i < 5; i++ {
+		if status := postEpoch(t, ts.URL, fmt.Sprint("a", i), fmt.Sprint("b", i)); status != http.StatusOK {
+			t.Fatalf("append: status %d", status)
+		}
+	}
+
+	body, _ := get(t, ts.URL+PathDigest+"?size=2", "")
+	var oldDigest core.Digest
+	if err := oldDigest.UnmarshalBinary(body); err != nil {
+		t.Fatal(err)
+	}
+	body, _ = get(t, ts.URL+PathDigest, ContentTypeJSON)
+	var newDigest core.Digest
+	if err := json.Unmarshal(body, &newDigest); err != nil {
+		t.Fatal(err)
+	}
+	if newDigest.Size != 5 {
+		t.Errorf("current size %d", newDigest.Size)
+	}
+
+	body, _ = get(t, ts.URL+PathConsistency+"?old=2", "")
+	var consistency core.MerkleConsistencyProof
+	if err := consistency.UnmarshalBinary(body); err != nil {
+		t.Fatal(err)
+	}
+	if !core.VerifyExtensionProof(tree.Hasher(), &oldDigest, &newDigest, &consistency) {
+		t.Error("consistency proof failed")
+	}
+
+	body, _ = get(t, ts.URL+PathInclusion+"?epoch=3", "")
+	var inclusion core.MerkleInclusionProof
+	if err := inclusion.UnmarshalBinary(body); err != nil {
+		t.Fatal(err)
+	}
+	if !core.VerifyInclusionProof(tree.Hasher(), &newDigest, &inclusion) {
+		t.Error("inclusion proof failed")
+	}
+
+	body, _ = get(t, ts.URL+PathEpochs+"/4", "")
+	var epoch EpochResponse
+	if err := json.Unmarshal(body, &epoch); err != nil {
+		t.Fatal(err)
+	}
+	if epoch.Epoch != 4 || len(epoch.Values) != 2 || string(epoch.Values[0]) != "a4" {
+		t.Errorf("got %+v", epoch)
+	}
+
+	body, _ = get(t, ts.URL+PathLookup+"?epoch=1&pos=1&size=2", ContentTypeJSON)
+	var lookup core.LookupProof
+	if err := json.Unmarshal(body, &lookup); err != nil {
+		t.Fatal(err)
+	}
+	if string(lookup.Value) != "b1" || !core.VerifyLookupProof(tree.Hasher(), 3, 2, &oldDigest, &lookup) {
+		t.Error("lookup proof failed")
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	ts, _ := newTestServer(t)
+	postEpoch(t, ts.URL, "a")
+
+	tables := []struct {
+		path   string
+		status int
+	}{
+		{PathDigest + "?size=2", http.StatusNotFound},
+		{PathDigest + "?size=x", http.StatusBadRequest},
+		{PathConsistency, http.StatusBadRequest},
+		{PathConsistency + "?old=0", http.StatusNotFound},
+		{PathInclusion + "?epoch=1", http.StatusNotFound},
+		{PathLookup + "?epoch=0&pos=5", http.StatusNotFound},
+		{PathEpochs + "/1", http.StatusNotFound},
+		{PathEpochs + "/x", http.StatusBadRequest},
+	}
+	for _, table := range tables {
+		if _, status := get(t, ts.URL+table.path, ""); status != table.status {
+			t.Errorf("%s: got status %d, want %d", table.path, status, table.status)
+		}
+	}
+
+	values := make([]string, 10)
+	if status := postEpoch(t, ts.URL, values...); status != http.StatusBadRequest {
+		t.Errorf("too many values: got status %d", status)
+	}
+	resp, err := http.Post(ts.URL+PathEpochs, ContentTypeJSON, bytes.NewReader([]byte(`{"value":[]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown field: got status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,328 @@
+// Package tile 把MerklePT的节点hash导出为不可变的静态tile, 布局与Go checksum database(tlog)的tile相同:
+// 第L层的tile包含树中第L*H层的2^H个连续节点的hash, 路径为 tile/H/L/NNN, 还没有满的tile为 tile/H/L/NNN.p/W。
+// data tile(tile/H/data/NNN)与第0层的tile一一对应, 包含每个epoch的verkle tree的commitment。
+//
+// 完整的节点不会再改变, 所以tile写入之后不会被修改。客户端读取tile自己计算inclusion proof和consistency proof,
+// 任何静态的web server或者目录都可以提供proof。
+package tile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+)
+
+// DefaultHeight 是tile的默认高度, 与tlog相同, 每个tile有256个hash
+const DefaultHeight = 8
+
+const maxHeight = 16
+
+// 与server的路由相同, 所以客户端可以用同样的方式读取导出的目录
+const (
+	DigestFile       = "digest"
+	SignedDigestFile = "signed-digest"
+)
+
+var ErrInvalidTile = errors.New("tile: invalid tile")
+
+// Tile 是一个tile的位置
+type Tile struct {
+	H int    // tile的高度
+	L int    // 层, -1表示data tile
+	N uint32 // 在这一层中的下标
+	W int    // 宽度, 1到2^H
+}
+
+func validHeight(h int) bool {
+	return h >= 1 && h <= maxHeight
+}
+
+// Path 返回tile的路径, 例如tile/8/0/x001/234.p/5。N按三位十进制分段, 除最后一段外都以x开头
+func (t Tile) Path() string {
+	n := t.N
+	nStr := fmt.Sprintf("%03d", n%1000)
+	for n >= 1000 {
+		n /= 1000
+		nStr = fmt.Sprintf("x%03d/%s", n%1000, nStr)
+	}
+	level := strconv.Itoa(t.L)
+	if t.L == -1 {
+		level = "data"
+	}
+	path := fmt.Sprintf("tile/%d/%s/%s", t.H, level, nStr)
+	if t.W != 1<<t.H {
+		path += ".p/" + strconv.Itoa(t.W)
+	}
+	return path
+}
+
+// ParsePath 是Path的逆操作
+func ParsePath(path string) (Tile, error) {
+	invalid := fmt.Errorf("%w path %q", ErrInvalidTile, path)
+	f := strings.Split(path, "/")
+	if len(f) < 4 || f[0] != "tile" {
+		return Tile{}, invalid
+	}
+	var t Tile
+	var err error
+	if t.H, err = strconv.Atoi(f[1]); err != nil || !validHeight(t.H) {
+		return Tile{}, invalid
+	}
+	if f[2] == "data" {
+		t.L = -1
+	} else if t.L, err = strconv.Atoi(f[2]); err != nil || t.L < 0 {
+		return Tile{}, invalid
+	}
+	f = f[3:]
+	t.W = 1 << t.H
+	if n := len(f); n >= 2 && strings.HasSuffix(f[n-2], ".p") {
+		if t.W, err = strconv.Atoi(f[n-1]); err != nil || t.W < 1 || t.W >= 1<<t.H {
+			return Tile{}, invalid
+		}
+		f[n-2] = strings.TrimSuffix(f[n-2], ".p")
+		f = f[:n-1]
+	}
+	var n uint64
+	for i, s := range f {
+		if i < len(f)-1 {
+			if !strings.HasPrefix(s, "x") {
+				return Tile{}, invalid
+			}
+			s = s[1:]
+		}
+		d, err := strconv.ParseUint(s, 10, 32)
+		if len(s) != 3 || err != nil {
+			return Tile{}, invalid
+		}
+		n = n*1000 + d
+	}
+	t.N = uint32(n)
+	// 只接受规范的路径
+	if n > 1<<32-1 || t.Path() != path {
+		return Tile{}, invalid
+	}
+	return t, nil
+}
+
+// 大小为size时第level层的完整节点个数
+func width(level uint32, size uint32) uint32 {
+	if level >= 32 {
+		return 0
+	}
+	return size >> level
+}
+
+// tileFor 返回大小为size时包含第L层第index个hash的tile
+func tileFor(h int, l int, index uint32, size uint32) Tile {
+	level := uint32(0)
+	if l > 0 {
+		level = uint32(l * h)
+	}
+	n := index >> h
+	w := width(level, size) - n<<h
+	if w > 1<<h {
+		w = 1 << h
+	}
+	return Tile{H: h, L: l, N: n, W: int(w)}
+}
+
+// Tiles 返回大小为size时所有的tile, 包括data tile
+func Tiles(h int, size uint32) []Tile {
+	var tiles []Tile
+	for l := -1; ; l++ {
+		level := uint32(0)
+		if l > 0 {
+			level = uint32(l * h)
+		}
+		n := width(level, size)
+		if n == 0 {
+			return tiles
+		}
+		for i := uint32(0); i<<h < n; i++ {
+			tiles = append(tiles, tileFor(h, l, i<<h, size))
+		}
+	}
+}
+
+// Export 把snapshot的tile写入dir, 已经存在的tile不会重写, 然后写入digest和signed的签名, 返回写入的tile个数。
+// signed必须是snapshot的digest的签名, 链式的MerklePT不能导出
+func Export(dir string, h int, snap *core.Snapshot, signed *core.SignedDigest) (int, error) {
+	if !validHeight(h) {
+		return 0, fmt.Errorf("%w height %d", ErrInvalidTile, h)
+	}
+	if signed == nil || signed.Digest.Size != snap.Size {
+		return 0, errors.New("tile: signed digest does not match the snapshot")
+	}
+	// tile中只有acc, 不能恢复链式叶子的header
+	if snap.Chained() {
+		return 0, errors.New("tile: chained trees cannot be exported")
+	}
+	written := 0
+	for _, t := range Tiles(h, snap.Size) {
+		path := filepath.Join(dir, filepath.FromSlash(t.Path()))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		data, err := tileData(snap, t)
+		if err != nil {
+			return written, err
+		}
+		if err := writeFile(path, data); err != nil {
+			return written, err
+		}
+		written++
+	}
+	// digest最后写入, 读到digest的客户端一定能读到它的tile
+	digest, _ := signed.Digest.MarshalBinary()
+	if err := writeFile(filepath.Join(dir, DigestFile), digest); err != nil {
+		return written, err
+	}
+	data, err := signed.MarshalBinary()
+	if err != nil {
+		return written, err
+	}
+	return written, writeFile(filepath.Join(dir, SignedDigestFile), data)
+}
+
+// tile的内容: W个hash连在一起
+func tileData(snap *core.Snapshot, t Tile) ([]byte, error) {
+	var data []byte
+	for i := 0; i < t.W; i++ {
+		index := t.N<<t.H + uint32(i)
+		var hash []byte
+		var err error
+		if t.L == -1 {
+			hash, err = snap.LeafAcc(index)
+		} else {
+			hash, err = snap.NodeHash(uint32(t.L*t.H), index)
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, hash...)
+	}
+	return data, nil
+}
+
+// 先写临时文件再rename, 读取的一方不会看到写了一半的文件
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Fetcher 读取导出目录中的文件, path是相对路径, 例如Tile.Path()
+type Fetcher func(path string) ([]byte, error)
+
+// Dir 返回读取本地目录的Fetcher
+func Dir(dir string) Fetcher {
+	return func(path string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	}
+}
+
+// Reader 从大小为size的tile中读取节点hash, 读过的tile会被缓存
+type Reader struct {
+	fetch  Fetcher
+	h      int
+	size   uint32
+	hasher crypto.Hasher
+
+	mu    sync.Mutex
+	tiles map[Tile][]byte
+}
+
+// NewReader 创建Reader, hasher是log使用的hash函数(包括tree ID)。
+// tile的内容没有单独验证, 由它们计算出的proof对digest验证
+func NewReader(fetch Fetcher, h int, size uint32, hasher crypto.Hasher) *Reader {
+	return &Reader{fetch: fetch, h: h, size: size, hasher: hasher, tiles: make(map[Tile][]byte)}
+}
+
+// NodeHash 返回第depth层第shift个节点的hash, 可以用作core.HashReader。
+// 不在tile层上的节点由下面一层tile中的hash计算
+func (r *Reader) NodeHash(depth uint32, shift uint32) ([]byte, error) {
+	if (uint64(shift)+1)<<depth > uint64(r.size) {
+		return nil, core.ErrInvalidSize
+	}
+	if depth%uint32(r.h) != 0 {
+		left, err := r.NodeHash(depth-1, shift*2)
+		if err != nil {
+			return nil, err
+		}
+		right, err := r.NodeHash(depth-1, shift*2+1)
+		if err != nil {
+			return nil, err
+		}
+		return core.HashChildren(r.hasher, left, right), nil
+	}
+	return r.hash(int(depth)/r.h, shift)
+}
+
+// LeafAcc 返回第epoch个epoch的verkle tree的commitment
+func (r *Reader) LeafAcc(epoch uint32) ([]byte, error) {
+	if epoch >= r.size {
+		return nil, core.ErrInvalidSize
+	}
+	return r.hash(-1, epoch)
+}
+
+// 读取第l层tile中的第index个hash
+func (r *Reader) hash(l int, index uint32) ([]byte, error) {
+	t := tileFor(r.h, l, index, r.size)
+	data, err := r.readTile(t)
+	if err != nil {
+		return nil, err
+	}
+	size := r.hasher.Size()
+	i := int(index-t.N<<t.H) * size
+	return data[i : i+size], nil
+}
+
+// 读取tile, 未满的tile不存在时读取满的tile(写入方可能删除了未满的tile)
+func (r *Reader) readTile(t Tile) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if data, ok := r.tiles[t]; ok {
+		return data, nil
+	}
+	read := t
+	data, err := r.fetch(t.Path())
+	if err != nil && t.W < 1<<t.H {
+		full := t
+		full.W = 1 << t.H
+		if fullData, fullErr := r.fetch(full.Path()); fullErr == nil {
+			read, data, err = full, fullData, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != read.W*r.hasher.Size() {
+		return nil, fmt.Errorf("%w: %s has %d bytes", ErrInvalidTile, read.Path(), len(data))
+	}
+	data = data[:t.W*r.hasher.Size()]
+	r.tiles[t] = data
+	return data, nil
+}
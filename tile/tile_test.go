@@ -0,0 +1,170 @@
+package tile
+
+import (
+	"crypto/ed25519"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"MerkleVerkle/core"
+	"MerkleVerkle/lib/crypto"
+)
+
+func TestPath(t *testing.T) {
+	tables := []struct {
+		tile Tile
+		path string
+	}{
+		{Tile{H: 8, L: 0, N: 0, W: 256}, "tile/8/0/000"},
+		{Tile{H: 8, L: 1, N: 1234067, W: 256}, "tile/8/1/x001/x234/067"},
+		{Tile{H: 8, L: -1, N: 3, W: 5}, "tile/8/data/003.p/5"},
+		{Tile{H: 2, L: 3, N: 1000, W: 1}, "tile/2/3/x001/000.p/1"},
+	}
+	for _, table := range tables {
+		if path := table.tile.Path(); path != table.path {
+			t.Errorf("%+v: got %s, want %s", table.tile, path, table.path)
+		}
+		if tile, err := ParsePath(table.path); err != nil || tile != table.tile {
+			t.Errorf("ParsePath(%s) = %+v, %v", table.path, tile, err)
+		}
+	}
+	for _, path := range []string{"tile/8/0/1", "tile/8/0/0000", "tile/8/0/001/002", "tile/8/0/000.p/256",
+		"tile/8/0/000.p/0", "tile/0/0/000", "tile/8/-1/000", "tiles/8/0/000", "tile/8/0/x000/001"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("ParsePath(%s) should fail", path)
+		}
+	}
+}
+
+func TestTiles(t *testing.T) {
+	// 高度为2, 大小为11: data和第0层各有3个tile(4, 4, 3), 第1层有2个(2个完整的节点在第2层)
+	got := Tiles(2, 11)
+	want := []Tile{
+		{2, -1, 0, 4}, {2, -1, 1, 4}, {2, -1, 2, 3},
+		{2, 0, 0, 4}, {2, 0, 1, 4}, {2, 0, 2, 3},
+		{2, 1, 0, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func createTree(t *testing.T, size uint32) *core.MerklePT {
+	m := core.NewMerklePT(6, crypto.Default)
+	for i := uint32(0); i < size; i++ {
+		if _, err := m.AppendValues(2, 2, [][]byte{{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestExportAndProve(t *testing.T) {
+	dir := t.TempDir()
+	_, key, _ := ed25519.GenerateKey(nil)
+	m := createTree(t, 7)
+	snap := m.Snapshot()
+	if _, err := Export(dir, 2, snap, core.SignDigest(key, nil, snap.Digest())); err != nil {
+		t.Fatal(err)
+	}
+	old := snap
+
+	// 树变大之后只写新的tile, 旧的tile不变
+	for i := byte(7); i < 29; i++ {
+		m.AppendValues(2, 2, [][]byte{{i}})
+	}
+	snap = m.Snapshot()
+	n, err := Export(dir, 2, snap, core.SignDigest(key, nil, snap.Digest()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 大小为29时有19个tile, 其中data和第0层的第一个tile在大小为7时已经是满的
+	if n != 17 {
+		t.Errorf("wrote %d tiles", n)
+	}
+	// 写入方可以删除已经满了的tile的未满版本
+	if err := os.Remove(filepath.Join(dir, "tile/2/0/001.p/3")); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer ts.Close()
+	fetchers := map[string]Fetcher{
+		"dir": Dir(dir),
+		"http": func(path string) ([]byte, error) {
+			resp, err := http.Get(ts.URL + "/" + path)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return nil, os.ErrNotExist
+			}
+			return io.ReadAll(resp.Body)
+		},
+	}
+	for name, fetch := range fetchers {
+		for _, s := range []*core.Snapshot{old, snap} {
+			r := NewReader(fetch, 2, s.Size, crypto.Default)
+			digest := s.Digest()
+			for epoch := uint32(0); epoch < s.Size; epoch++ {
+				acc, err := r.LeafAcc(epoch)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				proof, err := core.ProveInclusion(r.NodeHash, epoch, s.Size, acc)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if !core.VerifyInclusionProof(crypto.Default, digest, proof) {
+					t.Errorf("%s: inclusion of %d in %d failed", name, epoch, s.Size)
+				}
+			}
+		}
+		r := NewReader(fetch, 2, snap.Size, crypto.Default)
+		for oldSize := uint32(1); oldSize <= snap.Size; oldSize++ {
+			proof, err := core.ProveConsistency(r.NodeHash, oldSize, snap.Size)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			old, err := m.GetOldDigest(oldSize)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !core.VerifyExtensionProof(crypto.Default, old, snap.Digest(), proof) {
+				t.Errorf("%s: consistency %d -> %d failed", name, oldSize, snap.Size)
+			}
+		}
+	}
+}
+
+func TestEmptyEpoch(t *testing.T) {
+	dir := t.TempDir()
+	_, key, _ := ed25519.GenerateKey(nil)
+	m := core.NewMerklePT(6, crypto.Default)
+	for _, values := range [][][]byte{{[]byte("a")}, nil, {[]byte("b")}} {
+		if _, err := m.AppendValues(2, 2, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := m.Snapshot()
+	if _, err := Export(dir, 2, snap, core.SignDigest(key, nil, snap.Digest())); err != nil {
+		t.Fatal(err)
+	}
+	// 空的verkle tree的acc也是定长的, 同一个data tile中的其他epoch仍然可以读取
+	r := NewReader(Dir(dir), 2, snap.Size, crypto.Default)
+	for epoch := uint32(0); epoch < snap.Size; epoch++ {
+		acc, err := r.LeafAcc(epoch)
+		if err != nil {
+			t.Fatalf("epoch %d: %v", epoch, err)
+		}
+		proof, err := core.ProveInclusion(r.NodeHash, epoch, snap.Size, acc)
+		if err != nil || !core.VerifyInclusionProof(crypto.Default, snap.Digest(), proof) {
+			t.Errorf("epoch %d: inclusion failed: %v", epoch, err)
+		}
+	}
+}